@@ -3,7 +3,9 @@ package api
 import (
 	"fmt"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"strconv"
 )
 
 type ErrOffsetOutOfRange struct {
@@ -34,3 +36,411 @@ func (e ErrOffsetOutOfRange) GRPCStatus() *status.Status {
 func (e ErrOffsetOutOfRange) Error() string {
 	return e.GRPCStatus().Err().Error()
 }
+
+// ErrCorruptRecord names the segment by its base offset, the files of the server aren't disclosed to clients
+type ErrCorruptRecord struct {
+	Offset     uint64
+	BaseOffset uint64
+}
+
+func (e ErrCorruptRecord) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.DataLoss,
+		fmt.Sprintf("corrupt record at offset %d in segment %d", e.Offset, e.BaseOffset),
+	)
+	msg := fmt.Sprintf(
+		"The record stored at offset %d failed its integrity check, segment %d",
+		e.Offset,
+		e.BaseOffset,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrCorruptRecord) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrUnknownProducer struct {
+	ProducerID uint64
+}
+
+func (e ErrUnknownProducer) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("unknown producer: %d", e.ProducerID),
+	)
+	msg := fmt.Sprintf(
+		"The producer id was not handed out by RegisterProducer: %d",
+		e.ProducerID,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrUnknownProducer) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrOutOfOrderSequence struct {
+	ProducerID   uint64
+	Sequence     uint64
+	LastSequence uint64
+}
+
+func (e ErrOutOfOrderSequence) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.FailedPrecondition,
+		fmt.Sprintf("out of order sequence %d for producer %d, last appended sequence is %d",
+			e.Sequence, e.ProducerID, e.LastSequence),
+	)
+	msg := fmt.Sprintf(
+		"The sequence is neither new nor recent enough for the offsets it was appended at to be known: %d",
+		e.Sequence,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrOutOfOrderSequence) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+// ErrUnexpectedOffset fails a conditional append, NextOffset is the offset the records would have been appended at
+type ErrUnexpectedOffset struct {
+	Expected   uint64
+	NextOffset uint64
+}
+
+func (e ErrUnexpectedOffset) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.FailedPrecondition,
+		fmt.Sprintf("unexpected offset: expected %d, next offset is %d", e.Expected, e.NextOffset),
+	)
+	msg := fmt.Sprintf(
+		"The log moved on since the expected offset was read, its next offset is: %d",
+		e.NextOffset,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	// lets clients read the next offset back without parsing the message
+	info := &errdetails.ErrorInfo{
+		Reason:   "UNEXPECTED_OFFSET",
+		Metadata: map[string]string{"next_offset": strconv.FormatUint(e.NextOffset, 10)},
+	}
+	std, err := st.WithDetails(d, info)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrUnexpectedOffset) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrTopicNotFound struct {
+	Topic string
+}
+
+func (e ErrTopicNotFound) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("topic not found: %s", e.Topic),
+	)
+	msg := fmt.Sprintf(
+		"The topic doesn't exist, it must be created first: %s",
+		e.Topic,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrTopicNotFound) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrTopicExists struct {
+	Topic string
+}
+
+func (e ErrTopicExists) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.AlreadyExists,
+		fmt.Sprintf("topic already exists: %s", e.Topic),
+	)
+	msg := fmt.Sprintf(
+		"A topic with the same name already exists: %s",
+		e.Topic,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrTopicExists) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrPartitionNotFound struct {
+	Topic     string
+	Partition uint32
+}
+
+func (e ErrPartitionNotFound) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("partition not found: %s/%d", e.Topic, e.Partition),
+	)
+	msg := fmt.Sprintf(
+		"The topic has fewer partitions than requested: %s/%d",
+		e.Topic,
+		e.Partition,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrPartitionNotFound) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrUnknownMember struct {
+	Group    string
+	MemberID string
+}
+
+func (e ErrUnknownMember) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("unknown member of group %s: %s", e.Group, e.MemberID),
+	)
+	msg := fmt.Sprintf(
+		"The member left the group or missed its heartbeats, it must join again: %s/%s",
+		e.Group,
+		e.MemberID,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrUnknownMember) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrStaleGeneration struct {
+	Group             string
+	Generation        uint64
+	CurrentGeneration uint64
+}
+
+func (e ErrStaleGeneration) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.FailedPrecondition,
+		fmt.Sprintf("stale generation of group %s: %d, current %d", e.Group, e.Generation, e.CurrentGeneration),
+	)
+	msg := fmt.Sprintf(
+		"The group was rebalanced since generation %d, the partitions of the member are the ones of generation %d: %s",
+		e.Generation,
+		e.CurrentGeneration,
+		e.Group,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrStaleGeneration) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrPartitionNotAssigned struct {
+	Group     string
+	MemberID  string
+	Topic     string
+	Partition uint32
+}
+
+func (e ErrPartitionNotAssigned) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.FailedPrecondition,
+		fmt.Sprintf("partition %s/%d not assigned to member %s of group %s", e.Topic, e.Partition, e.MemberID, e.Group),
+	)
+	msg := fmt.Sprintf(
+		"Only the member assigned the partition commits its offsets: %s/%d",
+		e.Topic,
+		e.Partition,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrPartitionNotAssigned) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrGroupTopicMismatch struct {
+	Group      string
+	Topic      string
+	GroupTopic string
+}
+
+func (e ErrGroupTopicMismatch) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.FailedPrecondition,
+		fmt.Sprintf("group %s consumes topic %s, not %s", e.Group, e.GroupTopic, e.Topic),
+	)
+	msg := fmt.Sprintf(
+		"All the members of a group consume the same topic, the group consumes %s: %s",
+		e.GroupTopic,
+		e.Group,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrGroupTopicMismatch) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrNoCommittedOffset struct {
+	Group     string
+	Topic     string
+	Partition uint32
+}
+
+func (e ErrNoCommittedOffset) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.NotFound,
+		fmt.Sprintf("no committed offset for group %s on %s/%d", e.Group, e.Topic, e.Partition),
+	)
+	msg := fmt.Sprintf(
+		"The group never committed an offset for the partition: %s/%d",
+		e.Topic,
+		e.Partition,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrNoCommittedOffset) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
+
+type ErrLogClosed struct {
+	Dir string
+}
+
+func (e ErrLogClosed) GRPCStatus() *status.Status {
+	st := status.New(
+		codes.Unavailable,
+		fmt.Sprintf("log closed: %s", e.Dir),
+	)
+	msg := fmt.Sprintf(
+		"The log was closed while the request was served, its topic may have been deleted: %s",
+		e.Dir,
+	)
+
+	d := &errdetails.LocalizedMessage{
+		Locale:  "en-US",
+		Message: msg,
+	}
+	std, err := st.WithDetails(d)
+	if err != nil {
+		return st
+	}
+	return std
+}
+
+func (e ErrLogClosed) Error() string {
+	return e.GRPCStatus().Err().Error()
+}
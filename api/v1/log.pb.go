@@ -20,9 +20,132 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+// how the log stores the records of a request, records appended together are compressed as a single batch
+type Compression int32
+
+const (
+	// the compression configured for the log
+	Compression_DEFAULT_COMPRESSION Compression = 0
+	Compression_NO_COMPRESSION      Compression = 1
+	Compression_GZIP                Compression = 2
+	Compression_FLATE               Compression = 3
+)
+
+var Compression_name = map[int32]string{
+	0: "DEFAULT_COMPRESSION",
+	1: "NO_COMPRESSION",
+	2: "GZIP",
+	3: "FLATE",
+}
+
+var Compression_value = map[string]int32{
+	"DEFAULT_COMPRESSION": 0,
+	"NO_COMPRESSION":      1,
+	"GZIP":                2,
+	"FLATE":               3,
+}
+
+func (x Compression) String() string {
+	return proto.EnumName(Compression_name, int32(x))
+}
+
+func (Compression) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{0}
+}
+
+// how the partition of a topic the records are appended to is picked, the records of a request go to a single
+// partition
+// a retry of an idempotent producer must reach the partition of the request it retries: adding partitions to the topic
+// moves the records with a key, and the ones of a producer, to other partitions
+type Partitioner int32
+
+const (
+	// the hash of the keys of the records for the records with a key, round robin for the others, or a partition chosen
+	// by the producer id for an idempotent producer
+	Partitioner_DEFAULT_PARTITIONER Partitioner = 0
+	// records sharing a key go to the same partition until partitions are added to the topic
+	Partitioner_KEY_HASH Partitioner = 1
+	// refused for idempotent producers
+	Partitioner_ROUND_ROBIN Partitioner = 2
+	// the partition named by the request
+	Partitioner_EXPLICIT Partitioner = 3
+)
+
+var Partitioner_name = map[int32]string{
+	0: "DEFAULT_PARTITIONER",
+	1: "KEY_HASH",
+	2: "ROUND_ROBIN",
+	3: "EXPLICIT",
+}
+
+var Partitioner_value = map[string]int32{
+	"DEFAULT_PARTITIONER": 0,
+	"KEY_HASH":            1,
+	"ROUND_ROBIN":         2,
+	"EXPLICIT":            3,
+}
+
+func (x Partitioner) String() string {
+	return proto.EnumName(Partitioner_name, int32(x))
+}
+
+func (Partitioner) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{1}
+}
+
+// where a consume starts, resolved against the partition when the request is received
+type StartPosition int32
+
+const (
+	// offset, or start_timestamp when it is set
+	StartPosition_START_OFFSET StartPosition = 0
+	// the first record of the partition
+	StartPosition_START_EARLIEST StartPosition = 1
+	// the next record appended to the partition
+	StartPosition_START_LATEST StartPosition = 2
+	// latest_minus records before the next one appended, the first record of the partition when it holds fewer
+	StartPosition_START_LATEST_MINUS StartPosition = 3
+	// the first record with a timestamp at or after start_timestamp
+	StartPosition_START_TIMESTAMP StartPosition = 4
+)
+
+var StartPosition_name = map[int32]string{
+	0: "START_OFFSET",
+	1: "START_EARLIEST",
+	2: "START_LATEST",
+	3: "START_LATEST_MINUS",
+	4: "START_TIMESTAMP",
+}
+
+var StartPosition_value = map[string]int32{
+	"START_OFFSET":       0,
+	"START_EARLIEST":     1,
+	"START_LATEST":       2,
+	"START_LATEST_MINUS": 3,
+	"START_TIMESTAMP":    4,
+}
+
+func (x StartPosition) String() string {
+	return proto.EnumName(StartPosition_name, int32(x))
+}
+
+func (StartPosition) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{2}
+}
+
 type LogRecord struct {
-	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
-	Offset               uint64   `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	Value  []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Offset uint64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	// unix nanoseconds, set by the producer or by the log when the record is appended
+	Timestamp int64 `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// optional, compaction keeps only the latest record of every key
+	// a keyed record with an empty value is a tombstone marking the key as deleted
+	Key []byte `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	// metadata such as content type or trace ids, stored along with the record
+	Headers []*Header `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty"`
+	// set by the log on the records appended by an idempotent producer, see RegisterProducer
+	ProducerId           uint64   `protobuf:"varint,6,opt,name=producer_id,json=producerId,proto3" json:"producer_id,omitempty"`
+	ProducerSequence     uint64   `protobuf:"varint,7,opt,name=producer_sequence,json=producerSequence,proto3" json:"producer_sequence,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -67,18 +190,113 @@ func (m *LogRecord) GetOffset() uint64 {
 	return 0
 }
 
+func (m *LogRecord) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+func (m *LogRecord) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *LogRecord) GetHeaders() []*Header {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+func (m *LogRecord) GetProducerId() uint64 {
+	if m != nil {
+		return m.ProducerId
+	}
+	return 0
+}
+
+func (m *LogRecord) GetProducerSequence() uint64 {
+	if m != nil {
+		return m.ProducerSequence
+	}
+	return 0
+}
+
+type Header struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Header) Reset()         { *m = Header{} }
+func (m *Header) String() string { return proto.CompactTextString(m) }
+func (*Header) ProtoMessage()    {}
+func (*Header) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{1}
+}
+
+func (m *Header) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Header.Unmarshal(m, b)
+}
+func (m *Header) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Header.Marshal(b, m, deterministic)
+}
+func (m *Header) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Header.Merge(m, src)
+}
+func (m *Header) XXX_Size() int {
+	return xxx_messageInfo_Header.Size(m)
+}
+func (m *Header) XXX_DiscardUnknown() {
+	xxx_messageInfo_Header.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Header proto.InternalMessageInfo
+
+func (m *Header) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Header) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
 type ProduceRequest struct {
-	Record               *LogRecord `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
-	XXX_unrecognized     []byte     `json:"-"`
-	XXX_sizecache        int32      `json:"-"`
+	Record      *LogRecord  `protobuf:"bytes,1,opt,name=record,proto3" json:"record,omitempty"`
+	Compression Compression `protobuf:"varint,2,opt,name=compression,proto3,enum=log.v1.Compression" json:"compression,omitempty"`
+	// set by idempotent producers, see RegisterProducer
+	ProducerId uint64 `protobuf:"varint,3,opt,name=producer_id,json=producerId,proto3" json:"producer_id,omitempty"`
+	// greater than the sequence of the previous request of the producer, a request carrying a sequence the log already
+	// appended is answered with the offsets it was appended at instead of being appended again
+	Sequence uint64 `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// when set, the record is only appended if it gets this offset
+	ExpectedOffset *ExpectedOffset `protobuf:"bytes,5,opt,name=expected_offset,json=expectedOffset,proto3" json:"expected_offset,omitempty"`
+	// the default topic when empty
+	Topic       string      `protobuf:"bytes,6,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partitioner Partitioner `protobuf:"varint,7,opt,name=partitioner,proto3,enum=log.v1.Partitioner" json:"partitioner,omitempty"`
+	// with the EXPLICIT partitioner, the partition the record is appended to
+	Partition            uint32   `protobuf:"varint,8,opt,name=partition,proto3" json:"partition,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ProduceRequest) Reset()         { *m = ProduceRequest{} }
 func (m *ProduceRequest) String() string { return proto.CompactTextString(m) }
 func (*ProduceRequest) ProtoMessage()    {}
 func (*ProduceRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_19a5c3fde3f7ae80, []int{1}
+	return fileDescriptor_19a5c3fde3f7ae80, []int{2}
 }
 
 func (m *ProduceRequest) XXX_Unmarshal(b []byte) error {
@@ -106,8 +324,99 @@ func (m *ProduceRequest) GetRecord() *LogRecord {
 	return nil
 }
 
+func (m *ProduceRequest) GetCompression() Compression {
+	if m != nil {
+		return m.Compression
+	}
+	return Compression_DEFAULT_COMPRESSION
+}
+
+func (m *ProduceRequest) GetProducerId() uint64 {
+	if m != nil {
+		return m.ProducerId
+	}
+	return 0
+}
+
+func (m *ProduceRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+func (m *ProduceRequest) GetExpectedOffset() *ExpectedOffset {
+	if m != nil {
+		return m.ExpectedOffset
+	}
+	return nil
+}
+
+func (m *ProduceRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *ProduceRequest) GetPartitioner() Partitioner {
+	if m != nil {
+		return m.Partitioner
+	}
+	return Partitioner_DEFAULT_PARTITIONER
+}
+
+func (m *ProduceRequest) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+// condition of an append, it fails with FailedPrecondition when the next offset of the log is another one
+type ExpectedOffset struct {
+	NextOffset           uint64   `protobuf:"varint,1,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExpectedOffset) Reset()         { *m = ExpectedOffset{} }
+func (m *ExpectedOffset) String() string { return proto.CompactTextString(m) }
+func (*ExpectedOffset) ProtoMessage()    {}
+func (*ExpectedOffset) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{3}
+}
+
+func (m *ExpectedOffset) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExpectedOffset.Unmarshal(m, b)
+}
+func (m *ExpectedOffset) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExpectedOffset.Marshal(b, m, deterministic)
+}
+func (m *ExpectedOffset) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExpectedOffset.Merge(m, src)
+}
+func (m *ExpectedOffset) XXX_Size() int {
+	return xxx_messageInfo_ExpectedOffset.Size(m)
+}
+func (m *ExpectedOffset) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExpectedOffset.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExpectedOffset proto.InternalMessageInfo
+
+func (m *ExpectedOffset) GetNextOffset() uint64 {
+	if m != nil {
+		return m.NextOffset
+	}
+	return 0
+}
+
+// offsets are numbered within the partition
 type ProduceResponse struct {
 	Offset               uint64   `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	Partition            uint32   `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -117,7 +426,7 @@ func (m *ProduceResponse) Reset()         { *m = ProduceResponse{} }
 func (m *ProduceResponse) String() string { return proto.CompactTextString(m) }
 func (*ProduceResponse) ProtoMessage()    {}
 func (*ProduceResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_19a5c3fde3f7ae80, []int{2}
+	return fileDescriptor_19a5c3fde3f7ae80, []int{4}
 }
 
 func (m *ProduceResponse) XXX_Unmarshal(b []byte) error {
@@ -145,111 +454,1601 @@ func (m *ProduceResponse) GetOffset() uint64 {
 	return 0
 }
 
-type ConsumeRequest struct {
-	Offset               uint64   `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+func (m *ProduceResponse) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+// the records of a batch are appended all together, a crash never keeps only some of them
+type ProduceBatchRequest struct {
+	Records     []*LogRecord `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	Compression Compression  `protobuf:"varint,2,opt,name=compression,proto3,enum=log.v1.Compression" json:"compression,omitempty"`
+	// same as in ProduceRequest, the whole batch takes a single sequence
+	ProducerId uint64 `protobuf:"varint,3,opt,name=producer_id,json=producerId,proto3" json:"producer_id,omitempty"`
+	Sequence   uint64 `protobuf:"varint,4,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// when set, the batch is only appended if its first record gets this offset
+	ExpectedOffset       *ExpectedOffset `protobuf:"bytes,5,opt,name=expected_offset,json=expectedOffset,proto3" json:"expected_offset,omitempty"`
+	Topic                string          `protobuf:"bytes,6,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partitioner          Partitioner     `protobuf:"varint,7,opt,name=partitioner,proto3,enum=log.v1.Partitioner" json:"partitioner,omitempty"`
+	Partition            uint32          `protobuf:"varint,8,opt,name=partition,proto3" json:"partition,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *ProduceBatchRequest) Reset()         { *m = ProduceBatchRequest{} }
+func (m *ProduceBatchRequest) String() string { return proto.CompactTextString(m) }
+func (*ProduceBatchRequest) ProtoMessage()    {}
+func (*ProduceBatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{5}
+}
+
+func (m *ProduceBatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProduceBatchRequest.Unmarshal(m, b)
+}
+func (m *ProduceBatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProduceBatchRequest.Marshal(b, m, deterministic)
+}
+func (m *ProduceBatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProduceBatchRequest.Merge(m, src)
+}
+func (m *ProduceBatchRequest) XXX_Size() int {
+	return xxx_messageInfo_ProduceBatchRequest.Size(m)
+}
+func (m *ProduceBatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProduceBatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProduceBatchRequest proto.InternalMessageInfo
+
+func (m *ProduceBatchRequest) GetRecords() []*LogRecord {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *ProduceBatchRequest) GetCompression() Compression {
+	if m != nil {
+		return m.Compression
+	}
+	return Compression_DEFAULT_COMPRESSION
+}
+
+func (m *ProduceBatchRequest) GetProducerId() uint64 {
+	if m != nil {
+		return m.ProducerId
+	}
+	return 0
+}
+
+func (m *ProduceBatchRequest) GetSequence() uint64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+func (m *ProduceBatchRequest) GetExpectedOffset() *ExpectedOffset {
+	if m != nil {
+		return m.ExpectedOffset
+	}
+	return nil
+}
+
+func (m *ProduceBatchRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *ProduceBatchRequest) GetPartitioner() Partitioner {
+	if m != nil {
+		return m.Partitioner
+	}
+	return Partitioner_DEFAULT_PARTITIONER
+}
+
+func (m *ProduceBatchRequest) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+// producer ids are valid on every partition of the topic they were registered with, sequences are checked per
+// partition
+type RegisterProducerRequest struct {
+	Topic                string   `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *ConsumeRequest) Reset()         { *m = ConsumeRequest{} }
-func (m *ConsumeRequest) String() string { return proto.CompactTextString(m) }
-func (*ConsumeRequest) ProtoMessage()    {}
-func (*ConsumeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_19a5c3fde3f7ae80, []int{3}
+func (m *RegisterProducerRequest) Reset()         { *m = RegisterProducerRequest{} }
+func (m *RegisterProducerRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterProducerRequest) ProtoMessage()    {}
+func (*RegisterProducerRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{6}
 }
 
-func (m *ConsumeRequest) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_ConsumeRequest.Unmarshal(m, b)
+func (m *RegisterProducerRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterProducerRequest.Unmarshal(m, b)
 }
-func (m *ConsumeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_ConsumeRequest.Marshal(b, m, deterministic)
+func (m *RegisterProducerRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterProducerRequest.Marshal(b, m, deterministic)
 }
-func (m *ConsumeRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ConsumeRequest.Merge(m, src)
+func (m *RegisterProducerRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterProducerRequest.Merge(m, src)
 }
-func (m *ConsumeRequest) XXX_Size() int {
-	return xxx_messageInfo_ConsumeRequest.Size(m)
+func (m *RegisterProducerRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterProducerRequest.Size(m)
 }
-func (m *ConsumeRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_ConsumeRequest.DiscardUnknown(m)
+func (m *RegisterProducerRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterProducerRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_ConsumeRequest proto.InternalMessageInfo
+var xxx_messageInfo_RegisterProducerRequest proto.InternalMessageInfo
 
-func (m *ConsumeRequest) GetOffset() uint64 {
+func (m *RegisterProducerRequest) GetTopic() string {
 	if m != nil {
-		return m.Offset
+		return m.Topic
 	}
-	return 0
+	return ""
 }
 
-type ConsumeResponse struct {
-	Record               *LogRecord `protobuf:"bytes,2,opt,name=record,proto3" json:"record,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
-	XXX_unrecognized     []byte     `json:"-"`
-	XXX_sizecache        int32      `json:"-"`
+type RegisterProducerResponse struct {
+	ProducerId           uint64   `protobuf:"varint,1,opt,name=producer_id,json=producerId,proto3" json:"producer_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
-func (m *ConsumeResponse) Reset()         { *m = ConsumeResponse{} }
-func (m *ConsumeResponse) String() string { return proto.CompactTextString(m) }
-func (*ConsumeResponse) ProtoMessage()    {}
-func (*ConsumeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_19a5c3fde3f7ae80, []int{4}
+func (m *RegisterProducerResponse) Reset()         { *m = RegisterProducerResponse{} }
+func (m *RegisterProducerResponse) String() string { return proto.CompactTextString(m) }
+func (*RegisterProducerResponse) ProtoMessage()    {}
+func (*RegisterProducerResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{7}
 }
 
-func (m *ConsumeResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_ConsumeResponse.Unmarshal(m, b)
+func (m *RegisterProducerResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterProducerResponse.Unmarshal(m, b)
 }
-func (m *ConsumeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_ConsumeResponse.Marshal(b, m, deterministic)
+func (m *RegisterProducerResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterProducerResponse.Marshal(b, m, deterministic)
 }
-func (m *ConsumeResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_ConsumeResponse.Merge(m, src)
+func (m *RegisterProducerResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterProducerResponse.Merge(m, src)
 }
-func (m *ConsumeResponse) XXX_Size() int {
-	return xxx_messageInfo_ConsumeResponse.Size(m)
+func (m *RegisterProducerResponse) XXX_Size() int {
+	return xxx_messageInfo_RegisterProducerResponse.Size(m)
 }
-func (m *ConsumeResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_ConsumeResponse.DiscardUnknown(m)
+func (m *RegisterProducerResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterProducerResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_ConsumeResponse proto.InternalMessageInfo
+var xxx_messageInfo_RegisterProducerResponse proto.InternalMessageInfo
 
-func (m *ConsumeResponse) GetRecord() *LogRecord {
+func (m *RegisterProducerResponse) GetProducerId() uint64 {
 	if m != nil {
-		return m.Record
+		return m.ProducerId
 	}
-	return nil
+	return 0
+}
+
+// the records of a batch get consecutive offsets
+type ProduceBatchResponse struct {
+	FirstOffset          uint64   `protobuf:"varint,1,opt,name=first_offset,json=firstOffset,proto3" json:"first_offset,omitempty"`
+	LastOffset           uint64   `protobuf:"varint,2,opt,name=last_offset,json=lastOffset,proto3" json:"last_offset,omitempty"`
+	Partition            uint32   `protobuf:"varint,3,opt,name=partition,proto3" json:"partition,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProduceBatchResponse) Reset()         { *m = ProduceBatchResponse{} }
+func (m *ProduceBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*ProduceBatchResponse) ProtoMessage()    {}
+func (*ProduceBatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{8}
+}
+
+func (m *ProduceBatchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProduceBatchResponse.Unmarshal(m, b)
+}
+func (m *ProduceBatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProduceBatchResponse.Marshal(b, m, deterministic)
+}
+func (m *ProduceBatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProduceBatchResponse.Merge(m, src)
+}
+func (m *ProduceBatchResponse) XXX_Size() int {
+	return xxx_messageInfo_ProduceBatchResponse.Size(m)
+}
+func (m *ProduceBatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProduceBatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProduceBatchResponse proto.InternalMessageInfo
+
+func (m *ProduceBatchResponse) GetFirstOffset() uint64 {
+	if m != nil {
+		return m.FirstOffset
+	}
+	return 0
+}
+
+func (m *ProduceBatchResponse) GetLastOffset() uint64 {
+	if m != nil {
+		return m.LastOffset
+	}
+	return 0
+}
+
+func (m *ProduceBatchResponse) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+type ConsumeRequest struct {
+	Offset uint64 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	// when set, start from the first record with a timestamp at or after it instead of offset (unix nanoseconds)
+	StartTimestamp int64 `protobuf:"varint,2,opt,name=start_timestamp,json=startTimestamp,proto3" json:"start_timestamp,omitempty"`
+	// the default topic when empty
+	Topic         string        `protobuf:"bytes,3,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition     uint32        `protobuf:"varint,4,opt,name=partition,proto3" json:"partition,omitempty"`
+	StartPosition StartPosition `protobuf:"varint,5,opt,name=start_position,json=startPosition,proto3,enum=log.v1.StartPosition" json:"start_position,omitempty"`
+	LatestMinus   uint64        `protobuf:"varint,6,opt,name=latest_minus,json=latestMinus,proto3" json:"latest_minus,omitempty"`
+	// ConsumeStream only sends the records matching this expression over their key, headers and JSON value fields,
+	// e.g. headers.type == "order" && value.total >= 100; all of them when empty
+	Filter               string   `protobuf:"bytes,7,opt,name=filter,proto3" json:"filter,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ConsumeRequest) Reset()         { *m = ConsumeRequest{} }
+func (m *ConsumeRequest) String() string { return proto.CompactTextString(m) }
+func (*ConsumeRequest) ProtoMessage()    {}
+func (*ConsumeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{9}
+}
+
+func (m *ConsumeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ConsumeRequest.Unmarshal(m, b)
+}
+func (m *ConsumeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ConsumeRequest.Marshal(b, m, deterministic)
+}
+func (m *ConsumeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ConsumeRequest.Merge(m, src)
+}
+func (m *ConsumeRequest) XXX_Size() int {
+	return xxx_messageInfo_ConsumeRequest.Size(m)
+}
+func (m *ConsumeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ConsumeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ConsumeRequest proto.InternalMessageInfo
+
+func (m *ConsumeRequest) GetOffset() uint64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *ConsumeRequest) GetStartTimestamp() int64 {
+	if m != nil {
+		return m.StartTimestamp
+	}
+	return 0
+}
+
+func (m *ConsumeRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *ConsumeRequest) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *ConsumeRequest) GetStartPosition() StartPosition {
+	if m != nil {
+		return m.StartPosition
+	}
+	return StartPosition_START_OFFSET
+}
+
+func (m *ConsumeRequest) GetLatestMinus() uint64 {
+	if m != nil {
+		return m.LatestMinus
+	}
+	return 0
+}
+
+func (m *ConsumeRequest) GetFilter() string {
+	if m != nil {
+		return m.Filter
+	}
+	return ""
+}
+
+type ConsumeResponse struct {
+	Record *LogRecord `protobuf:"bytes,2,opt,name=record,proto3" json:"record,omitempty"`
+	// the offset the consumer carries on from, a response without a record reports the progress of a filtered stream
+	// over the records it skipped
+	NextOffset           uint64   `protobuf:"varint,3,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ConsumeResponse) Reset()         { *m = ConsumeResponse{} }
+func (m *ConsumeResponse) String() string { return proto.CompactTextString(m) }
+func (*ConsumeResponse) ProtoMessage()    {}
+func (*ConsumeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{10}
+}
+
+func (m *ConsumeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ConsumeResponse.Unmarshal(m, b)
+}
+func (m *ConsumeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ConsumeResponse.Marshal(b, m, deterministic)
+}
+func (m *ConsumeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ConsumeResponse.Merge(m, src)
+}
+func (m *ConsumeResponse) XXX_Size() int {
+	return xxx_messageInfo_ConsumeResponse.Size(m)
+}
+func (m *ConsumeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ConsumeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ConsumeResponse proto.InternalMessageInfo
+
+func (m *ConsumeResponse) GetRecord() *LogRecord {
+	if m != nil {
+		return m.Record
+	}
+	return nil
+}
+
+func (m *ConsumeResponse) GetNextOffset() uint64 {
+	if m != nil {
+		return m.NextOffset
+	}
+	return 0
+}
+
+type GetOffsetsRequest struct {
+	// the default topic when empty
+	Topic                string   `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition            uint32   `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetOffsetsRequest) Reset()         { *m = GetOffsetsRequest{} }
+func (m *GetOffsetsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetOffsetsRequest) ProtoMessage()    {}
+func (*GetOffsetsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{11}
+}
+
+func (m *GetOffsetsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetOffsetsRequest.Unmarshal(m, b)
+}
+func (m *GetOffsetsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetOffsetsRequest.Marshal(b, m, deterministic)
+}
+func (m *GetOffsetsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetOffsetsRequest.Merge(m, src)
+}
+func (m *GetOffsetsRequest) XXX_Size() int {
+	return xxx_messageInfo_GetOffsetsRequest.Size(m)
+}
+func (m *GetOffsetsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetOffsetsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetOffsetsRequest proto.InternalMessageInfo
+
+func (m *GetOffsetsRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *GetOffsetsRequest) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+// the partition holds the records from the low watermark up to, excluding, the high one
+type GetOffsetsResponse struct {
+	// the offset of the first record
+	LowWatermark uint64 `protobuf:"varint,1,opt,name=low_watermark,json=lowWatermark,proto3" json:"low_watermark,omitempty"`
+	// the offset the next appended record gets
+	HighWatermark        uint64   `protobuf:"varint,2,opt,name=high_watermark,json=highWatermark,proto3" json:"high_watermark,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetOffsetsResponse) Reset()         { *m = GetOffsetsResponse{} }
+func (m *GetOffsetsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetOffsetsResponse) ProtoMessage()    {}
+func (*GetOffsetsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{12}
+}
+
+func (m *GetOffsetsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetOffsetsResponse.Unmarshal(m, b)
+}
+func (m *GetOffsetsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetOffsetsResponse.Marshal(b, m, deterministic)
+}
+func (m *GetOffsetsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetOffsetsResponse.Merge(m, src)
+}
+func (m *GetOffsetsResponse) XXX_Size() int {
+	return xxx_messageInfo_GetOffsetsResponse.Size(m)
+}
+func (m *GetOffsetsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetOffsetsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetOffsetsResponse proto.InternalMessageInfo
+
+func (m *GetOffsetsResponse) GetLowWatermark() uint64 {
+	if m != nil {
+		return m.LowWatermark
+	}
+	return 0
+}
+
+func (m *GetOffsetsResponse) GetHighWatermark() uint64 {
+	if m != nil {
+		return m.HighWatermark
+	}
+	return 0
+}
+
+// a fetch returns the records from offset on, as many as fit in max_bytes, waiting up to max_wait_ms for min_bytes of
+// records to be appended; it returns what the partition holds once the wait is over, possibly nothing
+type FetchRequest struct {
+	// the default topic when empty
+	Topic     string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition uint32 `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Offset    uint64 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	// 1 MiB when 0 and at most 4 MiB less 1 KiB so the response fits the default gRPC message size, the first record is
+	// returned even when it is larger
+	MaxBytes uint64 `protobuf:"varint,4,opt,name=max_bytes,json=maxBytes,proto3" json:"max_bytes,omitempty"`
+	// 0 returns at once
+	MinBytes             uint64   `protobuf:"varint,5,opt,name=min_bytes,json=minBytes,proto3" json:"min_bytes,omitempty"`
+	MaxWaitMs            uint32   `protobuf:"varint,6,opt,name=max_wait_ms,json=maxWaitMs,proto3" json:"max_wait_ms,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FetchRequest) Reset()         { *m = FetchRequest{} }
+func (m *FetchRequest) String() string { return proto.CompactTextString(m) }
+func (*FetchRequest) ProtoMessage()    {}
+func (*FetchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{13}
+}
+
+func (m *FetchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FetchRequest.Unmarshal(m, b)
+}
+func (m *FetchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FetchRequest.Marshal(b, m, deterministic)
+}
+func (m *FetchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchRequest.Merge(m, src)
+}
+func (m *FetchRequest) XXX_Size() int {
+	return xxx_messageInfo_FetchRequest.Size(m)
+}
+func (m *FetchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchRequest proto.InternalMessageInfo
+
+func (m *FetchRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *FetchRequest) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *FetchRequest) GetOffset() uint64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *FetchRequest) GetMaxBytes() uint64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
+func (m *FetchRequest) GetMinBytes() uint64 {
+	if m != nil {
+		return m.MinBytes
+	}
+	return 0
+}
+
+func (m *FetchRequest) GetMaxWaitMs() uint32 {
+	if m != nil {
+		return m.MaxWaitMs
+	}
+	return 0
+}
+
+type FetchResponse struct {
+	Records []*LogRecord `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	// the offset to fetch from next
+	NextOffset           uint64   `protobuf:"varint,2,opt,name=next_offset,json=nextOffset,proto3" json:"next_offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FetchResponse) Reset()         { *m = FetchResponse{} }
+func (m *FetchResponse) String() string { return proto.CompactTextString(m) }
+func (*FetchResponse) ProtoMessage()    {}
+func (*FetchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{14}
+}
+
+func (m *FetchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FetchResponse.Unmarshal(m, b)
+}
+func (m *FetchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FetchResponse.Marshal(b, m, deterministic)
+}
+func (m *FetchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchResponse.Merge(m, src)
+}
+func (m *FetchResponse) XXX_Size() int {
+	return xxx_messageInfo_FetchResponse.Size(m)
+}
+func (m *FetchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchResponse proto.InternalMessageInfo
+
+func (m *FetchResponse) GetRecords() []*LogRecord {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *FetchResponse) GetNextOffset() uint64 {
+	if m != nil {
+		return m.NextOffset
+	}
+	return 0
+}
+
+// topic names are made of letters, digits, '.', '_' and '-', the default topic always exists
+type CreateTopicRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// 1 when 0
+	Partitions           uint32   `protobuf:"varint,2,opt,name=partitions,proto3" json:"partitions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateTopicRequest) Reset()         { *m = CreateTopicRequest{} }
+func (m *CreateTopicRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateTopicRequest) ProtoMessage()    {}
+func (*CreateTopicRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{15}
+}
+
+func (m *CreateTopicRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateTopicRequest.Unmarshal(m, b)
+}
+func (m *CreateTopicRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateTopicRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateTopicRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateTopicRequest.Merge(m, src)
+}
+func (m *CreateTopicRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateTopicRequest.Size(m)
+}
+func (m *CreateTopicRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateTopicRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateTopicRequest proto.InternalMessageInfo
+
+func (m *CreateTopicRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreateTopicRequest) GetPartitions() uint32 {
+	if m != nil {
+		return m.Partitions
+	}
+	return 0
+}
+
+type CreateTopicResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateTopicResponse) Reset()         { *m = CreateTopicResponse{} }
+func (m *CreateTopicResponse) String() string { return proto.CompactTextString(m) }
+func (*CreateTopicResponse) ProtoMessage()    {}
+func (*CreateTopicResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{16}
+}
+
+func (m *CreateTopicResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateTopicResponse.Unmarshal(m, b)
+}
+func (m *CreateTopicResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateTopicResponse.Marshal(b, m, deterministic)
+}
+func (m *CreateTopicResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateTopicResponse.Merge(m, src)
+}
+func (m *CreateTopicResponse) XXX_Size() int {
+	return xxx_messageInfo_CreateTopicResponse.Size(m)
+}
+func (m *CreateTopicResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateTopicResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateTopicResponse proto.InternalMessageInfo
+
+type DeleteTopicRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteTopicRequest) Reset()         { *m = DeleteTopicRequest{} }
+func (m *DeleteTopicRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteTopicRequest) ProtoMessage()    {}
+func (*DeleteTopicRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{17}
+}
+
+func (m *DeleteTopicRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteTopicRequest.Unmarshal(m, b)
+}
+func (m *DeleteTopicRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteTopicRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteTopicRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteTopicRequest.Merge(m, src)
+}
+func (m *DeleteTopicRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteTopicRequest.Size(m)
+}
+func (m *DeleteTopicRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteTopicRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteTopicRequest proto.InternalMessageInfo
+
+func (m *DeleteTopicRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type DeleteTopicResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteTopicResponse) Reset()         { *m = DeleteTopicResponse{} }
+func (m *DeleteTopicResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteTopicResponse) ProtoMessage()    {}
+func (*DeleteTopicResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{18}
+}
+
+func (m *DeleteTopicResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteTopicResponse.Unmarshal(m, b)
+}
+func (m *DeleteTopicResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteTopicResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteTopicResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteTopicResponse.Merge(m, src)
+}
+func (m *DeleteTopicResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteTopicResponse.Size(m)
+}
+func (m *DeleteTopicResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteTopicResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteTopicResponse proto.InternalMessageInfo
+
+// the partition count can only be increased, keys hashed to a partition may go to another one afterwards
+type AddPartitionsRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// the new partition count of the topic
+	Partitions           uint32   `protobuf:"varint,2,opt,name=partitions,proto3" json:"partitions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddPartitionsRequest) Reset()         { *m = AddPartitionsRequest{} }
+func (m *AddPartitionsRequest) String() string { return proto.CompactTextString(m) }
+func (*AddPartitionsRequest) ProtoMessage()    {}
+func (*AddPartitionsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{19}
+}
+
+func (m *AddPartitionsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddPartitionsRequest.Unmarshal(m, b)
+}
+func (m *AddPartitionsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddPartitionsRequest.Marshal(b, m, deterministic)
+}
+func (m *AddPartitionsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddPartitionsRequest.Merge(m, src)
+}
+func (m *AddPartitionsRequest) XXX_Size() int {
+	return xxx_messageInfo_AddPartitionsRequest.Size(m)
+}
+func (m *AddPartitionsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddPartitionsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddPartitionsRequest proto.InternalMessageInfo
+
+func (m *AddPartitionsRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AddPartitionsRequest) GetPartitions() uint32 {
+	if m != nil {
+		return m.Partitions
+	}
+	return 0
+}
+
+type AddPartitionsResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddPartitionsResponse) Reset()         { *m = AddPartitionsResponse{} }
+func (m *AddPartitionsResponse) String() string { return proto.CompactTextString(m) }
+func (*AddPartitionsResponse) ProtoMessage()    {}
+func (*AddPartitionsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{20}
+}
+
+func (m *AddPartitionsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddPartitionsResponse.Unmarshal(m, b)
+}
+func (m *AddPartitionsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddPartitionsResponse.Marshal(b, m, deterministic)
+}
+func (m *AddPartitionsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddPartitionsResponse.Merge(m, src)
+}
+func (m *AddPartitionsResponse) XXX_Size() int {
+	return xxx_messageInfo_AddPartitionsResponse.Size(m)
+}
+func (m *AddPartitionsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddPartitionsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddPartitionsResponse proto.InternalMessageInfo
+
+type ListTopicsRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListTopicsRequest) Reset()         { *m = ListTopicsRequest{} }
+func (m *ListTopicsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListTopicsRequest) ProtoMessage()    {}
+func (*ListTopicsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{21}
+}
+
+func (m *ListTopicsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListTopicsRequest.Unmarshal(m, b)
+}
+func (m *ListTopicsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListTopicsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListTopicsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListTopicsRequest.Merge(m, src)
+}
+func (m *ListTopicsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListTopicsRequest.Size(m)
+}
+func (m *ListTopicsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListTopicsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListTopicsRequest proto.InternalMessageInfo
+
+type Topic struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Partitions           uint32   `protobuf:"varint,2,opt,name=partitions,proto3" json:"partitions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Topic) Reset()         { *m = Topic{} }
+func (m *Topic) String() string { return proto.CompactTextString(m) }
+func (*Topic) ProtoMessage()    {}
+func (*Topic) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{22}
+}
+
+func (m *Topic) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Topic.Unmarshal(m, b)
+}
+func (m *Topic) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Topic.Marshal(b, m, deterministic)
+}
+func (m *Topic) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Topic.Merge(m, src)
+}
+func (m *Topic) XXX_Size() int {
+	return xxx_messageInfo_Topic.Size(m)
+}
+func (m *Topic) XXX_DiscardUnknown() {
+	xxx_messageInfo_Topic.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Topic proto.InternalMessageInfo
+
+func (m *Topic) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Topic) GetPartitions() uint32 {
+	if m != nil {
+		return m.Partitions
+	}
+	return 0
+}
+
+type ListTopicsResponse struct {
+	Topics               []*Topic `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListTopicsResponse) Reset()         { *m = ListTopicsResponse{} }
+func (m *ListTopicsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListTopicsResponse) ProtoMessage()    {}
+func (*ListTopicsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{23}
+}
+
+func (m *ListTopicsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListTopicsResponse.Unmarshal(m, b)
+}
+func (m *ListTopicsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListTopicsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListTopicsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListTopicsResponse.Merge(m, src)
+}
+func (m *ListTopicsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListTopicsResponse.Size(m)
+}
+func (m *ListTopicsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListTopicsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListTopicsResponse proto.InternalMessageInfo
+
+func (m *ListTopicsResponse) GetTopics() []*Topic {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+// consumer groups spread the partitions of a topic across their members, every partition is consumed by a single
+// member; a member keeps its partitions by sending heartbeats, it is removed from the group once it hasn't for the
+// session timeout of the server
+// every join or leave starts a new generation of the group with new assignments, members learn about it from the
+// response to their heartbeat
+type JoinGroupRequest struct {
+	Group string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	// the default topic when empty, all the members of a group consume the same topic
+	Topic string `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	// empty, or unknown to the group, joins as a new member
+	MemberId             string   `protobuf:"bytes,3,opt,name=member_id,json=memberId,proto3" json:"member_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *JoinGroupRequest) Reset()         { *m = JoinGroupRequest{} }
+func (m *JoinGroupRequest) String() string { return proto.CompactTextString(m) }
+func (*JoinGroupRequest) ProtoMessage()    {}
+func (*JoinGroupRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{24}
+}
+
+func (m *JoinGroupRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JoinGroupRequest.Unmarshal(m, b)
+}
+func (m *JoinGroupRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JoinGroupRequest.Marshal(b, m, deterministic)
+}
+func (m *JoinGroupRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JoinGroupRequest.Merge(m, src)
+}
+func (m *JoinGroupRequest) XXX_Size() int {
+	return xxx_messageInfo_JoinGroupRequest.Size(m)
+}
+func (m *JoinGroupRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_JoinGroupRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JoinGroupRequest proto.InternalMessageInfo
+
+func (m *JoinGroupRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *JoinGroupRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *JoinGroupRequest) GetMemberId() string {
+	if m != nil {
+		return m.MemberId
+	}
+	return ""
+}
+
+type JoinGroupResponse struct {
+	MemberId   string `protobuf:"bytes,1,opt,name=member_id,json=memberId,proto3" json:"member_id,omitempty"`
+	Generation uint64 `protobuf:"varint,2,opt,name=generation,proto3" json:"generation,omitempty"`
+	// the partitions assigned to the member
+	Partitions           []uint32 `protobuf:"varint,3,rep,packed,name=partitions,proto3" json:"partitions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *JoinGroupResponse) Reset()         { *m = JoinGroupResponse{} }
+func (m *JoinGroupResponse) String() string { return proto.CompactTextString(m) }
+func (*JoinGroupResponse) ProtoMessage()    {}
+func (*JoinGroupResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{25}
+}
+
+func (m *JoinGroupResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_JoinGroupResponse.Unmarshal(m, b)
+}
+func (m *JoinGroupResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_JoinGroupResponse.Marshal(b, m, deterministic)
+}
+func (m *JoinGroupResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_JoinGroupResponse.Merge(m, src)
+}
+func (m *JoinGroupResponse) XXX_Size() int {
+	return xxx_messageInfo_JoinGroupResponse.Size(m)
+}
+func (m *JoinGroupResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_JoinGroupResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_JoinGroupResponse proto.InternalMessageInfo
+
+func (m *JoinGroupResponse) GetMemberId() string {
+	if m != nil {
+		return m.MemberId
+	}
+	return ""
+}
+
+func (m *JoinGroupResponse) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+func (m *JoinGroupResponse) GetPartitions() []uint32 {
+	if m != nil {
+		return m.Partitions
+	}
+	return nil
+}
+
+type HeartbeatRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	MemberId             string   `protobuf:"bytes,2,opt,name=member_id,json=memberId,proto3" json:"member_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatRequest) Reset()         { *m = HeartbeatRequest{} }
+func (m *HeartbeatRequest) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatRequest) ProtoMessage()    {}
+func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{26}
+}
+
+func (m *HeartbeatRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatRequest.Unmarshal(m, b)
+}
+func (m *HeartbeatRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatRequest.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatRequest.Merge(m, src)
+}
+func (m *HeartbeatRequest) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatRequest.Size(m)
+}
+func (m *HeartbeatRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatRequest proto.InternalMessageInfo
+
+func (m *HeartbeatRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *HeartbeatRequest) GetMemberId() string {
+	if m != nil {
+		return m.MemberId
+	}
+	return ""
+}
+
+// the assignment of the member may have changed when the generation did
+type HeartbeatResponse struct {
+	Generation           uint64   `protobuf:"varint,1,opt,name=generation,proto3" json:"generation,omitempty"`
+	Partitions           []uint32 `protobuf:"varint,2,rep,packed,name=partitions,proto3" json:"partitions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HeartbeatResponse) Reset()         { *m = HeartbeatResponse{} }
+func (m *HeartbeatResponse) String() string { return proto.CompactTextString(m) }
+func (*HeartbeatResponse) ProtoMessage()    {}
+func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{27}
+}
+
+func (m *HeartbeatResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HeartbeatResponse.Unmarshal(m, b)
+}
+func (m *HeartbeatResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HeartbeatResponse.Marshal(b, m, deterministic)
+}
+func (m *HeartbeatResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HeartbeatResponse.Merge(m, src)
+}
+func (m *HeartbeatResponse) XXX_Size() int {
+	return xxx_messageInfo_HeartbeatResponse.Size(m)
+}
+func (m *HeartbeatResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_HeartbeatResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HeartbeatResponse proto.InternalMessageInfo
+
+func (m *HeartbeatResponse) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+func (m *HeartbeatResponse) GetPartitions() []uint32 {
+	if m != nil {
+		return m.Partitions
+	}
+	return nil
+}
+
+type LeaveGroupRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	MemberId             string   `protobuf:"bytes,2,opt,name=member_id,json=memberId,proto3" json:"member_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LeaveGroupRequest) Reset()         { *m = LeaveGroupRequest{} }
+func (m *LeaveGroupRequest) String() string { return proto.CompactTextString(m) }
+func (*LeaveGroupRequest) ProtoMessage()    {}
+func (*LeaveGroupRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{28}
+}
+
+func (m *LeaveGroupRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LeaveGroupRequest.Unmarshal(m, b)
+}
+func (m *LeaveGroupRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LeaveGroupRequest.Marshal(b, m, deterministic)
+}
+func (m *LeaveGroupRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LeaveGroupRequest.Merge(m, src)
+}
+func (m *LeaveGroupRequest) XXX_Size() int {
+	return xxx_messageInfo_LeaveGroupRequest.Size(m)
+}
+func (m *LeaveGroupRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LeaveGroupRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LeaveGroupRequest proto.InternalMessageInfo
+
+func (m *LeaveGroupRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *LeaveGroupRequest) GetMemberId() string {
+	if m != nil {
+		return m.MemberId
+	}
+	return ""
+}
+
+type LeaveGroupResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LeaveGroupResponse) Reset()         { *m = LeaveGroupResponse{} }
+func (m *LeaveGroupResponse) String() string { return proto.CompactTextString(m) }
+func (*LeaveGroupResponse) ProtoMessage()    {}
+func (*LeaveGroupResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{29}
+}
+
+func (m *LeaveGroupResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LeaveGroupResponse.Unmarshal(m, b)
+}
+func (m *LeaveGroupResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LeaveGroupResponse.Marshal(b, m, deterministic)
+}
+func (m *LeaveGroupResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LeaveGroupResponse.Merge(m, src)
+}
+func (m *LeaveGroupResponse) XXX_Size() int {
+	return xxx_messageInfo_LeaveGroupResponse.Size(m)
+}
+func (m *LeaveGroupResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LeaveGroupResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LeaveGroupResponse proto.InternalMessageInfo
+
+// the committed offset is the one of the next record the group consumes from the partition
+type CommitOffsetRequest struct {
+	Group string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	// the default topic when empty
+	Topic     string `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition uint32 `protobuf:"varint,3,opt,name=partition,proto3" json:"partition,omitempty"`
+	Offset    uint64 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	// when set, the commit fails unless the member is assigned the partition in this generation of the group
+	// when empty, the commit fails unless the group has no live members
+	MemberId             string   `protobuf:"bytes,5,opt,name=member_id,json=memberId,proto3" json:"member_id,omitempty"`
+	Generation           uint64   `protobuf:"varint,6,opt,name=generation,proto3" json:"generation,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommitOffsetRequest) Reset()         { *m = CommitOffsetRequest{} }
+func (m *CommitOffsetRequest) String() string { return proto.CompactTextString(m) }
+func (*CommitOffsetRequest) ProtoMessage()    {}
+func (*CommitOffsetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{30}
+}
+
+func (m *CommitOffsetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CommitOffsetRequest.Unmarshal(m, b)
+}
+func (m *CommitOffsetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CommitOffsetRequest.Marshal(b, m, deterministic)
+}
+func (m *CommitOffsetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommitOffsetRequest.Merge(m, src)
+}
+func (m *CommitOffsetRequest) XXX_Size() int {
+	return xxx_messageInfo_CommitOffsetRequest.Size(m)
+}
+func (m *CommitOffsetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommitOffsetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommitOffsetRequest proto.InternalMessageInfo
+
+func (m *CommitOffsetRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *CommitOffsetRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *CommitOffsetRequest) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *CommitOffsetRequest) GetOffset() uint64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *CommitOffsetRequest) GetMemberId() string {
+	if m != nil {
+		return m.MemberId
+	}
+	return ""
+}
+
+func (m *CommitOffsetRequest) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+type CommitOffsetResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CommitOffsetResponse) Reset()         { *m = CommitOffsetResponse{} }
+func (m *CommitOffsetResponse) String() string { return proto.CompactTextString(m) }
+func (*CommitOffsetResponse) ProtoMessage()    {}
+func (*CommitOffsetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{31}
+}
+
+func (m *CommitOffsetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CommitOffsetResponse.Unmarshal(m, b)
+}
+func (m *CommitOffsetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CommitOffsetResponse.Marshal(b, m, deterministic)
+}
+func (m *CommitOffsetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CommitOffsetResponse.Merge(m, src)
+}
+func (m *CommitOffsetResponse) XXX_Size() int {
+	return xxx_messageInfo_CommitOffsetResponse.Size(m)
+}
+func (m *CommitOffsetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CommitOffsetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CommitOffsetResponse proto.InternalMessageInfo
+
+type FetchOffsetRequest struct {
+	Group string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	// the default topic when empty
+	Topic                string   `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition            uint32   `protobuf:"varint,3,opt,name=partition,proto3" json:"partition,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FetchOffsetRequest) Reset()         { *m = FetchOffsetRequest{} }
+func (m *FetchOffsetRequest) String() string { return proto.CompactTextString(m) }
+func (*FetchOffsetRequest) ProtoMessage()    {}
+func (*FetchOffsetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{32}
+}
+
+func (m *FetchOffsetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FetchOffsetRequest.Unmarshal(m, b)
+}
+func (m *FetchOffsetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FetchOffsetRequest.Marshal(b, m, deterministic)
+}
+func (m *FetchOffsetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchOffsetRequest.Merge(m, src)
+}
+func (m *FetchOffsetRequest) XXX_Size() int {
+	return xxx_messageInfo_FetchOffsetRequest.Size(m)
+}
+func (m *FetchOffsetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchOffsetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchOffsetRequest proto.InternalMessageInfo
+
+func (m *FetchOffsetRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *FetchOffsetRequest) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *FetchOffsetRequest) GetPartition() uint32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+type FetchOffsetResponse struct {
+	Offset               uint64   `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FetchOffsetResponse) Reset()         { *m = FetchOffsetResponse{} }
+func (m *FetchOffsetResponse) String() string { return proto.CompactTextString(m) }
+func (*FetchOffsetResponse) ProtoMessage()    {}
+func (*FetchOffsetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_19a5c3fde3f7ae80, []int{33}
+}
+
+func (m *FetchOffsetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FetchOffsetResponse.Unmarshal(m, b)
+}
+func (m *FetchOffsetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FetchOffsetResponse.Marshal(b, m, deterministic)
+}
+func (m *FetchOffsetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FetchOffsetResponse.Merge(m, src)
+}
+func (m *FetchOffsetResponse) XXX_Size() int {
+	return xxx_messageInfo_FetchOffsetResponse.Size(m)
+}
+func (m *FetchOffsetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_FetchOffsetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FetchOffsetResponse proto.InternalMessageInfo
+
+func (m *FetchOffsetResponse) GetOffset() uint64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
 }
 
 func init() {
+	proto.RegisterEnum("log.v1.Compression", Compression_name, Compression_value)
+	proto.RegisterEnum("log.v1.Partitioner", Partitioner_name, Partitioner_value)
+	proto.RegisterEnum("log.v1.StartPosition", StartPosition_name, StartPosition_value)
 	proto.RegisterType((*LogRecord)(nil), "log.v1.LogRecord")
+	proto.RegisterType((*Header)(nil), "log.v1.Header")
 	proto.RegisterType((*ProduceRequest)(nil), "log.v1.ProduceRequest")
+	proto.RegisterType((*ExpectedOffset)(nil), "log.v1.ExpectedOffset")
 	proto.RegisterType((*ProduceResponse)(nil), "log.v1.ProduceResponse")
+	proto.RegisterType((*ProduceBatchRequest)(nil), "log.v1.ProduceBatchRequest")
+	proto.RegisterType((*RegisterProducerRequest)(nil), "log.v1.RegisterProducerRequest")
+	proto.RegisterType((*RegisterProducerResponse)(nil), "log.v1.RegisterProducerResponse")
+	proto.RegisterType((*ProduceBatchResponse)(nil), "log.v1.ProduceBatchResponse")
 	proto.RegisterType((*ConsumeRequest)(nil), "log.v1.ConsumeRequest")
 	proto.RegisterType((*ConsumeResponse)(nil), "log.v1.ConsumeResponse")
+	proto.RegisterType((*GetOffsetsRequest)(nil), "log.v1.GetOffsetsRequest")
+	proto.RegisterType((*GetOffsetsResponse)(nil), "log.v1.GetOffsetsResponse")
+	proto.RegisterType((*FetchRequest)(nil), "log.v1.FetchRequest")
+	proto.RegisterType((*FetchResponse)(nil), "log.v1.FetchResponse")
+	proto.RegisterType((*CreateTopicRequest)(nil), "log.v1.CreateTopicRequest")
+	proto.RegisterType((*CreateTopicResponse)(nil), "log.v1.CreateTopicResponse")
+	proto.RegisterType((*DeleteTopicRequest)(nil), "log.v1.DeleteTopicRequest")
+	proto.RegisterType((*DeleteTopicResponse)(nil), "log.v1.DeleteTopicResponse")
+	proto.RegisterType((*AddPartitionsRequest)(nil), "log.v1.AddPartitionsRequest")
+	proto.RegisterType((*AddPartitionsResponse)(nil), "log.v1.AddPartitionsResponse")
+	proto.RegisterType((*ListTopicsRequest)(nil), "log.v1.ListTopicsRequest")
+	proto.RegisterType((*Topic)(nil), "log.v1.Topic")
+	proto.RegisterType((*ListTopicsResponse)(nil), "log.v1.ListTopicsResponse")
+	proto.RegisterType((*JoinGroupRequest)(nil), "log.v1.JoinGroupRequest")
+	proto.RegisterType((*JoinGroupResponse)(nil), "log.v1.JoinGroupResponse")
+	proto.RegisterType((*HeartbeatRequest)(nil), "log.v1.HeartbeatRequest")
+	proto.RegisterType((*HeartbeatResponse)(nil), "log.v1.HeartbeatResponse")
+	proto.RegisterType((*LeaveGroupRequest)(nil), "log.v1.LeaveGroupRequest")
+	proto.RegisterType((*LeaveGroupResponse)(nil), "log.v1.LeaveGroupResponse")
+	proto.RegisterType((*CommitOffsetRequest)(nil), "log.v1.CommitOffsetRequest")
+	proto.RegisterType((*CommitOffsetResponse)(nil), "log.v1.CommitOffsetResponse")
+	proto.RegisterType((*FetchOffsetRequest)(nil), "log.v1.FetchOffsetRequest")
+	proto.RegisterType((*FetchOffsetResponse)(nil), "log.v1.FetchOffsetResponse")
 }
 
 func init() { proto.RegisterFile("api/v1/log.proto", fileDescriptor_19a5c3fde3f7ae80) }
 
 var fileDescriptor_19a5c3fde3f7ae80 = []byte{
-	// 271 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0x12, 0x48, 0x2c, 0xc8, 0xd4,
-	0x2f, 0x33, 0xd4, 0xcf, 0xc9, 0x4f, 0xd7, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x03, 0x31,
-	0xcb, 0x0c, 0x95, 0x2c, 0xb9, 0x38, 0x7d, 0xf2, 0xd3, 0x83, 0x52, 0x93, 0xf3, 0x8b, 0x52, 0x84,
-	0x44, 0xb8, 0x58, 0xcb, 0x12, 0x73, 0x4a, 0x53, 0x25, 0x18, 0x15, 0x18, 0x35, 0x78, 0x82, 0x20,
-	0x1c, 0x21, 0x31, 0x2e, 0xb6, 0xfc, 0xb4, 0xb4, 0xe2, 0xd4, 0x12, 0x09, 0x26, 0x05, 0x46, 0x0d,
-	0x96, 0x20, 0x28, 0x4f, 0xc9, 0x9a, 0x8b, 0x2f, 0xa0, 0x28, 0x3f, 0xa5, 0x34, 0x39, 0x35, 0x28,
-	0xb5, 0xb0, 0x34, 0xb5, 0xb8, 0x44, 0x48, 0x93, 0x8b, 0xad, 0x08, 0x6c, 0x12, 0xd8, 0x00, 0x6e,
-	0x23, 0x41, 0x3d, 0x88, 0x2d, 0x7a, 0x70, 0x2b, 0x82, 0xa0, 0x0a, 0x94, 0x34, 0xb9, 0xf8, 0xe1,
-	0x9a, 0x8b, 0x0b, 0xf2, 0xf3, 0x8a, 0x91, 0xed, 0x61, 0x44, 0xb1, 0x47, 0x83, 0x8b, 0xcf, 0x39,
-	0x3f, 0xaf, 0xb8, 0x34, 0x17, 0x6e, 0x0f, 0x2e, 0x95, 0x36, 0x5c, 0xfc, 0x70, 0x95, 0x50, 0x43,
-	0x11, 0x4e, 0x62, 0x22, 0xe0, 0x24, 0xa3, 0x7e, 0x26, 0x2e, 0x66, 0x9f, 0xfc, 0x74, 0x21, 0x1b,
-	0x2e, 0x76, 0xa8, 0xd3, 0x84, 0xc4, 0x60, 0xaa, 0x51, 0x3d, 0x2a, 0x25, 0x8e, 0x21, 0x0e, 0xb1,
-	0x4e, 0x89, 0x01, 0xa4, 0x1b, 0xea, 0x06, 0x84, 0x6e, 0x54, 0xe7, 0x23, 0x74, 0xa3, 0x39, 0x56,
-	0x89, 0x41, 0xc8, 0x85, 0x8b, 0x17, 0x2a, 0x18, 0x5c, 0x52, 0x94, 0x9a, 0x98, 0x4b, 0x86, 0x19,
-	0x06, 0x8c, 0x42, 0x6e, 0x5c, 0xbc, 0x50, 0x87, 0xa1, 0x9b, 0x42, 0xb4, 0x3f, 0x34, 0x18, 0x0d,
-	0x18, 0x9d, 0x78, 0xa2, 0xb8, 0x20, 0x09, 0xc7, 0x3a, 0xb1, 0x20, 0x33, 0x89, 0x0d, 0x9c, 0x72,
-	0x8c, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0xf3, 0x12, 0x98, 0xd5, 0x4d, 0x02, 0x00, 0x00,
+	// 1497 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xec, 0x58, 0xdb, 0x6e, 0xdb, 0x46,
+	0x10, 0x0d, 0x49, 0x49, 0xb6, 0x46, 0x17, 0xd3, 0x2b, 0x5f, 0x14, 0x3a, 0x4d, 0x54, 0x16, 0x41,
+	0xd5, 0x04, 0xcd, 0xc5, 0x45, 0xfb, 0xe2, 0x00, 0x85, 0x6c, 0xcb, 0xb6, 0x12, 0xd9, 0x12, 0x48,
+	0x06, 0x69, 0x53, 0xa4, 0x2a, 0x2d, 0xad, 0x65, 0x22, 0xa2, 0xa8, 0x92, 0xeb, 0x4b, 0xfe, 0x2b,
+	0xbf, 0xd0, 0xaf, 0xe8, 0x53, 0xfb, 0x21, 0x45, 0x41, 0x72, 0xb9, 0x5c, 0x52, 0xb4, 0x9d, 0xba,
+	0xe8, 0x5b, 0xdf, 0xb4, 0x33, 0xb3, 0x67, 0xcf, 0x5c, 0x38, 0x33, 0x36, 0xc8, 0xe6, 0xcc, 0x7a,
+	0x7a, 0xfe, 0xfc, 0xe9, 0xc4, 0x19, 0x3f, 0x99, 0xb9, 0x0e, 0x71, 0x50, 0xc1, 0xff, 0x79, 0xfe,
+	0x5c, 0xfd, 0x43, 0x80, 0x62, 0xd7, 0x19, 0x6b, 0x78, 0xe8, 0xb8, 0x23, 0xb4, 0x02, 0xf9, 0x73,
+	0x73, 0x72, 0x86, 0xeb, 0x42, 0x43, 0x68, 0x96, 0xb5, 0xf0, 0x80, 0xd6, 0xa0, 0xe0, 0x9c, 0x9c,
+	0x78, 0x98, 0xd4, 0xc5, 0x86, 0xd0, 0xcc, 0x69, 0xf4, 0x84, 0xee, 0x41, 0x91, 0x58, 0x36, 0xf6,
+	0x88, 0x69, 0xcf, 0xea, 0x52, 0x43, 0x68, 0x4a, 0x5a, 0x2c, 0x40, 0x32, 0x48, 0xef, 0xf1, 0x87,
+	0x7a, 0x2e, 0x40, 0xf2, 0x7f, 0xa2, 0x26, 0x2c, 0x9c, 0x62, 0x73, 0x84, 0x5d, 0xaf, 0x9e, 0x6f,
+	0x48, 0xcd, 0xd2, 0x66, 0xf5, 0x49, 0xc8, 0xe2, 0xc9, 0x41, 0x20, 0xd6, 0x22, 0x35, 0x7a, 0x00,
+	0xa5, 0x99, 0xeb, 0x8c, 0xce, 0x86, 0xd8, 0x1d, 0x58, 0xa3, 0x7a, 0x21, 0x78, 0x16, 0x22, 0x51,
+	0x67, 0x84, 0x1e, 0xc3, 0x32, 0x33, 0xf0, 0xf0, 0xaf, 0x67, 0x78, 0x3a, 0xc4, 0xf5, 0x85, 0xc0,
+	0x4c, 0x8e, 0x14, 0x3a, 0x95, 0xab, 0xcf, 0xa0, 0x10, 0x3e, 0x10, 0x71, 0xf2, 0xbd, 0x2b, 0x86,
+	0x9c, 0x98, 0xc7, 0x22, 0xe7, 0xb1, 0xfa, 0xbb, 0x08, 0xd5, 0x7e, 0x08, 0xa3, 0xf9, 0x28, 0x1e,
+	0x41, 0x5f, 0x41, 0xc1, 0x0d, 0x82, 0x14, 0xdc, 0x2e, 0x6d, 0x2e, 0x47, 0xdc, 0x59, 0xf4, 0x34,
+	0x6a, 0x80, 0xbe, 0x85, 0xd2, 0xd0, 0xb1, 0x67, 0x2e, 0xf6, 0x3c, 0xcb, 0x99, 0x06, 0xc8, 0xd5,
+	0xcd, 0x5a, 0x64, 0xbf, 0x13, 0xab, 0x34, 0xde, 0x2e, 0xed, 0xb4, 0x34, 0xe7, 0xb4, 0x02, 0x8b,
+	0xcc, 0xd7, 0x5c, 0xa0, 0x65, 0x67, 0xf4, 0x3d, 0x2c, 0xe1, 0xcb, 0x19, 0x1e, 0x12, 0x3c, 0x1a,
+	0xd0, 0x64, 0xe5, 0x03, 0x9e, 0x6b, 0xd1, 0xbb, 0x6d, 0xaa, 0xee, 0x05, 0x5a, 0xad, 0x8a, 0x13,
+	0x67, 0x3f, 0x10, 0xc4, 0x99, 0x59, 0xc3, 0x20, 0xd8, 0x45, 0x2d, 0x3c, 0xf8, 0xae, 0xcc, 0x4c,
+	0x97, 0x58, 0xc4, 0x72, 0xa6, 0xd8, 0xad, 0x2f, 0x24, 0x5d, 0xe9, 0xc7, 0x2a, 0x8d, 0xb7, 0xf3,
+	0x2b, 0x83, 0x1d, 0xeb, 0x8b, 0x0d, 0xa1, 0x59, 0xd1, 0x62, 0x81, 0xfa, 0x1c, 0xaa, 0x49, 0x32,
+	0xbe, 0xeb, 0x53, 0x7c, 0x49, 0x22, 0xe6, 0x42, 0xe8, 0xba, 0x2f, 0x0a, 0x0d, 0xd4, 0x7d, 0x58,
+	0x62, 0xf9, 0xf0, 0x66, 0xce, 0xd4, 0xe3, 0xab, 0x52, 0x48, 0x57, 0x65, 0xfc, 0xb6, 0x98, 0x7e,
+	0xfb, 0x4f, 0x11, 0x6a, 0x14, 0x69, 0xdb, 0x24, 0xc3, 0xd3, 0x28, 0xbd, 0x8f, 0x61, 0x21, 0xcc,
+	0x9e, 0x57, 0x17, 0x1a, 0x52, 0x76, 0x7e, 0x23, 0x8b, 0xff, 0x13, 0x7c, 0x43, 0x82, 0x9f, 0xc2,
+	0xba, 0x86, 0xc7, 0x96, 0x47, 0xb0, 0x4b, 0x63, 0xed, 0x46, 0x71, 0x66, 0x2c, 0x04, 0x8e, 0x85,
+	0xba, 0x05, 0xf5, 0xf9, 0x0b, 0x34, 0xcf, 0xa9, 0xa8, 0x09, 0xe9, 0xa8, 0xa9, 0x97, 0xb0, 0x92,
+	0xcc, 0x28, 0xbd, 0xf8, 0x39, 0x94, 0x4f, 0x2c, 0xd7, 0x4b, 0x55, 0x55, 0x29, 0x90, 0xc5, 0x75,
+	0x37, 0x31, 0x63, 0x8b, 0xb0, 0xbd, 0xc1, 0xc4, 0x64, 0x06, 0x09, 0x3f, 0xa5, 0xb4, 0x9f, 0x7f,
+	0x09, 0x50, 0xdd, 0x71, 0xa6, 0xde, 0x99, 0xcd, 0xda, 0xc4, 0x55, 0x55, 0xf9, 0x25, 0x2c, 0x79,
+	0xc4, 0x74, 0xc9, 0x20, 0xee, 0x98, 0x62, 0xd0, 0x31, 0xab, 0x81, 0xd8, 0x88, 0xa4, 0x71, 0x80,
+	0x24, 0x3e, 0x4d, 0x09, 0x1e, 0xb9, 0x14, 0x0f, 0xf4, 0x02, 0x42, 0x94, 0xc1, 0xcc, 0xf1, 0x42,
+	0x93, 0x7c, 0x90, 0xc7, 0xd5, 0x28, 0x8f, 0xba, 0xaf, 0xed, 0x53, 0xa5, 0x56, 0xf1, 0xf8, 0xa3,
+	0x1f, 0xa7, 0x89, 0x49, 0xb0, 0x47, 0x06, 0xb6, 0x35, 0x3d, 0xf3, 0x68, 0xb7, 0x2d, 0x85, 0xb2,
+	0x43, 0x5f, 0xe4, 0x7b, 0x75, 0x62, 0x4d, 0x08, 0x2d, 0x90, 0xa2, 0x46, 0x4f, 0xea, 0x3b, 0x58,
+	0x62, 0xfe, 0xd3, 0xa8, 0xc7, 0x7d, 0x52, 0xbc, 0xa9, 0x4f, 0xa6, 0xbe, 0x7a, 0x29, 0xe3, 0xab,
+	0x5f, 0xde, 0xc7, 0xf4, 0xe0, 0x5d, 0x5b, 0x41, 0x37, 0x7c, 0xf5, 0xbf, 0x00, 0xe2, 0x81, 0x28,
+	0xd5, 0x2f, 0xa0, 0x32, 0x71, 0x2e, 0x06, 0x17, 0x26, 0xc1, 0xae, 0x6d, 0xba, 0xef, 0x69, 0xca,
+	0xca, 0x13, 0xe7, 0xe2, 0x4d, 0x24, 0x43, 0x0f, 0xa1, 0x7a, 0x6a, 0x8d, 0x4f, 0x39, 0xab, 0xb0,
+	0x4a, 0x2a, 0xbe, 0x94, 0x99, 0xa9, 0x1f, 0x05, 0x28, 0xef, 0x61, 0xae, 0xa1, 0xdc, 0x82, 0x26,
+	0x57, 0x3c, 0x52, 0xa2, 0x78, 0x36, 0xa0, 0x68, 0x9b, 0x97, 0x83, 0xe3, 0x0f, 0x04, 0x7b, 0x51,
+	0x63, 0xb0, 0xcd, 0xcb, 0x6d, 0xff, 0x1c, 0x28, 0xad, 0x29, 0x55, 0xe6, 0xa9, 0xd2, 0x9a, 0x86,
+	0xca, 0xfb, 0x50, 0xf2, 0x6f, 0x5e, 0x98, 0x16, 0x19, 0xd8, 0x61, 0x6a, 0x2b, 0x9a, 0x0f, 0xf6,
+	0xc6, 0xb4, 0xc8, 0xa1, 0xa7, 0xbe, 0x83, 0x0a, 0x65, 0x4d, 0x63, 0xf2, 0x8f, 0xfa, 0x60, 0x2a,
+	0x81, 0xe2, 0x5c, 0x02, 0x0f, 0x00, 0xed, 0xb8, 0xd8, 0x24, 0xd8, 0xf0, 0xbd, 0x8f, 0x42, 0x83,
+	0x20, 0x37, 0x35, 0x6d, 0x4c, 0x23, 0x13, 0xfc, 0x46, 0xf7, 0x01, 0x58, 0x1c, 0x3c, 0x1a, 0x19,
+	0x4e, 0xa2, 0xae, 0x42, 0x2d, 0x81, 0x14, 0xd2, 0x55, 0x9b, 0x80, 0x76, 0xf1, 0x04, 0xdf, 0xfc,
+	0x80, 0x0f, 0x90, 0xb0, 0xa4, 0x00, 0x2f, 0x61, 0xa5, 0x35, 0x1a, 0xb1, 0x3e, 0xe7, 0xfd, 0x1b,
+	0x8e, 0xeb, 0xb0, 0x9a, 0xc2, 0xa2, 0x8f, 0xd4, 0x60, 0xb9, 0x6b, 0x79, 0x24, 0x78, 0x39, 0x7a,
+	0x41, 0xdd, 0x82, 0x7c, 0x20, 0xb8, 0xd5, 0x53, 0x5b, 0x80, 0x78, 0x44, 0x9a, 0xbc, 0x87, 0x50,
+	0x08, 0xca, 0x2c, 0xca, 0x5d, 0x25, 0xca, 0x5d, 0xe8, 0x33, 0x55, 0xaa, 0x3f, 0x81, 0xfc, 0xd2,
+	0xb1, 0xa6, 0xfb, 0xae, 0x73, 0x36, 0xe3, 0xca, 0x75, 0xec, 0x9f, 0xa3, 0x72, 0x0d, 0x0e, 0x71,
+	0x11, 0x8b, 0x7c, 0x11, 0xfb, 0x15, 0x87, 0xed, 0xe3, 0x78, 0x8a, 0x15, 0xb5, 0xc5, 0x50, 0xd0,
+	0x19, 0xa9, 0x33, 0x58, 0xe6, 0xc0, 0x29, 0xb1, 0xc4, 0x0d, 0x21, 0x79, 0xc3, 0xf7, 0x75, 0x8c,
+	0xa7, 0xd8, 0x35, 0xd9, 0x47, 0x91, 0xd3, 0x38, 0x49, 0x2a, 0x16, 0x52, 0x43, 0x4a, 0xc5, 0xa2,
+	0x0d, 0xf2, 0x01, 0x36, 0x5d, 0x72, 0x8c, 0x4d, 0x72, 0xbd, 0x3b, 0x09, 0x1a, 0x62, 0x8a, 0xb8,
+	0x0e, 0xcb, 0x1c, 0x0c, 0x25, 0x9e, 0xe4, 0x26, 0xdc, 0xc0, 0x4d, 0x9c, 0xe3, 0xb6, 0x07, 0xcb,
+	0x5d, 0x6c, 0x9e, 0xe3, 0x4f, 0x88, 0xf5, 0xb5, 0xe4, 0x56, 0x00, 0xf1, 0x38, 0xb4, 0xae, 0x3e,
+	0x0a, 0x50, 0xdb, 0x71, 0x6c, 0xdb, 0xa2, 0xdf, 0xdb, 0x6d, 0x92, 0x79, 0xed, 0x84, 0xe3, 0x3a,
+	0x52, 0x6e, 0xae, 0x23, 0x31, 0xb2, 0xf9, 0x6b, 0x13, 0x5a, 0x48, 0x07, 0x4d, 0x5d, 0x83, 0x95,
+	0x24, 0x6b, 0xea, 0xce, 0xcf, 0x80, 0x82, 0x66, 0xf4, 0x1f, 0x39, 0xa3, 0x7e, 0x0d, 0xb5, 0x04,
+	0xfe, 0xf5, 0x8b, 0xe4, 0xa3, 0x1e, 0x94, 0xb8, 0x55, 0x0e, 0xad, 0x43, 0x6d, 0xb7, 0xbd, 0xd7,
+	0x7a, 0xdd, 0x35, 0x06, 0x3b, 0xbd, 0xc3, 0xbe, 0xd6, 0xd6, 0xf5, 0x4e, 0xef, 0x48, 0xbe, 0x83,
+	0x10, 0x54, 0x8f, 0x7a, 0x09, 0x99, 0x80, 0x16, 0x21, 0xb7, 0xff, 0xb6, 0xd3, 0x97, 0x45, 0x54,
+	0x84, 0xfc, 0x5e, 0xb7, 0x65, 0xb4, 0x65, 0xe9, 0x91, 0x0e, 0x25, 0x6e, 0xa1, 0xe2, 0x01, 0xfb,
+	0x2d, 0xcd, 0xe8, 0x18, 0x9d, 0xde, 0x51, 0x5b, 0x93, 0xef, 0xa0, 0x32, 0x2c, 0xbe, 0x6a, 0xff,
+	0x38, 0x38, 0x68, 0xe9, 0x07, 0xb2, 0x80, 0x96, 0xa0, 0xa4, 0xf5, 0x5e, 0x1f, 0xed, 0x0e, 0xb4,
+	0xde, 0x76, 0xe7, 0x48, 0x16, 0x7d, 0x75, 0xfb, 0x87, 0x7e, 0xb7, 0xb3, 0xd3, 0x31, 0x64, 0xe9,
+	0x11, 0x81, 0x4a, 0x62, 0xba, 0x23, 0x19, 0xca, 0xba, 0xd1, 0xd2, 0x8c, 0x41, 0x6f, 0x6f, 0x4f,
+	0x6f, 0x1b, 0x21, 0xc1, 0x50, 0xd2, 0x6e, 0x69, 0xdd, 0x4e, 0x5b, 0x37, 0x64, 0x21, 0xb6, 0xf2,
+	0xb9, 0xe9, 0x86, 0x2c, 0xa2, 0x35, 0x40, 0xbc, 0x64, 0x70, 0xd8, 0x39, 0x7a, 0xad, 0xcb, 0x12,
+	0xaa, 0xc1, 0x52, 0x28, 0x37, 0x3a, 0x87, 0x6d, 0xdd, 0x68, 0x1d, 0xf6, 0xe5, 0xdc, 0xe6, 0x6f,
+	0x45, 0x90, 0xba, 0xce, 0x18, 0xbd, 0x80, 0x05, 0xba, 0x7b, 0x21, 0xb6, 0x87, 0x26, 0xff, 0x70,
+	0x52, 0xd6, 0xe7, 0xe4, 0x34, 0xdd, 0x77, 0xfc, 0xdb, 0x74, 0x7d, 0x88, 0x6f, 0x27, 0xf7, 0x29,
+	0x65, 0x7d, 0x4e, 0xce, 0x6e, 0xef, 0x42, 0x85, 0x0a, 0x75, 0xe2, 0x62, 0xd3, 0xbe, 0x05, 0xc6,
+	0x33, 0x01, 0x7d, 0x07, 0xf9, 0xa0, 0x28, 0xd0, 0x4a, 0x64, 0xc5, 0x8f, 0x71, 0x65, 0x35, 0x25,
+	0x65, 0xaf, 0xb7, 0x01, 0xe2, 0x95, 0x02, 0xdd, 0x8d, 0xcc, 0xe6, 0xf6, 0x15, 0x45, 0xc9, 0x52,
+	0x31, 0x98, 0x3d, 0xa8, 0xd0, 0xb8, 0xa4, 0x9d, 0xf8, 0xe4, 0x30, 0x36, 0x85, 0x67, 0x02, 0x7a,
+	0x05, 0x65, 0x7e, 0x09, 0x46, 0x1b, 0x29, 0x73, 0xfe, 0x8f, 0x1d, 0xe5, 0x5e, 0xb6, 0x92, 0x91,
+	0x7a, 0x03, 0x72, 0x7a, 0x1d, 0x47, 0x0f, 0xa2, 0x3b, 0x57, 0x6c, 0xf6, 0x4a, 0xe3, 0x6a, 0x03,
+	0x06, 0x7c, 0x00, 0x25, 0x6e, 0x8a, 0x23, 0x16, 0x9a, 0xf9, 0x25, 0x41, 0xd9, 0xc8, 0xd4, 0xf1,
+	0x48, 0xdc, 0x38, 0x8f, 0x91, 0xe6, 0xb7, 0x01, 0x65, 0x23, 0x53, 0xc7, 0x90, 0x8e, 0xa0, 0x92,
+	0x98, 0xda, 0x88, 0x45, 0x27, 0x6b, 0x31, 0x50, 0x3e, 0xbb, 0x42, 0xcb, 0x17, 0x46, 0x3c, 0x9a,
+	0xe3, 0xc2, 0x98, 0x5b, 0x00, 0x14, 0x25, 0x4b, 0xc5, 0x60, 0xb6, 0xa1, 0xc8, 0xe6, 0x28, 0xaa,
+	0x47, 0xa6, 0xe9, 0xb9, 0xad, 0xdc, 0xcd, 0xd0, 0xf0, 0x18, 0x6c, 0xa4, 0xc5, 0x18, 0xe9, 0x61,
+	0xa9, 0xdc, 0xcd, 0xd0, 0x24, 0xdc, 0x61, 0x93, 0x87, 0x73, 0x27, 0x3d, 0xd5, 0x14, 0x25, 0x4b,
+	0xc5, 0x60, 0x5e, 0x41, 0x99, 0xef, 0xf9, 0x71, 0x7d, 0x66, 0xcc, 0x2f, 0xe5, 0x5e, 0xb6, 0x92,
+	0x4f, 0x3e, 0xd7, 0xc8, 0xe3, 0xe4, 0xcf, 0x4f, 0x0f, 0x65, 0x23, 0x53, 0x17, 0x21, 0x6d, 0x97,
+	0xdf, 0x42, 0xf8, 0xaf, 0xb1, 0x2d, 0x73, 0x66, 0x1d, 0x17, 0x82, 0xff, 0x8d, 0x7d, 0xf3, 0xf7,
+	0x00, 0x03, 0xe7, 0xef, 0xa4, 0x2f, 0x13, 0x00, 0x00,
 }
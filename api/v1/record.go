@@ -0,0 +1,11 @@
+package api
+
+// Header returns the value of the first header of the record with the given key
+func (m *LogRecord) Header(key string) ([]byte, bool) {
+	for _, h := range m.GetHeaders() {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
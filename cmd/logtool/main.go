@@ -0,0 +1,220 @@
+package main
+
+import (
+	"EchoLog/internal/log"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// environment variable holding the master keys of encrypted logs as a comma separated list of id=key pairs, the keys
+// being hex encoded; the key files of the segments tell which one wraps their data key
+const masterKeysEnv = "ECHOLOG_MASTER_KEYS"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  verify\tvalidate the segments of a log directory and optionally rebuild their indexes\n")
+	fmt.Fprintf(os.Stderr, "  snapshot\twrite a snapshot of a log directory which isn't open\n")
+	fmt.Fprintf(os.Stderr, "  restore\treplace the content of a log directory which isn't open with a snapshot\n")
+	fmt.Fprintf(os.Stderr, "  migrate\tupgrade the segments of a log directory written by an older version\n")
+	fmt.Fprintf(os.Stderr, "\nthe master keys of encrypted logs are read from %s=<id>=<hex key>,...\n", masterKeysEnv)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "verify":
+		err = verify(os.Args[2:])
+	case "snapshot":
+		err = snapshot(os.Args[2:])
+	case "restore":
+		err = restore(os.Args[2:])
+	case "migrate":
+		err = migrate(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	dir := flags.String("dir", "", "log directory")
+	rebuild := flags.Bool("rebuild", false, "regenerate missing or inconsistent indexes from their store files")
+	interval := flags.Uint64("index-interval", 0, "IndexIntervalBytes the log was written with")
+	_ = flags.Parse(args)
+
+	if *dir == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	keys, err := masterKeys()
+	if err != nil {
+		return err
+	}
+	reports, err := log.Verify(*dir, *rebuild, log.Config{IndexIntervalBytes: *interval, Keys: keys})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEGMENT\tRECORDS\tOFFSETS\tSTORE BYTES\tINDEX BYTES\tSTATUS")
+	failed := false
+	for _, r := range reports {
+		offsets := "-"
+		if r.Records > 0 {
+			offsets = fmt.Sprintf("%d-%d", r.FirstOffset, r.LastOffset)
+		}
+
+		// a rebuilt index doesn't repair the store, its errors are reported either way
+		status := "ok"
+		if len(r.Errors) > 0 {
+			status = "errors"
+			failed = true
+		}
+		if r.Rebuilt {
+			status += ", index rebuilt"
+			if len(r.Errors) == 0 {
+				status = "index rebuilt"
+			}
+		}
+
+		fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%d\t%s\n", r.BaseOffset, r.Records, offsets, r.StoreBytes, r.IndexBytes, status)
+		for _, e := range r.Repaired {
+			fmt.Fprintf(w, "\t  %s (repaired)\n", e)
+		}
+		for _, e := range r.Errors {
+			fmt.Fprintf(w, "\t  %s\n", e)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if failed {
+		return fmt.Errorf("%s: inconsistent segments found", *dir)
+	}
+	return nil
+}
+
+func snapshot(args []string) error {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	dir := flags.String("dir", "", "log directory")
+	out := flags.String("out", "", "snapshot file, stdout when empty")
+	interval := flags.Uint64("index-interval", 0, "IndexIntervalBytes the log was written with")
+	_ = flags.Parse(args)
+
+	if *dir == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	keys, err := masterKeys()
+	if err != nil {
+		return err
+	}
+	config := log.Config{IndexIntervalBytes: *interval, Keys: keys}
+
+	// the log is read as it is on disk, opening it would resize its files to the config given to it
+	if *out == "" {
+		return log.SnapshotDir(*dir, os.Stdout, config)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err = log.SnapshotDir(*dir, f, config); err != nil {
+		_ = f.Close()
+		_ = os.Remove(*out)
+		return err
+	}
+	return f.Close()
+}
+
+func restore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := flags.String("dir", "", "log directory")
+	in := flags.String("in", "", "snapshot file, stdin when empty")
+	_ = flags.Parse(args)
+
+	if *dir == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	keys, err := masterKeys()
+	if err != nil {
+		return err
+	}
+
+	if *in == "" {
+		return log.RestoreDir(*dir, os.Stdin, keys)
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return log.RestoreDir(*dir, f, keys)
+}
+
+func migrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dir := flags.String("dir", "", "log directory")
+	_ = flags.Parse(args)
+
+	if *dir == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	migrated, err := log.Migrate(*dir)
+	for _, name := range migrated {
+		fmt.Println(name)
+	}
+	return err
+}
+
+// masterKeys returns the master keys found in the environment, nil when there are none
+func masterKeys() (log.KeyProvider, error) {
+	value := os.Getenv(masterKeysEnv)
+	if value == "" {
+		return nil, nil
+	}
+
+	var keys *log.KeyRing
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%s: want <id>=<hex key> pairs", masterKeysEnv)
+		}
+		key, err := hex.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %q: %w", masterKeysEnv, parts[0], err)
+		}
+		if n := len(key); n != 16 && n != 24 && n != 32 {
+			return nil, fmt.Errorf("%s: key %q is %d bytes long, want 16, 24 or 32", masterKeysEnv, parts[0], n)
+		}
+
+		if keys == nil {
+			keys = log.NewKeyRing(parts[0], key)
+		} else {
+			keys.Rotate(parts[0], key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/log"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+// dirDigest maps the files of dir to their content hash
+func dirDigest(t *testing.T, dir string) map[string][32]byte {
+	t.Helper()
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	digest := make(map[string][32]byte, len(files))
+	for _, f := range files {
+		data, err := ioutil.ReadFile(path.Join(dir, f.Name()))
+		require.NoError(t, err)
+		digest[f.Name()] = sha256.Sum256(data)
+	}
+	return digest
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "logtool")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	// indexes larger than the default config, opening the log with it would shrink them
+	config := log.Config{MaxStoreBytes: 1 << 20, MaxIndexBytes: 1 << 20}
+	dir := path.Join(tmp, "log")
+	l, err := log.NewLog(dir, config)
+	require.NoError(t, err)
+	for i := 0; i < 500; i++ {
+		_, err = l.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	before := dirDigest(t, dir)
+	out := path.Join(tmp, "snapshot.tar")
+	require.NoError(t, snapshot([]string{"-dir", dir, "-out", out}))
+	require.Equal(t, before, dirDigest(t, dir))
+
+	restored := path.Join(tmp, "restored")
+	require.NoError(t, restore([]string{"-dir", restored, "-in", out}))
+	require.Equal(t, before, dirDigest(t, restored))
+
+	for _, d := range []string{dir, restored} {
+		l, err = log.NewLog(d, config)
+		require.NoError(t, err)
+		low, high := l.Offsets()
+		require.Equal(t, uint64(0), low)
+		require.Equal(t, uint64(499), high)
+		rec, err := l.Read(499)
+		require.NoError(t, err)
+		require.Equal(t, []byte("record 499"), rec.Value)
+		require.NoError(t, l.Close())
+	}
+}
+
+func TestVerifyRebuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logtool")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l, err := log.NewLog(dir, log.Config{})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = l.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	require.NoError(t, os.Remove(path.Join(dir, "0.index")))
+	require.NoError(t, verify([]string{"-dir", dir, "-rebuild"}))
+	require.NoError(t, verify([]string{"-dir", dir}))
+
+	// rebuilding the index doesn't repair a torn store
+	require.NoError(t, os.Remove(path.Join(dir, "0.index")))
+	f, err := os.OpenFile(path.Join(dir, "0.store"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Error(t, verify([]string{"-dir", dir, "-rebuild"}))
+	require.Error(t, verify([]string{"-dir", dir}))
+}
+
+func TestEncryptedLog(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "logtool")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	dir := path.Join(tmp, "log")
+	l, err := log.NewLog(dir, log.Config{MaxStoreBytes: 256, Keys: log.NewKeyRing("k1", key)})
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		_, err = l.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record %d", i))})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+
+	out := path.Join(tmp, "snapshot.tar")
+	restored := path.Join(tmp, "restored")
+	require.Error(t, verify([]string{"-dir", dir}))
+	require.Error(t, snapshot([]string{"-dir", dir, "-out", out}))
+
+	require.NoError(t, os.Setenv(masterKeysEnv, "k1=not hex"))
+	require.Error(t, verify([]string{"-dir", dir}))
+	require.NoError(t, os.Setenv(masterKeysEnv, "k0=00112233445566778899aabbccddeeff,k1="+hex.EncodeToString(key)))
+	defer os.Unsetenv(masterKeysEnv)
+
+	require.NoError(t, verify([]string{"-dir", dir}))
+	require.NoError(t, snapshot([]string{"-dir", dir, "-out", out}))
+	require.NoError(t, restore([]string{"-dir", restored, "-in", out}))
+	require.Equal(t, dirDigest(t, dir), dirDigest(t, restored))
+	require.NoError(t, verify([]string{"-dir", restored}))
+}
@@ -0,0 +1,219 @@
+package filter
+
+import (
+	"EchoLog/api/v1"
+	"encoding/json"
+	"strconv"
+)
+
+// Filter selects records by their key, headers and, for JSON payloads, the fields of their value, e.g.
+//
+//	headers.type == "order" && (value.total >= 100 || value.customer.tier == "gold")
+//
+// a selector alone tests the field is set; a comparison against a missing field, or a field of another type than the
+// literal, is false, including for != - numbers are compared with the keys and headers holding their decimal
+// representation; a header repeated in the record matches when any of its values does
+type Filter struct {
+	expr string
+	root node
+}
+
+// Parse compiles a filter expression
+func Parse(expr string) (*Filter, error) {
+	root, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// Match tells whether the record satisfies the filter
+func (f *Filter) Match(rec *api.LogRecord) bool {
+	return f.root.eval(&record{LogRecord: rec})
+}
+
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// record decodes the value of the record the first time one of its fields is selected
+type record struct {
+	*api.LogRecord
+	decoded bool
+	value   interface{}
+}
+
+func (r *record) decodedValue() interface{} {
+	if !r.decoded {
+		r.decoded = true
+		if err := json.Unmarshal(r.Value, &r.value); err != nil {
+			r.value = string(r.Value)
+		}
+	}
+	return r.value
+}
+
+type node interface {
+	eval(r *record) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(r *record) bool { return n.left.eval(r) && n.right.eval(r) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(r *record) bool { return n.left.eval(r) || n.right.eval(r) }
+
+type notNode struct{ n node }
+
+func (n notNode) eval(r *record) bool { return !n.n.eval(r) }
+
+type existsNode struct{ sel selector }
+
+func (n existsNode) eval(r *record) bool { return len(n.sel.values(r)) > 0 }
+
+type compareNode struct {
+	sel selector
+	op  string
+	lit interface{}
+}
+
+func (n compareNode) eval(r *record) bool {
+	for _, v := range n.sel.values(r) {
+		if c, ok := compare(v, n.lit); ok && n.holds(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n compareNode) holds(c int) bool {
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// compare orders the field value against the literal, false when they can't be compared
+// values which can only be told equal or not compare as 0 or 1
+func compare(v interface{}, lit interface{}) (int, bool) {
+	switch lit := lit.(type) {
+	case float64:
+		var n float64
+		switch v := v.(type) {
+		case float64:
+			n = v
+		case string:
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return 0, false
+			}
+			n = parsed
+		default:
+			return 0, false
+		}
+		switch {
+		case n < lit:
+			return -1, true
+		case n > lit:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case s < lit:
+			return -1, true
+		case s > lit:
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		b, ok := v.(bool)
+		if !ok {
+			return 0, false
+		}
+		if b == lit {
+			return 0, true
+		}
+		return 1, true
+	case nil:
+		if v == nil {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+type root int
+
+const (
+	rootKey root = iota
+	rootHeaders
+	rootValue
+)
+
+type selector struct {
+	root root
+	// names of object fields as strings, indexes of arrays as float64
+	path []interface{}
+}
+
+// values returns the values the selector points to in the record, none when it points to nothing
+func (s selector) values(r *record) []interface{} {
+	switch s.root {
+	case rootKey:
+		if len(r.Key) == 0 {
+			return nil
+		}
+		return []interface{}{string(r.Key)}
+	case rootHeaders:
+		var values []interface{}
+		for _, h := range r.Headers {
+			if h.Key == s.path[0] {
+				values = append(values, string(h.Value))
+			}
+		}
+		return values
+	}
+
+	if len(r.Value) == 0 {
+		return nil
+	}
+	v := r.decodedValue()
+	for _, field := range s.path {
+		switch field := field.(type) {
+		case string:
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			if v, ok = obj[field]; !ok {
+				return nil
+			}
+		case float64:
+			arr, ok := v.([]interface{})
+			if !ok || field < 0 || int(field) >= len(arr) || float64(int(field)) != field {
+				return nil
+			}
+			v = arr[int(field)]
+		}
+	}
+	return []interface{}{v}
+}
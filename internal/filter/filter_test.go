@@ -0,0 +1,56 @@
+package filter
+
+import (
+	"EchoLog/api/v1"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFilter_Match(t *testing.T) {
+	rec := &api.LogRecord{
+		Key:   []byte("customer-42"),
+		Value: []byte(`{"total": 120.5, "customer": {"tier": "gold"}, "items": [{"sku": "A1"}], "gift": false, "note": null}`),
+		Headers: []*api.Header{
+			{Key: "type", Value: []byte("order")},
+			{Key: "content-type", Value: []byte("application/json")},
+			{Key: "priority", Value: []byte("3")},
+			{Key: "tag", Value: []byte("eu")},
+			{Key: "tag", Value: []byte("new")},
+		},
+	}
+
+	for expr, want := range map[string]bool{
+		`key == "customer-42"`:                                   true,
+		`key >= "customer-5"`:                                    false,
+		`headers.type == "order"`:                                true,
+		`header["content-type"] == "application/json"`:           true,
+		`headers.content-type == "application/json"`:             true,
+		`headers.priority > 2`:                                   true,
+		`headers.priority <= 2`:                                  false,
+		`headers.tag == "new"`:                                   true,
+		`headers.trace`:                                          false,
+		`!headers.trace`:                                         true,
+		`headers.trace != "x"`:                                   false,
+		`value.total >= 100 && value.customer.tier == "gold"`:    true,
+		`value.total > 200 || value.customer.tier == "gold"`:     true,
+		`value.total > 200 || value.customer.tier == "silver"`:   false,
+		`not (value.total > 200) and value.items[0].sku == "A1"`: true,
+		`value.items[1].sku`:                                     false,
+		`value.gift == false`:                                    true,
+		`value.note == null`:                                     true,
+		`value.note`:                                             true,
+		`value.customer.tier > 100`:                              false,
+		`value.total == "120.5"`:                                 false,
+	} {
+		f, err := Parse(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, want, f.Match(rec), expr)
+		require.Equal(t, expr, f.String())
+	}
+
+	// values which aren't JSON are only seen as a whole
+	f, err := Parse(`value == "plain" && !value.field`)
+	require.NoError(t, err)
+	require.True(t, f.Match(&api.LogRecord{Value: []byte("plain")}))
+	require.False(t, f.Match(&api.LogRecord{}))
+}
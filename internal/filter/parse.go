@@ -0,0 +1,332 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// the grammar of filter expressions, loosest binding first:
+//
+//	expr       = and { ( "||" | "or" ) and }
+//	and        = unary { ( "&&" | "and" ) unary }
+//	unary      = ( "!" | "not" ) unary | "(" expr ")" | comparison
+//	comparison = selector [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) literal ]
+//	selector   = "key" | ( "headers" | "header" ) field | "value" { field }
+//	field      = "." name | "[" string | number "]"
+//	literal    = string | number | "true" | "false" | "null"
+//
+// strings are double quoted with Go escapes
+
+// limits on expressions sent by clients, the parser recurses once per nested operator or parenthesis
+const (
+	MaxExpressionLength = 4096
+	MaxDepth            = 64
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value interface{}
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(expr) && expr[end] != '"'; end++ {
+				if expr[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("filter: unterminated string at %d", i)
+			}
+			s, err := strconv.Unquote(expr[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid string at %d: %w", i, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expr[i : end+1], pos: i, value: s})
+			i = end + 1
+		case unicode.IsDigit(c) || c == '-' && i+1 < len(expr) && unicode.IsDigit(rune(expr[i+1])):
+			end := i + 1
+			for end < len(expr) && strings.ContainsRune("0123456789.eE+-", rune(expr[end])) {
+				end++
+			}
+			n, err := strconv.ParseFloat(expr[i:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("filter: invalid number at %d: %s", i, expr[i:end])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expr[i:end], pos: i, value: n})
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i + 1
+			for end < len(expr) && (unicode.IsLetter(rune(expr[end])) || unicode.IsDigit(rune(expr[end])) ||
+				expr[end] == '_' || expr[end] == '-') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenName, text: expr[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", "."} {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("filter: unexpected %q at %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	// number of expressions being parsed, each nesting the next one
+	depth int
+}
+
+func parse(expr string) (node, error) {
+	if len(expr) > MaxExpressionLength {
+		return nil, fmt.Errorf("filter: expression of %d bytes, at most %d are allowed", len(expr), MaxExpressionLength)
+	}
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// accept consumes the next token when it is one of the given operators or keywords
+func (p *parser) accept(texts ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenName {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			p.next++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("filter: unexpected end of expression")
+	}
+	return fmt.Errorf("filter: unexpected %s at %d", t.text, t.pos)
+}
+
+// nest must be called when a new expression starts, the returned function when it ends
+func (p *parser) nest() (func(), error) {
+	if p.depth == MaxDepth {
+		return nil, fmt.Errorf("filter: expression nested more than %d times, at %d", MaxDepth, p.peek().pos)
+	}
+	p.depth++
+	return func() { p.depth-- }, nil
+}
+
+func (p *parser) or() (node, error) {
+	done, err := p.nest()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||", "or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&", "and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	done, err := p.nest()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	if p.accept("!", "not") {
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	if p.accept("(") {
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected(p.peek())
+		}
+		return n, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	sel, err := p.selector()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return existsNode{sel}, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return existsNode{sel}, nil
+	}
+	p.advance()
+
+	lit, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := lit.(float64); !ok && t.text != "==" && t.text != "!=" {
+		if _, ok := lit.(string); !ok {
+			return nil, fmt.Errorf("filter: %s compares numbers or strings, at %d", t.text, t.pos)
+		}
+	}
+	return compareNode{sel: sel, op: t.text, lit: lit}, nil
+}
+
+func (p *parser) selector() (selector, error) {
+	t := p.advance()
+	if t.kind != tokenName {
+		return selector{}, p.unexpected(t)
+	}
+
+	var sel selector
+	switch t.text {
+	case "key":
+		sel.root = rootKey
+	case "headers", "header":
+		sel.root = rootHeaders
+	case "value":
+		sel.root = rootValue
+	default:
+		return selector{}, fmt.Errorf("filter: unknown field %s at %d, expected key, headers or value", t.text, t.pos)
+	}
+
+	for {
+		var field interface{}
+		if p.accept(".") {
+			name := p.advance()
+			if name.kind != tokenName {
+				return selector{}, p.unexpected(name)
+			}
+			field = name.text
+		} else if p.accept("[") {
+			index := p.advance()
+			if index.kind != tokenString && index.kind != tokenNumber {
+				return selector{}, p.unexpected(index)
+			}
+			if !p.accept("]") {
+				return selector{}, p.unexpected(p.peek())
+			}
+			field = index.value
+		} else {
+			break
+		}
+		sel.path = append(sel.path, field)
+	}
+
+	switch {
+	case sel.root == rootKey && len(sel.path) > 0:
+		return selector{}, fmt.Errorf("filter: key has no fields, at %d", t.pos)
+	case sel.root == rootHeaders && len(sel.path) != 1:
+		return selector{}, fmt.Errorf("filter: headers takes the name of a header, at %d", t.pos)
+	case sel.root == rootHeaders:
+		if _, ok := sel.path[0].(string); !ok {
+			return selector{}, fmt.Errorf("filter: header names are strings, at %d", t.pos)
+		}
+	}
+	return sel, nil
+}
+
+func (p *parser) literal() (interface{}, error) {
+	t := p.advance()
+	switch {
+	case t.kind == tokenString || t.kind == tokenNumber:
+		return t.value, nil
+	case t.kind == tokenName && t.text == "true":
+		return true, nil
+	case t.kind == tokenName && t.text == "false":
+		return false, nil
+	case t.kind == tokenName && t.text == "null":
+		return nil, nil
+	}
+	return nil, p.unexpected(t)
+}
@@ -0,0 +1,47 @@
+package filter
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParse_Errors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`topic == "orders"`,
+		`key.name == "a"`,
+		`headers == "a"`,
+		`headers[0] == "a"`,
+		`value.total >`,
+		`value.total > true`,
+		`value.total == "unterminated`,
+		`(value.total > 1`,
+		`value.total > 1)`,
+		`value.total > 1 &&`,
+		`value.total $ 1`,
+		`value.items[0 == 1`,
+	} {
+		_, err := Parse(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestParse_Limits(t *testing.T) {
+	nested := func(n int) string {
+		return strings.Repeat("(", n) + "key" + strings.Repeat(")", n)
+	}
+	_, err := Parse(nested(20))
+	require.NoError(t, err)
+	_, err = Parse(nested(MaxDepth))
+	require.Error(t, err)
+	_, err = Parse(strings.Repeat("!", MaxDepth) + "key")
+	require.Error(t, err)
+	_, err = Parse(strings.Repeat("(", 4<<20))
+	require.Error(t, err)
+
+	long := "key" + strings.Repeat(" || key", MaxExpressionLength/7)
+	require.Greater(t, len(long), MaxExpressionLength)
+	_, err = Parse(long)
+	require.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package group
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/log"
+	"EchoLog/internal/topic"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// the offsets committed by the groups are records of an internal log keyed by group, topic and partition, replayed
+// when the coordinator starts; the log is compacted so only the latest commit of every partition is kept
+// the membership of the groups is only kept in memory, members join again after a restart
+
+type Config struct {
+	// members which didn't send a heartbeat for this long are removed from their group, defaults to 10s
+	SessionTimeout time.Duration
+	// config of the offsets log, its compaction is always enabled and every commit is synced before it is
+	// acknowledged; segments default to 1 MiB stores and 64 KiB indexes
+	Log log.Config
+}
+
+// Coordinator tracks the members of the consumer groups, assigns them the partitions of their topic and keeps the
+// offsets the groups committed
+type Coordinator struct {
+	mux       sync.Mutex
+	config    Config
+	topics    *topic.Manager
+	offsets   *log.Log
+	committed map[offsetKey]uint64
+	groups    map[string]*group
+	now       func() time.Time
+}
+
+type offsetKey struct {
+	Group     string
+	Topic     string
+	Partition uint32
+}
+
+// NewCoordinator opens the offsets log stored in dir, the partitions assigned to the members are the ones of the
+// topics of the manager
+func NewCoordinator(dir string, topics *topic.Manager, config Config) (*Coordinator, error) {
+	if config.SessionTimeout == 0 {
+		config.SessionTimeout = 10 * time.Second
+	}
+	config.Log.Compaction.Enabled = true
+	config.Log.Durability = log.DurabilityAlways
+	if config.Log.MaxStoreBytes == 0 {
+		config.Log.MaxStoreBytes = 1 << 20
+	}
+	if config.Log.MaxIndexBytes == 0 {
+		config.Log.MaxIndexBytes = 64 << 10
+	}
+
+	offsets, err := log.NewLog(dir, config.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Coordinator{
+		config:    config,
+		topics:    topics,
+		offsets:   offsets,
+		committed: make(map[offsetKey]uint64),
+		groups:    make(map[string]*group),
+		now:       time.Now,
+	}
+	if err = c.replay(); err != nil {
+		_ = offsets.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Coordinator) replay() error {
+	low, _ := c.offsets.Offsets()
+	it := c.offsets.Iterator(low)
+	for {
+		rec, err := it.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var key offsetKey
+		if err = json.Unmarshal(rec.Key, &key); err != nil {
+			return fmt.Errorf("offset commit at %d: %w", rec.Offset, err)
+		}
+		offset, err := strconv.ParseUint(string(rec.Value), 10, 64)
+		if err != nil {
+			return fmt.Errorf("offset commit at %d: %w", rec.Offset, err)
+		}
+		c.committed[key] = offset
+	}
+}
+
+// Commit records the offset of the next record the group consumes from the partition
+// with a member id the commit fails unless the member is assigned the partition in this generation of the group,
+// without one the offset is only committed for a group which has no live members, whose consumers track their
+// partitions themselves
+func (c *Coordinator) Commit(groupName string, topicName string, partition uint32, offset uint64, memberID string,
+	generation uint64) error {
+	if topicName == "" {
+		topicName = topic.DefaultTopic
+	}
+	t, err := c.topics.Topic(topicName)
+	if err != nil {
+		return err
+	}
+	if _, err = t.Partition(partition); err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if memberID == "" {
+		if g, ok := c.groups[groupName]; ok {
+			c.expire(g)
+			if len(g.members) > 0 {
+				return api.ErrUnknownMember{Group: groupName, MemberID: memberID}
+			}
+		}
+	} else {
+		g, m, err := c.member(groupName, memberID)
+		if err != nil {
+			return err
+		}
+		if generation != g.generation {
+			return api.ErrStaleGeneration{Group: groupName, Generation: generation, CurrentGeneration: g.generation}
+		}
+		if g.topic != topicName || !m.assigned(partition) {
+			return api.ErrPartitionNotAssigned{Group: groupName, MemberID: memberID, Topic: topicName, Partition: partition}
+		}
+	}
+
+	key := offsetKey{Group: groupName, Topic: topicName, Partition: partition}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	// appended under the lock so the log replays the commits in the order they were made
+	if _, err = c.offsets.Append(&api.LogRecord{
+		Key:   data,
+		Value: []byte(strconv.FormatUint(offset, 10)),
+	}); err != nil {
+		return err
+	}
+	c.committed[key] = offset
+	return nil
+}
+
+// Fetch returns the offset the group last committed for the partition, api.ErrNoCommittedOffset when it never did
+func (c *Coordinator) Fetch(groupName string, topicName string, partition uint32) (uint64, error) {
+	if topicName == "" {
+		topicName = topic.DefaultTopic
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	offset, ok := c.committed[offsetKey{Group: groupName, Topic: topicName, Partition: partition}]
+	if !ok {
+		return 0, api.ErrNoCommittedOffset{Group: groupName, Topic: topicName, Partition: partition}
+	}
+	return offset, nil
+}
+
+// Close closes the offsets log, the members of the groups are forgotten
+func (c *Coordinator) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.groups = make(map[string]*group)
+	return c.offsets.Close()
+}
@@ -0,0 +1,113 @@
+package group
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/log"
+	"EchoLog/internal/topic"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func setupCoordinator(t *testing.T) (*Coordinator, *topic.Manager, string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "group-coordinator")
+	require.NoError(t, err)
+
+	topics, err := topic.NewManager(path.Join(dir, "topics"), log.Config{})
+	require.NoError(t, err)
+	require.NoError(t, topics.Create("orders", 4))
+
+	c, err := NewCoordinator(path.Join(dir, "groups"), topics, Config{})
+	require.NoError(t, err)
+
+	return c, topics, dir, func() {
+		c.Close()
+		topics.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCoordinator_Offsets(t *testing.T) {
+	c, topics, dir, teardown := setupCoordinator(t)
+	defer teardown()
+
+	_, err := c.Fetch("billing", "orders", 0)
+	require.Equal(t, api.ErrNoCommittedOffset{Group: "billing", Topic: "orders", Partition: 0}, err)
+
+	require.NoError(t, c.Commit("billing", "orders", 0, 5, "", 0))
+	require.NoError(t, c.Commit("billing", "orders", 0, 8, "", 0))
+	require.NoError(t, c.Commit("billing", "orders", 1, 2, "", 0))
+	require.NoError(t, c.Commit("shipping", "orders", 0, 1, "", 0))
+	require.NoError(t, c.Commit("billing", "", 0, 3, "", 0))
+
+	_, err = c.Fetch("billing", "payments", 0)
+	require.Error(t, err)
+	require.Equal(t, api.ErrPartitionNotFound{Topic: "orders", Partition: 4}, c.Commit("billing", "orders", 4, 0, "", 0))
+	require.Equal(t, api.ErrTopicNotFound{Topic: "payments"}, c.Commit("billing", "payments", 0, 0, "", 0))
+
+	// the commits are replayed when the coordinator is reopened
+	require.NoError(t, c.Close())
+	c, err = NewCoordinator(path.Join(dir, "groups"), topics, Config{})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		group     string
+		topic     string
+		partition uint32
+		offset    uint64
+	}{
+		{"billing", "orders", 0, 8},
+		{"billing", "orders", 1, 2},
+		{"shipping", "orders", 0, 1},
+		{"billing", topic.DefaultTopic, 0, 3},
+	} {
+		offset, err := c.Fetch(tc.group, tc.topic, tc.partition)
+		require.NoError(t, err)
+		require.Equal(t, tc.offset, offset)
+	}
+}
+
+func TestCoordinator_CommitFencing(t *testing.T) {
+	c, _, _, teardown := setupCoordinator(t)
+	defer teardown()
+
+	first, generation, partitions, err := c.Join("billing", "orders", "")
+	require.NoError(t, err)
+	require.Equal(t, []uint32{0, 1, 2, 3}, partitions)
+	require.NoError(t, c.Commit("billing", "orders", 3, 10, first, generation))
+
+	// the second member takes over half of the partitions
+	second, _, _, err := c.Join("billing", "orders", "")
+	require.NoError(t, err)
+	require.Equal(t,
+		api.ErrStaleGeneration{Group: "billing", Generation: generation, CurrentGeneration: generation + 1},
+		c.Commit("billing", "orders", 3, 11, first, generation),
+	)
+	require.Equal(t,
+		api.ErrPartitionNotAssigned{Group: "billing", MemberID: first, Topic: "orders", Partition: 3},
+		c.Commit("billing", "orders", 3, 11, first, generation+1),
+	)
+	require.NoError(t, c.Commit("billing", "orders", 1, 4, first, generation+1))
+	require.Equal(t,
+		api.ErrUnknownMember{Group: "billing", MemberID: "unknown"},
+		c.Commit("billing", "orders", 1, 4, "unknown", generation+1),
+	)
+	// a commit without a member id can't bypass the assignment of a group with live members
+	require.Equal(t,
+		api.ErrUnknownMember{Group: "billing", MemberID: ""},
+		c.Commit("billing", "orders", 3, 11, "", 0),
+	)
+
+	offset, err := c.Fetch("billing", "orders", 3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), offset)
+
+	// once the group is empty its offsets may be reset without a member
+	require.NoError(t, c.Leave("billing", first))
+	require.NoError(t, c.Leave("billing", second))
+	require.NoError(t, c.Commit("billing", "orders", 3, 0, "", 0))
+}
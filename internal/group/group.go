@@ -0,0 +1,208 @@
+package group
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/topic"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// group is rebalanced whenever a member joins, leaves or expires and when partitions are added to its topic: its
+// generation is increased and the partitions are assigned again, split in contiguous ranges across the members in
+// the order they joined
+type group struct {
+	topic      string
+	generation uint64
+	// the partition count of the topic the assignment was made for
+	partitions uint32
+	members    []*member
+}
+
+type member struct {
+	id            string
+	lastHeartbeat time.Time
+	partitions    []uint32
+}
+
+func (m *member) assigned(partition uint32) bool {
+	for _, p := range m.partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *group) rebalance(partitions uint32) {
+	g.generation++
+	g.partitions = partitions
+
+	if len(g.members) == 0 {
+		return
+	}
+	n := partitions / uint32(len(g.members))
+	extra := partitions % uint32(len(g.members))
+	next := uint32(0)
+	for i, m := range g.members {
+		count := n
+		if uint32(i) < extra {
+			count++
+		}
+		m.partitions = make([]uint32, 0, count)
+		for p := next; p < next+count; p++ {
+			m.partitions = append(m.partitions, p)
+		}
+		next += count
+	}
+}
+
+// Join adds a member to the group, creating it when it has no members, and returns the member id along with the
+// generation of the group and the partitions assigned to the member
+// a member id known to the group keeps its partitions, an empty or unknown one joins as a new member
+func (c *Coordinator) Join(groupName string, topicName string, memberID string) (string, uint64, []uint32, error) {
+	if topicName == "" {
+		topicName = topic.DefaultTopic
+	}
+	t, err := c.topics.Topic(topicName)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	g, ok := c.groups[groupName]
+	if ok {
+		c.expire(g)
+	}
+	if !ok || len(g.members) == 0 {
+		g = &group{topic: topicName, generation: g.lastGeneration()}
+		c.groups[groupName] = g
+	}
+	if g.topic != topicName {
+		return "", 0, nil, api.ErrGroupTopicMismatch{Group: groupName, Topic: topicName, GroupTopic: g.topic}
+	}
+
+	for _, m := range g.members {
+		if m.id == memberID {
+			m.lastHeartbeat = c.now()
+			c.refresh(g, t)
+			return m.id, g.generation, m.partitions, nil
+		}
+	}
+
+	if memberID, err = newMemberID(); err != nil {
+		return "", 0, nil, err
+	}
+	m := &member{id: memberID, lastHeartbeat: c.now()}
+	g.members = append(g.members, m)
+	g.rebalance(t.Partitions())
+	return m.id, g.generation, m.partitions, nil
+}
+
+// Heartbeat keeps the member in the group and returns the generation of the group and the partitions assigned to the
+// member, which changed if the generation did
+// fails with api.ErrUnknownMember once the member left or expired
+func (c *Coordinator) Heartbeat(groupName string, memberID string) (uint64, []uint32, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	g, m, err := c.member(groupName, memberID)
+	if err != nil {
+		return 0, nil, err
+	}
+	m.lastHeartbeat = c.now()
+	if t, err := c.topics.Topic(g.topic); err == nil {
+		c.refresh(g, t)
+	}
+	return g.generation, m.partitions, nil
+}
+
+// Leave removes the member from the group, its partitions are assigned to the remaining members
+func (c *Coordinator) Leave(groupName string, memberID string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	g, m, err := c.member(groupName, memberID)
+	if err != nil {
+		return err
+	}
+	g.remove(m)
+	g.rebalance(g.partitions)
+	return nil
+}
+
+// Topic returns the topic the group consumes, false when the group has no members
+func (c *Coordinator) Topic(groupName string) (string, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	g, ok := c.groups[groupName]
+	if !ok || len(g.members) == 0 {
+		return "", false
+	}
+	return g.topic, true
+}
+
+// member expires the members of the group and returns the member, must be called with the lock held
+func (c *Coordinator) member(groupName string, memberID string) (*group, *member, error) {
+	g, ok := c.groups[groupName]
+	if ok {
+		c.expire(g)
+		for _, m := range g.members {
+			if m.id == memberID {
+				return g, m, nil
+			}
+		}
+	}
+	return nil, nil, api.ErrUnknownMember{Group: groupName, MemberID: memberID}
+}
+
+// expire removes the members which missed their heartbeats, must be called with the lock held
+func (c *Coordinator) expire(g *group) {
+	deadline := c.now().Add(-c.config.SessionTimeout)
+	expired := false
+	for _, m := range append([]*member(nil), g.members...) {
+		if m.lastHeartbeat.Before(deadline) {
+			g.remove(m)
+			expired = true
+		}
+	}
+	if expired {
+		g.rebalance(g.partitions)
+	}
+}
+
+// refresh rebalances the group when partitions were added to its topic, must be called with the lock held
+func (c *Coordinator) refresh(g *group, t *topic.Topic) {
+	if partitions := t.Partitions(); partitions != g.partitions {
+		g.rebalance(partitions)
+	}
+}
+
+func (g *group) remove(m *member) {
+	for i, other := range g.members {
+		if other == m {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			return
+		}
+	}
+}
+
+// lastGeneration keeps the generations of a group increasing when it is emptied and joined again, so a commit made
+// for an earlier generation never matches a later one
+func (g *group) lastGeneration() uint64 {
+	if g == nil {
+		return 0
+	}
+	return g.generation
+}
+
+func newMemberID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
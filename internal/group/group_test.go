@@ -0,0 +1,98 @@
+package group
+
+import (
+	"EchoLog/api/v1"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGroup_Rebalance(t *testing.T) {
+	g := &group{}
+	for i := 0; i < 3; i++ {
+		g.members = append(g.members, &member{})
+	}
+
+	g.rebalance(7)
+	require.Equal(t, uint64(1), g.generation)
+	require.Equal(t, []uint32{0, 1, 2}, g.members[0].partitions)
+	require.Equal(t, []uint32{3, 4}, g.members[1].partitions)
+	require.Equal(t, []uint32{5, 6}, g.members[2].partitions)
+
+	// members beyond the partition count are assigned nothing
+	g.rebalance(2)
+	require.Equal(t, []uint32{0}, g.members[0].partitions)
+	require.Equal(t, []uint32{1}, g.members[1].partitions)
+	require.Empty(t, g.members[2].partitions)
+}
+
+func TestCoordinator_Membership(t *testing.T) {
+	c, topics, _, teardown := setupCoordinator(t)
+	defer teardown()
+
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	first, generation, partitions, err := c.Join("billing", "orders", "")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), generation)
+	require.Equal(t, []uint32{0, 1, 2, 3}, partitions)
+
+	second, generation, partitions, err := c.Join("billing", "orders", "")
+	require.NoError(t, err)
+	require.NotEqual(t, first, second)
+	require.Equal(t, uint64(2), generation)
+	require.Equal(t, []uint32{2, 3}, partitions)
+
+	// the first member learns about the new assignment from its heartbeat
+	generation, partitions, err = c.Heartbeat("billing", first)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), generation)
+	require.Equal(t, []uint32{0, 1}, partitions)
+
+	// joining again with a known member id keeps the assignment
+	id, generation, partitions, err := c.Join("billing", "orders", first)
+	require.NoError(t, err)
+	require.Equal(t, first, id)
+	require.Equal(t, uint64(2), generation)
+	require.Equal(t, []uint32{0, 1}, partitions)
+
+	_, _, _, err = c.Join("billing", "", "")
+	require.Equal(t, api.ErrGroupTopicMismatch{Group: "billing", Topic: "default", GroupTopic: "orders"}, err)
+
+	// added partitions are assigned
+	orders, err := topics.Topic("orders")
+	require.NoError(t, err)
+	require.NoError(t, orders.AddPartitions(6))
+	generation, partitions, err = c.Heartbeat("billing", second)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), generation)
+	require.Equal(t, []uint32{3, 4, 5}, partitions)
+
+	// the second member stops sending heartbeats
+	now = now.Add(6 * time.Second)
+	_, _, err = c.Heartbeat("billing", first)
+	require.NoError(t, err)
+	now = now.Add(6 * time.Second)
+	generation, partitions, err = c.Heartbeat("billing", first)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), generation)
+	require.Equal(t, []uint32{0, 1, 2, 3, 4, 5}, partitions)
+	_, _, err = c.Heartbeat("billing", second)
+	require.Equal(t, api.ErrUnknownMember{Group: "billing", MemberID: second}, err)
+
+	name, ok := c.Topic("billing")
+	require.True(t, ok)
+	require.Equal(t, "orders", name)
+
+	require.NoError(t, c.Leave("billing", first))
+	_, ok = c.Topic("billing")
+	require.False(t, ok)
+	require.Equal(t, api.ErrUnknownMember{Group: "billing", MemberID: first}, c.Leave("billing", first))
+
+	// an empty group may consume another topic, its generations keep increasing
+	_, generation, partitions, err = c.Join("billing", "", "")
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), generation)
+	require.Equal(t, []uint32{0}, partitions)
+}
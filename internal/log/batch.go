@@ -0,0 +1,205 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"io"
+	"io/ioutil"
+)
+
+// an entry of the store holds either a single record or a batch of records appended together, compressed or not
+// a batch is framed as [marker][compression][first offset][last offset][records][uncompressed bytes][payload],
+// the payload is the sequence of its records each framed as [length][data], compressed unless the compression is
+// NO_COMPRESSION; being a single entry of the store a batch is written, and torn by a crash, as a whole
+// the marker is a zero byte: protobuf field tags are never zero so the encoding of a single record can't start with it
+const (
+	batchMarker         byte = 0
+	batchHeaderByteSize      = 1 + 1 + 8 + 8 + 4 + 8
+)
+
+// entryHeader describes an entry of the store without decompressing it
+type entryHeader struct {
+	firstOffset uint64
+	lastOffset  uint64
+	records     uint64
+	compression api.Compression
+	// number of store bytes the records would take if they were stored one by one
+	uncompressedBytes uint64
+}
+
+// storeEntry is an entry of the store with its records decoded
+type storeEntry struct {
+	entryHeader
+	records []*api.LogRecord
+	// number of store bytes taken by the entry, framing included
+	size uint64
+}
+
+func isBatch(data []byte) bool {
+	return len(data) > 0 && data[0] == batchMarker
+}
+
+// checkCompression returns an error for the codecs the log doesn't know about
+func checkCompression(compression api.Compression) error {
+	switch compression {
+	case api.Compression_DEFAULT_COMPRESSION, api.Compression_NO_COMPRESSION, api.Compression_GZIP, api.Compression_FLATE:
+		return nil
+	}
+	return fmt.Errorf("unsupported compression: %d", compression)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func compressor(w io.Writer, compression api.Compression) (io.WriteCloser, error) {
+	switch compression {
+	case api.Compression_NO_COMPRESSION:
+		return nopWriteCloser{w}, nil
+	case api.Compression_GZIP:
+		return gzip.NewWriter(w), nil
+	case api.Compression_FLATE:
+		return flate.NewWriter(w, flate.DefaultCompression)
+	}
+	return nil, fmt.Errorf("unsupported compression: %d", compression)
+}
+
+func decompressor(r io.Reader, compression api.Compression) (io.Reader, error) {
+	switch compression {
+	case api.Compression_NO_COMPRESSION:
+		return r, nil
+	case api.Compression_GZIP:
+		return gzip.NewReader(r)
+	case api.Compression_FLATE:
+		return flate.NewReader(r), nil
+	}
+	return nil, errCorruptRecord
+}
+
+// encodeBatch compresses the records, which already hold their offsets, into the data of a single store entry
+// compression must be NO_COMPRESSION for records stored uncompressed
+func encodeBatch(recs []*api.LogRecord, compression api.Compression) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, batchHeaderByteSize))
+	w, err := compressor(buf, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	var uncompressedBytes uint64
+	length := make([]byte, recordLengthByteSize)
+	for _, rec := range recs {
+		data, err := proto.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint64(length, uint64(len(data)))
+		if _, err = w.Write(length); err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(data); err != nil {
+			return nil, err
+		}
+		uncompressedBytes += uint64(len(data)) + recordHeaderByteSize
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()
+	data[0] = batchMarker
+	data[1] = byte(compression)
+	binary.BigEndian.PutUint64(data[2:10], recs[0].Offset)
+	binary.BigEndian.PutUint64(data[10:18], recs[len(recs)-1].Offset)
+	binary.BigEndian.PutUint32(data[18:22], uint32(len(recs)))
+	binary.BigEndian.PutUint64(data[22:30], uncompressedBytes)
+	return data, nil
+}
+
+// readEntryHeader describes the entry stored as data, only single records are decoded
+func readEntryHeader(data []byte) (entryHeader, error) {
+	if !isBatch(data) {
+		rec := &api.LogRecord{}
+		if err := proto.Unmarshal(data, rec); err != nil {
+			return entryHeader{}, errCorruptRecord
+		}
+		return entryHeader{
+			firstOffset:       rec.Offset,
+			lastOffset:        rec.Offset,
+			records:           1,
+			compression:       api.Compression_NO_COMPRESSION,
+			uncompressedBytes: uint64(len(data)) + recordHeaderByteSize,
+		}, nil
+	}
+
+	if len(data) < batchHeaderByteSize {
+		return entryHeader{}, errCorruptRecord
+	}
+	h := entryHeader{
+		compression:       api.Compression(data[1]),
+		firstOffset:       binary.BigEndian.Uint64(data[2:10]),
+		lastOffset:        binary.BigEndian.Uint64(data[10:18]),
+		records:           uint64(binary.BigEndian.Uint32(data[18:22])),
+		uncompressedBytes: binary.BigEndian.Uint64(data[22:30]),
+	}
+	if h.records == 0 || h.lastOffset < h.firstOffset || h.lastOffset-h.firstOffset < h.records-1 {
+		return entryHeader{}, errCorruptRecord
+	}
+	return h, nil
+}
+
+// decodeEntry returns the records of the entry stored as data, decompressing them if needed
+// returns errCorruptRecord when the data can't be decoded
+func decodeEntry(data []byte) (entryHeader, []*api.LogRecord, error) {
+	h, err := readEntryHeader(data)
+	if err != nil {
+		return h, nil, err
+	}
+
+	if !isBatch(data) {
+		rec := &api.LogRecord{}
+		if err = proto.Unmarshal(data, rec); err != nil {
+			return h, nil, errCorruptRecord
+		}
+		return h, []*api.LogRecord{rec}, nil
+	}
+
+	r, err := decompressor(bytes.NewReader(data[batchHeaderByteSize:]), h.compression)
+	if err != nil {
+		return h, nil, errCorruptRecord
+	}
+	payload, err := ioutil.ReadAll(r)
+	if err != nil {
+		return h, nil, errCorruptRecord
+	}
+
+	recs := make([]*api.LogRecord, 0, h.records)
+	for len(payload) > 0 {
+		if len(payload) < recordLengthByteSize {
+			return h, nil, errCorruptRecord
+		}
+		size := binary.BigEndian.Uint64(payload[:recordLengthByteSize])
+		payload = payload[recordLengthByteSize:]
+		if size > uint64(len(payload)) {
+			return h, nil, errCorruptRecord
+		}
+
+		rec := &api.LogRecord{}
+		if err = proto.Unmarshal(payload[:size], rec); err != nil {
+			return h, nil, errCorruptRecord
+		}
+		recs = append(recs, rec)
+		payload = payload[size:]
+	}
+
+	if uint64(len(recs)) != h.records || recs[0].Offset != h.firstOffset || recs[len(recs)-1].Offset != h.lastOffset {
+		return h, nil, errCorruptRecord
+	}
+	return h, recs, nil
+}
@@ -0,0 +1,151 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestBatch_EncodeDecode(t *testing.T) {
+	compressions := []api.Compression{api.Compression_NO_COMPRESSION, api.Compression_GZIP, api.Compression_FLATE}
+	for _, compression := range compressions {
+		recs := []*api.LogRecord{
+			{Offset: 7, Value: []byte("first"), Timestamp: 1},
+			{Offset: 8, Value: []byte("second"), Key: []byte("k")},
+			{Offset: 9, Headers: []*api.Header{{Key: "content-type", Value: []byte("json")}}},
+		}
+
+		data, err := encodeBatch(recs, compression)
+		assert.NoError(t, err)
+		assert.True(t, isBatch(data))
+
+		h, decoded, err := decodeEntry(data)
+		assert.NoError(t, err)
+		assert.Equal(t, compression, h.compression)
+		assert.Equal(t, uint64(7), h.firstOffset)
+		assert.Equal(t, uint64(9), h.lastOffset)
+		assert.Equal(t, uint64(3), h.records)
+		assert.Len(t, decoded, 3)
+		for i := range recs {
+			assert.Equal(t, recs[i].Offset, decoded[i].Offset)
+			assert.Equal(t, recs[i].Value, decoded[i].Value)
+			assert.Equal(t, recs[i].Key, decoded[i].Key)
+		}
+		assert.Equal(t, "json", string(decoded[2].Headers[0].Value))
+
+		// a payload which doesn't match its header is rejected
+		_, _, err = decodeEntry(data[:len(data)-4])
+		assert.Equal(t, errCorruptRecord, err)
+		data[10+7]++
+		_, _, err = decodeEntry(data)
+		assert.Equal(t, errCorruptRecord, err)
+	}
+
+	_, err := encodeBatch([]*api.LogRecord{{}}, api.Compression(42))
+	assert.Error(t, err)
+}
+
+func TestLog_AppendCompressed(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-compression")
+	defer os.RemoveAll(dir)
+
+	config := Config{MaxStoreBytes: 1024, MaxIndexBytes: entryWidth * 2, Compression: api.Compression_GZIP}
+	log, err := NewLog(dir, config)
+	assert.NoError(t, err)
+
+	value := func(i int) []byte {
+		return []byte(fmt.Sprintf(`{"id":%d,"status":"active","tags":["a","b","c"]}`, i))
+	}
+	// compressed with the codec of the config, then one chosen per append, then not compressed
+	var recs []*api.LogRecord
+	for i := 0; i < 30; i++ {
+		recs = append(recs, &api.LogRecord{Value: value(i)})
+	}
+	first, last, err := log.AppendBatch(recs[:10])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), first)
+	assert.Equal(t, uint64(9), last)
+	first, last, err = log.AppendCompressed(recs[10:20], api.Compression_FLATE)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), first)
+	assert.Equal(t, uint64(19), last)
+	first, last, err = log.AppendCompressed(recs[20:], api.Compression_NO_COMPRESSION)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), first)
+	assert.Equal(t, uint64(29), last)
+
+	_, _, err = log.AppendCompressed(recs[:1], api.Compression(42))
+	assert.Error(t, err)
+
+	check := func(log *Log) {
+		for i := 0; i < 30; i++ {
+			rec, err := log.Read(uint64(i))
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(i), rec.Offset)
+			assert.Equal(t, value(i), rec.Value)
+		}
+
+		it := log.Iterator(0)
+		for i := 0; i < 30; i++ {
+			rec, err := it.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, value(i), rec.Value)
+		}
+		_, err := it.Next()
+		assert.Equal(t, io.EOF, err)
+	}
+	check(log)
+
+	// a batch takes a single entry of the store
+	assert.Equal(t, uint64(2), log.segments[0].index.entries())
+
+	assert.NoError(t, log.Close())
+	log, err = NewLog(dir, config)
+	assert.NoError(t, err)
+	defer log.Remove()
+	assert.Empty(t, log.Recovered())
+	check(log)
+
+	offset, err := log.Append(&api.LogRecord{Value: value(30)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(30), offset)
+}
+
+func TestLog_CompactCompressed(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-compression")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxStoreBytes: 64, Compression: api.Compression_GZIP})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	_, _, err = log.AppendBatch([]*api.LogRecord{
+		{Key: []byte("a"), Value: []byte("a1")},
+		{Key: []byte("b"), Value: []byte("b1")},
+		{Key: []byte("a"), Value: []byte("a2")},
+	})
+	assert.NoError(t, err)
+	_, err = log.Append(&api.LogRecord{Key: []byte("b"), Value: []byte("b2")})
+	assert.NoError(t, err)
+	assert.Len(t, log.segments, 3)
+
+	stats, err := log.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), stats.RemovedRecords)
+
+	// the record left of the batch is still compressed
+	segmentStats, err := log.SegmentStats()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), segmentStats[0].Records)
+	assert.Equal(t, uint64(1), segmentStats[0].CompressedRecords)
+
+	for offset, expected := range map[uint64]string{0: "a2", 1: "a2", 2: "a2", 3: "b2"} {
+		rec, err := log.Read(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(rec.Value))
+	}
+}
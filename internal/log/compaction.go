@@ -0,0 +1,275 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"fmt"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// name of the directory, inside the log directory, where segments are rewritten before replacing the original ones
+const compactionDir = "compaction"
+
+// CompactionStats describes what a compaction rewrote
+type CompactionStats struct {
+	Segments       uint64
+	RemovedRecords uint64
+	BytesBefore    uint64
+	BytesAfter     uint64
+}
+
+// Compact rewrites the sealed segments keeping only the latest record of every key
+// records without a key are always kept, tombstones are kept until they are older than Compaction.TombstoneRetention
+// the records keep their offsets: reading a removed offset returns the first record after it
+// the sealed segments are scanned and rewritten while records keep being appended and read, the lock is only held to
+// scan the active segment and to swap a rewritten segment in; segments removed in the meantime are skipped
+func (log *Log) Compact() (CompactionStats, error) {
+	log.compactMux.Lock()
+	defer log.compactMux.Unlock()
+
+	var stats CompactionStats
+	// offset of the latest record of every key, the active segment included
+	latest := make(map[string]uint64)
+	scan := func(rec *api.LogRecord) error {
+		if offset, ok := latest[string(rec.Key)]; len(rec.Key) > 0 && (!ok || rec.Offset > offset) {
+			latest[string(rec.Key)] = rec.Offset
+		}
+		return nil
+	}
+
+	// leftovers of an interrupted compaction must not end up in the new segments
+	tmpDir := path.Join(log.dir, compactionDir)
+	log.mux.RLock()
+	if err := log.checkOpen(); err != nil {
+		log.mux.RUnlock()
+		return stats, err
+	}
+	err := os.RemoveAll(tmpDir)
+	if err == nil {
+		err = os.MkdirAll(tmpDir, os.ModePerm)
+	}
+	if err == nil {
+		err = log.activeSegment.forEach(scan)
+	}
+	// sealed segments don't change until they are swapped with their rewritten copy
+	sealed := append([]*fileSegment(nil), log.segments[:len(log.segments)-1]...)
+	log.mux.RUnlock()
+	defer os.RemoveAll(tmpDir)
+	if err != nil {
+		return stats, err
+	}
+
+	// a key whose latest record was appended after the scan keeps an older record until the next compaction
+	for _, segment := range sealed {
+		if err = segment.forEach(scan); err != nil {
+			if removed, checkErr := log.segmentRemoved(segment); checkErr != nil || !removed {
+				return stats, err
+			}
+		}
+	}
+
+	now := time.Now()
+	keep := func(rec *api.LogRecord) bool {
+		if len(rec.Key) == 0 {
+			return true
+		}
+		if latest[string(rec.Key)] != rec.Offset {
+			return false
+		}
+		if len(rec.Value) == 0 {
+			return now.Sub(time.Unix(0, rec.Timestamp)) < log.config.Compaction.TombstoneRetention
+		}
+		return true
+	}
+
+	for _, segment := range sealed {
+		bytesBefore := segment.store.size + segment.index.size
+		removed, err := log.rewriteSegment(tmpDir, segment, keep)
+		if err == nil && removed > 0 {
+			var compacted *fileSegment
+			if compacted, err = log.swapSegment(tmpDir, segment); err == nil && compacted != nil {
+				stats.Segments++
+				stats.RemovedRecords += removed
+				stats.BytesBefore += bytesBefore
+				stats.BytesAfter += compacted.store.size + compacted.index.size
+			}
+		}
+		removeStagedSegment(tmpDir, segment.startOffset)
+		if err != nil {
+			if removed, checkErr := log.segmentRemoved(segment); checkErr != nil || !removed {
+				return stats, err
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// segmentRemoved tells whether the segment was removed from the log, which fails once closed
+func (log *Log) segmentRemoved(segment *fileSegment) (bool, error) {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	if err := log.checkOpen(); err != nil {
+		return false, err
+	}
+	return log.positionOf(segment) < 0, nil
+}
+
+// positionOf returns the position of the segment in the log, -1 when it isn't part of it
+// must be called with the lock held
+func (log *Log) positionOf(segment *fileSegment) int {
+	for i, s := range log.segments {
+		if s == segment {
+			return i
+		}
+	}
+	return -1
+}
+
+// compactionConfig is the config of the rewritten copy of the segment, which keeps the data key of the original one
+func (log *Log) compactionConfig(segment *fileSegment) Config {
+	config := log.config
+	if segment.keyFile == "" {
+		config.Keys = nil
+	}
+	return config
+}
+
+// rewriteSegment writes the records of the segment to keep to a copy of it in tmpDir, the segment itself is left
+// untouched; returns the number of records left out, the copy is only written when there are some
+func (log *Log) rewriteSegment(tmpDir string, segment *fileSegment, keep func(*api.LogRecord) bool) (uint64, error) {
+	var removed uint64
+	err := segment.forEach(func(rec *api.LogRecord) error {
+		if !keep(rec) {
+			removed++
+		}
+		return nil
+	})
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+
+	// the rewritten segment keeps the data key of the original one, its key file is left in place
+	config := log.compactionConfig(segment)
+	if segment.keyFile != "" {
+		if err = copyFile(segment.keyFile, path.Join(tmpDir, path.Base(segment.keyFile))); err != nil {
+			return 0, err
+		}
+	}
+
+	compacted, err := newFileSegment(tmpDir, segment.startOffset, config)
+	if err != nil {
+		return 0, err
+	}
+	// the records left of a compressed batch are compressed together again
+	err = segment.forEachEntry(func(entry *storeEntry) error {
+		kept := make([]*api.LogRecord, 0, len(entry.records))
+		for _, rec := range entry.records {
+			if keep(rec) {
+				kept = append(kept, rec)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		if entry.compression != api.Compression_NO_COMPRESSION {
+			return compacted.appendBatch(kept, entry.compression)
+		}
+		for _, rec := range kept {
+			if err := compacted.appendRecord(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = compacted.Close()
+		return 0, err
+	}
+	// closing the copy syncs it before it replaces the original files
+	if err = compacted.Close(); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// swapSegment moves the rewritten copy of the segment from tmpDir in place of its files and replaces the segment
+// with it, the original segment is closed once the copy serves its reads; returns nil when the segment was removed
+// from the log while it was rewritten
+func (log *Log) swapSegment(tmpDir string, segment *fileSegment) (*fileSegment, error) {
+	log.mux.Lock()
+	defer log.mux.Unlock()
+
+	if err := log.checkOpen(); err != nil {
+		return nil, err
+	}
+	i := log.positionOf(segment)
+	if i < 0 {
+		return nil, nil
+	}
+
+	// a crash between the renames is repaired when the segment is opened, the store is renamed first
+	// the original segment keeps reading the files it opened until it is closed
+	for _, ext := range []string{"store", "index", "timeindex"} {
+		name := fmt.Sprintf("%d.%s", segment.startOffset, ext)
+		if err := os.Rename(path.Join(tmpDir, name), path.Join(log.dir, name)); err != nil {
+			return nil, err
+		}
+	}
+
+	compacted, err := newFileSegment(log.dir, segment.startOffset, log.compactionConfig(segment))
+	if err != nil {
+		return nil, err
+	}
+	// the segment keeps its range of offsets even if its last records were removed
+	compacted.nextOffset = segment.nextOffset
+	log.segments[i] = compacted
+	_ = segment.Close()
+
+	return compacted, nil
+}
+
+// removeStagedSegment removes what is left in tmpDir of the rewritten copy of the segment starting at baseOffset
+func removeStagedSegment(tmpDir string, baseOffset uint64) {
+	for _, ext := range []string{"store", "index", "timeindex", "key"} {
+		_ = os.Remove(path.Join(tmpDir, fmt.Sprintf("%d.%s", baseOffset, ext)))
+	}
+}
+
+func (log *Log) compactPeriodically() {
+	ticker := time.NewTicker(log.config.Compaction.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-log.done:
+			return
+		case <-ticker.C:
+			stats, err := log.Compact()
+			if err != nil {
+				log.logger.Error("failed to compact log", zap.Error(err))
+			}
+			if stats.Segments > 0 {
+				log.logger.Info(
+					"compacted log",
+					zap.Uint64("segments", stats.Segments),
+					zap.Uint64("removed_records", stats.RemovedRecords),
+					zap.Uint64("bytes_before", stats.BytesBefore),
+					zap.Uint64("bytes_after", stats.BytesAfter),
+				)
+			}
+		}
+	}
+}
+
+func copyFile(src string, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return writeFileSync(dst, data)
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLog_Compact(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-compaction")
+	defer os.RemoveAll(dir)
+
+	config := Config{
+		MaxStoreBytes: 1024,
+		MaxIndexBytes: entryWidth * 3,
+		Compaction:    Compaction{TombstoneRetention: time.Hour},
+	}
+	log, err := NewLog(dir, config)
+	assert.NoError(t, err)
+
+	old := time.Now().Add(-2 * time.Hour).UnixNano()
+	records := []*api.LogRecord{
+		{Key: []byte("a"), Value: []byte("a1")}, // 0 replaced
+		{Key: []byte("b"), Value: []byte("b1")}, // 1 replaced
+		{Value: []byte("unkeyed")},              // 2
+		{Key: []byte("a"), Value: []byte("a2")}, // 3 replaced
+		{Key: []byte("c"), Value: []byte("c1")}, // 4 deleted
+		{Key: []byte("b"), Value: []byte("b2")}, // 5
+		{Key: []byte("c")},                      // 6 tombstone kept
+		{Key: []byte("d"), Value: []byte("d1")}, // 7 deleted
+		{Key: []byte("d"), Timestamp: old},      // 8 expired tombstone
+		{Key: []byte("a"), Value: []byte("a3")}, // 9 active segment
+	}
+	for _, rec := range records {
+		_, err = log.Append(rec)
+		assert.NoError(t, err)
+	}
+
+	stats, err := log.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.Segments)
+	assert.Equal(t, uint64(6), stats.RemovedRecords)
+	assert.Less(t, stats.BytesAfter, stats.BytesBefore)
+
+	check := func(log *Log) {
+		// offsets left are the same as before compaction, removed ones resolve to the next record
+		expected := map[uint64]uint64{0: 2, 1: 2, 2: 2, 3: 5, 4: 5, 5: 5, 6: 6, 7: 9, 8: 9, 9: 9}
+		for requested, offset := range expected {
+			rec, err := log.Read(requested)
+			assert.NoError(t, err)
+			assert.Equal(t, offset, rec.Offset, "reading offset %d", requested)
+			assert.Equal(t, records[offset].Value, rec.Value)
+		}
+
+		_, err = log.Read(10)
+		assert.Error(t, err)
+
+		low, high := log.Offsets()
+		assert.Equal(t, uint64(0), low)
+		assert.Equal(t, uint64(9), high)
+	}
+
+	check(log)
+
+	// nothing left to remove
+	stats, err = log.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), stats.Segments)
+
+	assert.NoError(t, log.Close())
+
+	reports, err := Verify(dir, false, config)
+	assert.NoError(t, err)
+	for _, r := range reports {
+		assert.Empty(t, r.Errors)
+	}
+
+	log, err = NewLog(dir, config)
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	assert.Empty(t, log.Recovered())
+	check(log)
+
+	off, err := log.Append(&api.LogRecord{Value: []byte("after compaction")})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), off)
+}
+
+func TestLog_CompactConcurrently(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-compaction")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxStoreBytes: 1024, MaxIndexBytes: entryWidth * 3})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	for i := 0; i < 30; i++ {
+		_, err = log.Append(&api.LogRecord{Key: []byte(fmt.Sprintf("key-%d", i%3)), Value: []byte("before")})
+		assert.NoError(t, err)
+	}
+
+	// records keep being appended and read while the sealed segments are rewritten
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 30; i++ {
+			_, err := log.Append(&api.LogRecord{Value: []byte("during")})
+			assert.NoError(t, err)
+			_, err = log.Read(0)
+			assert.NoError(t, err)
+		}
+	}()
+	stats, err := log.Compact()
+	<-done
+	assert.NoError(t, err)
+	assert.NotZero(t, stats.RemovedRecords)
+
+	// the latest record of every key and the ones appended during the compaction are left
+	for i := uint64(27); i < 60; i++ {
+		rec, err := log.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, i, rec.Offset)
+	}
+
+	// segments removed while they are rewritten are skipped
+	_, err = log.Append(&api.LogRecord{Key: []byte("key-0"), Value: []byte("after")})
+	assert.NoError(t, err)
+	truncated := make(chan error)
+	go func() {
+		truncated <- log.Truncate(40)
+	}()
+	_, err = log.Compact()
+	assert.NoError(t, err)
+	assert.NoError(t, <-truncated)
+	rec, err := log.Read(60)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("after"), rec.Value)
+}
@@ -1,7 +1,74 @@
 package log
 
+import (
+	"EchoLog/api/v1"
+	"time"
+)
+
 type Config struct {
 	MaxStoreBytes uint64
 	MaxIndexBytes uint64
-	InitialOffset uint64
+	// minimum number of store bytes between two entries of the offset index, reads scan the store forward from the
+	// closest entry; 0 indexes every record
+	IndexIntervalBytes uint64
+	InitialOffset      uint64
+	// when appended records are forced to stable storage
+	Durability Durability
+	// number of records between two syncs with DurabilityEveryN, defaults to 1
+	SyncEveryRecords uint64
+	// time between two syncs with DurabilityInterval, defaults to 100ms
+	SyncInterval time.Duration
+	Retention    Retention
+	// minimum number of store bytes between two entries of the time index, defaults to 4096
+	TimeIndexIntervalBytes uint64
+	Compaction             Compaction
+	// how the records appended together are compressed when the append doesn't say, defaults to no compression
+	Compression api.Compression
+	// when set, the records of new segments are encrypted under a data key wrapped with the current master key
+	// segments encrypted before keep being read with the master key they were wrapped with
+	Keys KeyProvider
+	// idempotent producers which neither registered nor appended for this long are forgotten, their appends fail with
+	// api.ErrUnknownProducer until they register again; defaults to 7 days
+	ProducerExpiration time.Duration
+}
+
+// Compaction rewrites sealed segments keeping only the latest record of every key, see Log.Compact
+type Compaction struct {
+	// runs the compaction in a background goroutine every CheckInterval
+	Enabled bool
+	// tombstones are kept this long after their timestamp so consumers get to see the deletion
+	TombstoneRetention time.Duration
+	// time between two compactions, defaults to 1 minute
+	CheckInterval time.Duration
 }
+
+// Retention rules are enforced by a background goroutine which removes whole sealed segments, oldest first,
+// the active segment is never removed
+// the goroutine only runs when MaxAge or MaxBytes is set
+type Retention struct {
+	// sealed segments whose last write is older than this are removed
+	MaxAge time.Duration
+	// oldest sealed segments are removed while the log takes more bytes than this
+	MaxBytes uint64
+	// a segment is kept if removing it would leave fewer records than this in the log
+	MinRecords uint64
+	// time between two checks, defaults to 1 minute
+	CheckInterval time.Duration
+}
+
+// with DurabilityAlways and DurabilityEveryN an append whose sync fails returns the error although its records were
+// appended and can be read; they are synced again along with the next append, which may still lose them if the OS
+// dropped the pages it failed to write
+type Durability int
+
+const (
+	// records are buffered and written out whenever the log needs to read them back or is closed,
+	// the OS decides when they reach the disk
+	DurabilityOS Durability = iota
+	// Append returns once the record was fsynced, concurrent appenders share a single fsync
+	DurabilityAlways
+	// every SyncEveryRecords-th Append fsyncs everything appended before it and waits for it
+	DurabilityEveryN
+	// a background goroutine fsyncs the log every SyncInterval, Append never waits
+	DurabilityInterval
+)
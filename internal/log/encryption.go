@@ -0,0 +1,238 @@
+package log
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+)
+
+// the records of an encrypted segment are sealed with AES-GCM under a data key of its own
+// the data key is stored in <base offset>.key, wrapped with a master key supplied by Config.Keys
+// segments written before Config.Keys was set, or stored in plaintext for any other reason, have no key file
+
+const dataKeySize = 32
+
+// KeyProvider supplies the master keys wrapping the data keys of the segments, see Config.Keys
+type KeyProvider interface {
+	// CurrentKey returns the master key wrapping the data keys of new segments, and its id
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the master key with the given id, it must be known as long as segments wrapped with it exist
+	Key(id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider holding its master keys in memory
+// master keys are AES keys: 16, 24 or 32 bytes long
+type KeyRing struct {
+	mux     sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+var _ KeyProvider = (*KeyRing)(nil)
+
+func NewKeyRing(id string, key []byte) *KeyRing {
+	return &KeyRing{
+		current: id,
+		keys:    map[string][]byte{id: key},
+	}
+}
+
+// Rotate adds a master key and makes it the current one
+// segments created from now on are wrapped with it, the existing ones keep the key they were wrapped with
+func (r *KeyRing) Rotate(id string, key []byte) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.keys[id] = key
+	r.current = id
+}
+
+func (r *KeyRing) CurrentKey() (string, []byte, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return r.current, r.keys[r.current], nil
+}
+
+func (r *KeyRing) Key(id string) ([]byte, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", id)
+	}
+	return key, nil
+}
+
+// segmentKey is the content of a key file
+type segmentKey struct {
+	// id of the master key the data key is wrapped with
+	KeyID string
+	// [nonce][data key sealed with the master key]
+	WrappedKey []byte
+}
+
+func keyFileName(dir string, baseOffset uint64) string {
+	return path.Join(dir, fmt.Sprintf("%d.key", baseOffset))
+}
+
+// setupDataKey returns the cipher sealing the records of the segment and the name of its key file
+// a data key is generated for a new segment when keys is set, nil and an empty name are returned for a segment stored
+// in plaintext
+func setupDataKey(dir string, baseOffset uint64, keys KeyProvider) (cipher.AEAD, string, error) {
+	aead, name, err := loadDataKey(dir, baseOffset, keys)
+	if err != nil || aead != nil || keys == nil {
+		return aead, name, err
+	}
+
+	// records written before encryption was enabled stay in plaintext
+	if stat, err := os.Stat(path.Join(dir, fmt.Sprintf("%d.store", baseOffset))); err == nil && stat.Size() > fileHeaderSize {
+		return nil, "", nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err = rand.Read(dataKey); err != nil {
+		return nil, "", err
+	}
+	id, masterKey, err := keys.CurrentKey()
+	if err != nil {
+		return nil, "", err
+	}
+	wrapped, err := wrapDataKey(masterKey, baseOffset, dataKey)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := json.Marshal(segmentKey{KeyID: id, WrappedKey: wrapped})
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the key file must be complete before any record is sealed with it
+	name = keyFileName(dir, baseOffset)
+	if err = writeFileSync(name+".tmp", data); err != nil {
+		return nil, "", err
+	}
+	if err = os.Rename(name+".tmp", name); err != nil {
+		return nil, "", err
+	}
+
+	aead, err = newAEAD(dataKey)
+	return aead, name, err
+}
+
+// loadDataKey unwraps the data key of an existing segment, returns nil and an empty name when it has no key file
+func loadDataKey(dir string, baseOffset uint64, keys KeyProvider) (cipher.AEAD, string, error) {
+	name := keyFileName(dir, baseOffset)
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	if keys == nil {
+		return nil, "", fmt.Errorf("%s: segment is encrypted and no key provider is configured", name)
+	}
+
+	key := segmentKey{}
+	if err = json.Unmarshal(data, &key); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+	masterKey, err := keys.Key(key.KeyID)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+	dataKey, err := unwrapDataKey(masterKey, baseOffset, key.WrappedKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	return aead, name, err
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapDataKey seals the data key with the master key, the base offset is authenticated so a key file only
+// unwraps for its own segment
+func wrapDataKey(masterKey []byte, baseOffset uint64, dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, additionalData(baseOffset)), nil
+}
+
+func unwrapDataKey(masterKey []byte, baseOffset uint64, wrapped []byte) ([]byte, error) {
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	n := aead.NonceSize()
+	if len(wrapped) < n {
+		return nil, fmt.Errorf("wrapped data key too short")
+	}
+	dataKey, err := aead.Open(nil, wrapped[:n], wrapped[n:], additionalData(baseOffset))
+	if err != nil {
+		return nil, fmt.Errorf("data key can't be unwrapped: %w", err)
+	}
+	return dataKey, nil
+}
+
+// additionalData encodes the value authenticated along with sealed data
+func additionalData(v uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, v)
+	return data
+}
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir forces the entries of the directory, such as a file renamed into it, to stable storage
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err = d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,175 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+func testMasterKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func keyID(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	assert.NoError(t, err)
+	key := segmentKey{}
+	assert.NoError(t, json.Unmarshal(data, &key))
+	return key.KeyID
+}
+
+func TestLog_Encryption(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-encryption")
+	defer os.RemoveAll(dir)
+
+	keys := NewKeyRing("k1", testMasterKey(1))
+	config := Config{MaxStoreBytes: 256, MaxIndexBytes: 1024, Keys: keys}
+	log, err := NewLog(dir, config)
+	assert.NoError(t, err)
+
+	value := func(i int) []byte {
+		return []byte(fmt.Sprintf("secret-payload-%d", i))
+	}
+	for i := 0; i < 5; i++ {
+		_, err = log.Append(&api.LogRecord{Value: value(i)})
+		assert.NoError(t, err)
+	}
+	segmentsBefore := len(log.segments)
+
+	// segments created after the rotation are wrapped with the new master key
+	keys.Rotate("k2", testMasterKey(2))
+	for i := 5; i < 10; i++ {
+		_, err = log.Append(&api.LogRecord{Value: value(i)})
+		assert.NoError(t, err)
+	}
+	assert.Greater(t, len(log.segments), segmentsBefore)
+	assert.Equal(t, "k1", keyID(t, log.segments[0].keyFile))
+	assert.Equal(t, "k2", keyID(t, log.activeSegment.keyFile))
+
+	check := func(log *Log) {
+		for i := 0; i < 10; i++ {
+			rec, err := log.Read(uint64(i))
+			assert.NoError(t, err)
+			assert.Equal(t, value(i), rec.Value)
+		}
+	}
+	check(log)
+	assert.NoError(t, log.Close())
+
+	// no payload reaches the disk in plaintext
+	stores, err := filepath.Glob(path.Join(dir, "*.store"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, stores)
+	for _, name := range stores {
+		data, err := ioutil.ReadFile(name)
+		assert.NoError(t, err)
+		assert.False(t, bytes.Contains(data, []byte("secret-payload")), name)
+	}
+
+	reports, err := Verify(dir, false, config)
+	assert.NoError(t, err)
+	for _, r := range reports {
+		assert.Empty(t, r.Errors)
+		assert.True(t, r.Encrypted)
+	}
+	reports, err = Verify(dir, true, Config{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, reports[0].Errors)
+	assert.False(t, reports[0].Rebuilt)
+
+	// the old segments need the master key they were wrapped with
+	_, err = NewLog(dir, Config{MaxStoreBytes: 256, MaxIndexBytes: 1024, Keys: NewKeyRing("k2", testMasterKey(2))})
+	assert.Error(t, err)
+	_, err = NewLog(dir, Config{MaxStoreBytes: 256, MaxIndexBytes: 1024})
+	assert.Error(t, err)
+
+	log, err = NewLog(dir, config)
+	assert.NoError(t, err)
+	defer log.Remove()
+	assert.Empty(t, log.Recovered())
+	check(log)
+}
+
+func TestLog_EncryptionEnabledLater(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-encryption")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxStoreBytes: 256})
+	assert.NoError(t, err)
+	_, err = log.Append(&api.LogRecord{Value: []byte("plaintext")})
+	assert.NoError(t, err)
+	assert.NoError(t, log.Close())
+
+	log, err = NewLog(dir, Config{MaxStoreBytes: 256, Keys: NewKeyRing("k1", testMasterKey(1))})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	// the segment holding plaintext records keeps them in plaintext, the next ones are encrypted
+	for i := 0; i < 10; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte("encrypted")})
+		assert.NoError(t, err)
+	}
+	assert.Empty(t, log.segments[0].keyFile)
+	assert.NotEmpty(t, log.activeSegment.keyFile)
+
+	rec, err := log.Read(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plaintext"), rec.Value)
+	rec, err = log.Read(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("encrypted"), rec.Value)
+}
+
+func TestLog_EncryptedCompactionAndSnapshot(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-encryption")
+	defer os.RemoveAll(dir)
+
+	config := Config{MaxStoreBytes: 256, Keys: NewKeyRing("k1", testMasterKey(1))}
+	log, err := NewLog(dir, config)
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	for i := 0; i < 12; i++ {
+		_, err = log.Append(&api.LogRecord{Key: []byte{byte('a' + i%3)}, Value: []byte(fmt.Sprintf("value-%d", i))})
+		assert.NoError(t, err)
+	}
+	stats, err := log.Compact()
+	assert.NoError(t, err)
+	assert.NotZero(t, stats.RemovedRecords)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, log.Snapshot(buf))
+
+	restoredDir, _ := ioutil.TempDir(os.TempDir(), "log-encryption")
+	defer os.RemoveAll(restoredDir)
+	restored, err := NewLog(restoredDir, config)
+	assert.NoError(t, err)
+	defer restored.Remove()
+	assert.NoError(t, restored.Restore(buf))
+
+	// the compacted segments kept their data keys and travel with them
+	for _, l := range []*Log{log, restored} {
+		for offset, expected := range map[uint64]string{0: "value-9", 9: "value-9", 10: "value-10", 11: "value-11"} {
+			rec, err := l.Read(offset)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, string(rec.Value))
+		}
+	}
+
+	// a key file only unwraps for its own segment
+	other := log.segments[1]
+	data, err := ioutil.ReadFile(log.segments[0].keyFile)
+	assert.NoError(t, err)
+	copyDir, _ := ioutil.TempDir(os.TempDir(), "log-encryption")
+	defer os.RemoveAll(copyDir)
+	assert.NoError(t, ioutil.WriteFile(keyFileName(copyDir, other.startOffset), data, 0600))
+	_, _, err = loadDataKey(copyDir, other.startOffset, config.Keys)
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// every segment file starts with a header:
+// [magic][format version uint16][kind uint8][flags uint8][base offset uint64][created at int64 unix ns][reserved 8]
+// the stores and the indexes hide it: store positions and index entries are relative to the end of the header
+const (
+	fileHeaderSize = 32
+	// version of the layout of the segment files written by this package
+	formatVersion uint16 = 1
+)
+
+var fileMagic = []byte("ECHL")
+
+type fileKind uint8
+
+const (
+	storeFileKind fileKind = iota + 1
+	indexFileKind
+	timeIndexFileKind
+)
+
+func (k fileKind) String() string {
+	switch k {
+	case storeFileKind:
+		return "store"
+	case indexFileKind:
+		return "index"
+	case timeIndexFileKind:
+		return "timeindex"
+	}
+	return fmt.Sprintf("kind %d", k)
+}
+
+// the records of the store are sealed with the data key of the segment
+const fileFlagEncrypted uint8 = 1
+
+// ErrLegacyFormat is returned when opening a segment file written before the files had a header, Migrate upgrades it
+var ErrLegacyFormat = errors.New("segment file has no header, it was written by an older version and must be migrated")
+
+// ErrUnsupportedFormat is returned when opening a segment file written in a layout this version doesn't know about
+var ErrUnsupportedFormat = errors.New("unsupported segment file format")
+
+type fileHeader struct {
+	Version    uint16
+	Kind       fileKind
+	Flags      uint8
+	BaseOffset uint64
+	CreatedAt  time.Time
+}
+
+func (h fileHeader) encode() []byte {
+	data := make([]byte, fileHeaderSize)
+	copy(data, fileMagic)
+	binary.BigEndian.PutUint16(data[4:6], h.Version)
+	data[6] = byte(h.Kind)
+	data[7] = h.Flags
+	binary.BigEndian.PutUint64(data[8:16], h.BaseOffset)
+	binary.BigEndian.PutUint64(data[16:24], uint64(h.CreatedAt.UnixNano()))
+	return data
+}
+
+// decodeFileHeader parses the beginning of a segment file
+func decodeFileHeader(data []byte) (fileHeader, error) {
+	if len(data) < len(fileMagic) || string(data[:len(fileMagic)]) != string(fileMagic) {
+		return fileHeader{}, ErrLegacyFormat
+	}
+	if len(data) < fileHeaderSize {
+		return fileHeader{}, fmt.Errorf("%w: truncated header", ErrUnsupportedFormat)
+	}
+
+	h := fileHeader{
+		Version:    binary.BigEndian.Uint16(data[4:6]),
+		Kind:       fileKind(data[6]),
+		Flags:      data[7],
+		BaseOffset: binary.BigEndian.Uint64(data[8:16]),
+		CreatedAt:  time.Unix(0, int64(binary.BigEndian.Uint64(data[16:24]))),
+	}
+	if h.Version == 0 || h.Version > formatVersion {
+		return h, fmt.Errorf("%w: version %d, this build reads versions up to %d", ErrUnsupportedFormat, h.Version, formatVersion)
+	}
+	return h, nil
+}
+
+// readFileHeader reads the header of a segment file and checks it describes the expected file
+func readFileHeader(f *os.File, kind fileKind, baseOffset uint64) (fileHeader, error) {
+	data := make([]byte, fileHeaderSize)
+	n, err := f.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return fileHeader{}, err
+	}
+	return checkFileHeader(f.Name(), data[:n], kind, baseOffset)
+}
+
+// checkFileHeader decodes the header at the beginning of the data of the named file and checks it describes the
+// expected file
+func checkFileHeader(name string, data []byte, kind fileKind, baseOffset uint64) (fileHeader, error) {
+	h, err := decodeFileHeader(data)
+	if err != nil {
+		return h, fmt.Errorf("%s: %w", name, err)
+	}
+	if h.Kind != kind || h.BaseOffset != baseOffset {
+		return h, fmt.Errorf("%s: %w: header of the %s file of segment %d", name, ErrUnsupportedFormat, h.Kind, h.BaseOffset)
+	}
+	return h, nil
+}
+
+// setupFileHeader writes the header of a new, empty, segment file or reads the one of an existing file
+// a file holding nothing but its header takes the given flags, the data written after it will follow them
+func setupFileHeader(f *os.File, kind fileKind, baseOffset uint64, flags uint8) (fileHeader, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return fileHeader{}, err
+	}
+
+	h := fileHeader{
+		Version:    formatVersion,
+		Kind:       kind,
+		Flags:      flags,
+		BaseOffset: baseOffset,
+		CreatedAt:  time.Now(),
+	}
+	if stat.Size() > 0 {
+		existing, err := readFileHeader(f, kind, baseOffset)
+		if err != nil || existing.Flags == flags || stat.Size() > fileHeaderSize {
+			return existing, err
+		}
+		h.CreatedAt = existing.CreatedAt
+		if err = f.Truncate(0); err != nil {
+			return existing, err
+		}
+	}
+
+	if _, err = f.Write(h.encode()); err != nil {
+		return h, err
+	}
+	return h, nil
+}
@@ -0,0 +1,208 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestFileHeader(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_header")
+	defer os.RemoveAll(dir)
+
+	config := Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024}
+	segm, err := newFileSegment(dir, 16, config)
+	assert.NoError(t, err)
+	_, err = segm.Append(&api.LogRecord{Value: []byte("headed")})
+	assert.NoError(t, err)
+	assert.NoError(t, segm.Close())
+
+	storePath := path.Join(dir, "16.store")
+	data, err := ioutil.ReadFile(storePath)
+	assert.NoError(t, err)
+	h, err := decodeFileHeader(data)
+	assert.NoError(t, err)
+	assert.Equal(t, formatVersion, h.Version)
+	assert.Equal(t, storeFileKind, h.Kind)
+	assert.Equal(t, uint64(16), h.BaseOffset)
+	assert.Zero(t, h.Flags)
+	assert.False(t, h.CreatedAt.IsZero())
+
+	// a file written by a newer version is refused
+	data[5]++
+	assert.NoError(t, ioutil.WriteFile(storePath, data, 0644))
+	_, err = newFileSegment(dir, 16, config)
+	assert.True(t, errors.Is(err, ErrUnsupportedFormat))
+	assert.Contains(t, err.Error(), "version 2")
+
+	// so is the file of another segment
+	data[5]--
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "17.store"), data, 0644))
+	_, err = newFileSegment(dir, 17, config)
+	assert.True(t, errors.Is(err, ErrUnsupportedFormat))
+
+	// and a file without a header
+	assert.NoError(t, ioutil.WriteFile(storePath, data[fileHeaderSize:], 0644))
+	_, err = newFileSegment(dir, 16, config)
+	assert.True(t, errors.Is(err, ErrLegacyFormat))
+}
+
+func TestMigrate(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_migrate")
+	defer os.RemoveAll(dir)
+
+	keys := NewKeyRing("k1", make([]byte, 32))
+	config := Config{MaxStoreBytes: 256, MaxIndexBytes: entryWidth * 3, Keys: keys}
+	log, err := NewLog(dir, config)
+	assert.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record-%d", i))})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, log.Close())
+
+	// strip the headers to get the files an older version would have written
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	legacy := 0
+	for _, f := range files {
+		if path.Ext(f.Name()) == ".key" {
+			continue
+		}
+		name := path.Join(dir, f.Name())
+		data, err := ioutil.ReadFile(name)
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(name, data[fileHeaderSize:], 0644))
+		legacy++
+	}
+
+	_, err = NewLog(dir, config)
+	assert.True(t, errors.Is(err, ErrLegacyFormat))
+	reports, err := Verify(dir, true, config)
+	assert.NoError(t, err)
+	assert.Contains(t, reports[0].Errors[0], ErrLegacyFormat.Error())
+	assert.False(t, reports[0].Rebuilt)
+
+	migrated, err := Migrate(dir)
+	assert.NoError(t, err)
+	assert.Len(t, migrated, legacy)
+
+	// migrating twice changes nothing
+	migrated, err = Migrate(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, migrated)
+
+	reports, err = Verify(dir, false, config)
+	assert.NoError(t, err)
+	for _, r := range reports {
+		assert.Empty(t, r.Errors)
+		assert.True(t, r.Encrypted)
+	}
+
+	log, err = NewLog(dir, config)
+	assert.NoError(t, err)
+	defer log.Remove()
+	assert.Empty(t, log.Recovered())
+	for i := 0; i < 7; i++ {
+		rec, err := log.Read(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(fmt.Sprintf("record-%d", i)), rec.Value)
+	}
+}
+
+func TestMigrate_BaselineStore(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_migrate")
+	defer os.RemoveAll(dir)
+
+	// the first version framed the records with their length only and indexed every one of them
+	start := time.Unix(1000, 0)
+	counts := map[uint64]uint64{0: 3, 3: 2}
+	for baseOffset, count := range counts {
+		var recs []*api.LogRecord
+		var index []byte
+		var pos uint64
+		for offset := baseOffset; offset < baseOffset+count; offset++ {
+			rec := &api.LogRecord{
+				Value:     []byte(fmt.Sprintf("record-%d", offset)),
+				Offset:    offset,
+				Timestamp: start.Add(time.Duration(offset) * time.Second).UnixNano(),
+			}
+			recs = append(recs, rec)
+			index = appendIndexEntry(index, uint32(offset-baseOffset), pos)
+			pos += recordLengthByteSize + uint64(proto.Size(rec))
+		}
+		writeBaselineStore(t, path.Join(dir, fmt.Sprintf("%d.store", baseOffset)), recs)
+		assert.NoError(t, ioutil.WriteFile(path.Join(dir, fmt.Sprintf("%d.index", baseOffset)), index, 0644))
+	}
+
+	_, err := NewLog(dir, Config{})
+	assert.True(t, errors.Is(err, ErrLegacyFormat))
+
+	migrated, err := Migrate(dir)
+	assert.NoError(t, err)
+	assert.Len(t, migrated, 6)
+
+	// migrating twice changes nothing
+	migrated, err = Migrate(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, migrated)
+
+	reports, err := Verify(dir, false, Config{})
+	assert.NoError(t, err)
+	for _, r := range reports {
+		assert.Empty(t, r.Errors)
+		assert.Equal(t, counts[r.BaseOffset], r.Records)
+	}
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+	defer log.Remove()
+	assert.Empty(t, log.Recovered())
+	for i := uint64(0); i < 5; i++ {
+		rec, err := log.Read(i)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(fmt.Sprintf("record-%d", i)), rec.Value)
+	}
+
+	offset, err := log.OffsetForTime(start.Add(4 * time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), offset)
+
+	offset, err = log.Append(&api.LogRecord{Value: []byte("after-migration")})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), offset)
+}
+
+func TestMigrate_UnknownFraming(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_migrate")
+	defer os.RemoveAll(dir)
+
+	// framed neither with a checksum nor as the records of the first version
+	store := make([]byte, 64)
+	for i := range store {
+		store[i] = 0xff
+	}
+	binary.BigEndian.PutUint64(store, 16)
+	index := make([]byte, entryWidth)
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "0.store"), store, 0644))
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "0.index"), index, 0644))
+
+	migrated, err := Migrate(dir)
+	assert.True(t, errors.Is(err, ErrUnsupportedFormat))
+	assert.Empty(t, migrated)
+
+	data, err := ioutil.ReadFile(path.Join(dir, "0.store"))
+	assert.NoError(t, err)
+	assert.Equal(t, store, data)
+	data, err = ioutil.ReadFile(path.Join(dir, "0.index"))
+	assert.NoError(t, err)
+	assert.Equal(t, index, data)
+}
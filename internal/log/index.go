@@ -2,9 +2,11 @@ package log
 
 import (
 	"encoding/binary"
+	"fmt"
 	"github.com/edsrzf/mmap-go"
 	"io"
 	"os"
+	"sort"
 )
 
 var (
@@ -13,25 +15,28 @@ var (
 	entryWidth         = offsetWidth + posWidth
 )
 
+// newFileIndex expects the file to start with its header, the entries are mapped right after it
 func newFileIndex(f *os.File, maxByteSize uint64) (*fileIndex, error) {
 
 	if fi, err := os.Stat(f.Name()); err != nil {
 		return nil, err
+	} else if fi.Size() < fileHeaderSize {
+		return nil, fmt.Errorf("%s: %w", f.Name(), ErrLegacyFormat)
 	} else {
 		idx := &fileIndex{
 			file: f,
-			size: uint64(fi.Size()),
+			size: uint64(fi.Size()) - fileHeaderSize,
 		}
 
-		if err = os.Truncate(f.Name(), int64(maxByteSize)); err != nil {
+		if err = os.Truncate(f.Name(), int64(maxByteSize)+fileHeaderSize); err != nil {
 			return nil, err
 		}
 
-		fi, _ = os.Stat(f.Name())
 		idx.mmap, err = mmap.Map(f, mmap.RDWR, 0)
 		if err != nil {
 			return nil, err
 		}
+		idx.data = idx.mmap[fileHeaderSize:]
 
 		return idx, nil
 	}
@@ -42,6 +47,8 @@ type fileIndex struct {
 	file *os.File
 	size uint64
 	mmap mmap.MMap
+	// the entries, the mapping past the header of the file
+	data []byte
 }
 
 func (fi *fileIndex) Name() string {
@@ -57,7 +64,13 @@ func (fi *fileIndex) Close() error {
 		return err
 	}
 
-	if err := fi.file.Truncate(int64(fi.size)); err != nil {
+	// segments are closed while the process keeps running, by retention and compaction among others
+	if err := fi.mmap.Unmap(); err != nil {
+		return err
+	}
+	fi.data = nil
+
+	if err := fi.file.Truncate(int64(fi.size) + fileHeaderSize); err != nil {
 		return err
 	}
 
@@ -74,21 +87,84 @@ func (fi *fileIndex) Read(entryIndex int32) (outIndex uint32, pos uint64, err er
 		entryIndex = int32(fi.size/entryWidth - 1) // last entry
 	}
 
-	entryOffset := uint64(entryIndex) * entryWidth
+	outIndex, pos = readEntry(fi.data, uint64(entryIndex))
+	return
+}
+
+// readEntry decodes the entry stored at position i of raw index bytes
+func readEntry(data []byte, i uint64) (offset uint32, pos uint64) {
+	entryOffset := i * entryWidth
 
-	outIndex = binary.BigEndian.Uint32(fi.mmap[entryOffset : entryOffset+offsetWidth])
-	pos = binary.BigEndian.Uint64(fi.mmap[entryOffset+offsetWidth : entryOffset+entryWidth])
+	offset = binary.BigEndian.Uint32(data[entryOffset : entryOffset+offsetWidth])
+	pos = binary.BigEndian.Uint64(data[entryOffset+offsetWidth : entryOffset+entryWidth])
 	return
 }
 
+// writtenEntries counts the leading entries of raw index bytes which were actually written
+// an index file keeps its pre-allocated size after a crash and its zero-filled tail was never written
+// offsets and positions grow strictly from one entry to the next, compaction may leave gaps between offsets
+func writtenEntries(data []byte) uint64 {
+	var n uint64
+	var prevOff uint32
+	var prevPos uint64
+	for (n+1)*entryWidth <= uint64(len(data)) {
+		off, pos := readEntry(data, n)
+		if n > 0 && (off <= prevOff || pos <= prevPos) {
+			break
+		}
+		prevOff, prevPos = off, pos
+		n++
+	}
+	return n
+}
+
+// floor returns the number of the last entry whose offset is at or before the given one
+// the second return value is false when all the entries are after it
+func (fi *fileIndex) floor(offset uint32) (uint64, bool) {
+	entries := fi.entries()
+
+	// entries of dense indexes of segments which weren't compacted are numbered by their offset
+	if uint64(offset) < entries {
+		if off, _ := readEntry(fi.data, uint64(offset)); off == offset {
+			return uint64(offset), true
+		}
+	}
+
+	n := uint64(sort.Search(int(entries), func(i int) bool {
+		off, _ := readEntry(fi.data, uint64(i))
+		return off > offset
+	}))
+	if n == 0 {
+		return 0, false
+	}
+	return n - 1, true
+}
+
 func (fi *fileIndex) Write(offset uint32, pos uint64) error {
-	if uint64(len(fi.mmap)) < fi.size+entryWidth {
+	if uint64(len(fi.data)) < fi.size+entryWidth {
 		return io.EOF
 	}
-	binary.BigEndian.PutUint32(fi.mmap[fi.size:fi.size+offsetWidth], offset)
-	binary.BigEndian.PutUint64(fi.mmap[fi.size+offsetWidth:fi.size+entryWidth], pos)
+	binary.BigEndian.PutUint32(fi.data[fi.size:fi.size+offsetWidth], offset)
+	binary.BigEndian.PutUint64(fi.data[fi.size+offsetWidth:fi.size+entryWidth], pos)
 
 	fi.size += entryWidth
 
 	return nil
 }
+
+// number of complete entries currently held by the index
+func (fi *fileIndex) entries() uint64 {
+	return fi.size / entryWidth
+}
+
+// truncate drops every entry from position n onwards and zeroes their bytes in the mapping
+func (fi *fileIndex) truncate(n uint64) {
+	size := n * entryWidth
+	if size > fi.size {
+		return
+	}
+	for i := size; i < fi.size && i < uint64(len(fi.data)); i++ {
+		fi.data[i] = 0
+	}
+	fi.size = size
+}
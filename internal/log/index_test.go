@@ -12,6 +12,8 @@ func TestIndex(t *testing.T) {
 	assert.NoError(t, err)
 	defer os.Remove(f.Name())
 
+	_, err = setupFileHeader(f, indexFileKind, 0, 0)
+	assert.NoError(t, err)
 	idx, err := newFileIndex(f, 1024)
 	assert.NoError(t, err)
 
@@ -37,7 +39,9 @@ func TestIndex(t *testing.T) {
 		assert.Equal(t, x.Pos, pos)
 	}
 
-	_ = idx.Close()
+	// the mapping is released along with the file
+	assert.NoError(t, idx.Close())
+	assert.Nil(t, idx.mmap)
 
 	f, err = os.OpenFile(f.Name(), os.O_RDWR, 0600)
 	assert.NoError(t, err)
@@ -56,3 +60,31 @@ func TestIndex(t *testing.T) {
 	assert.Equal(t, x.Off, off)
 	assert.Equal(t, x.Pos, pos)
 }
+
+func TestIndex_Floor(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "index.tmp")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = setupFileHeader(f, indexFileKind, 0, 0)
+	assert.NoError(t, err)
+	idx, err := newFileIndex(f, 1024)
+	assert.NoError(t, err)
+	defer idx.Close()
+
+	_, ok := idx.floor(0)
+	assert.False(t, ok)
+
+	// a sparse index starting after offset 0, as left by compaction
+	for _, off := range []uint32{2, 5, 9} {
+		assert.NoError(t, idx.Write(off, uint64(off)*10))
+	}
+
+	_, ok = idx.floor(1)
+	assert.False(t, ok)
+	for offset, expected := range map[uint32]uint64{2: 0, 4: 0, 5: 1, 8: 1, 9: 2, 100: 2} {
+		entry, ok := idx.floor(offset)
+		assert.True(t, ok)
+		assert.Equal(t, expected, entry, "offset %d", offset)
+	}
+}
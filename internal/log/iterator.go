@@ -0,0 +1,64 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"io"
+	"sync"
+)
+
+// Iterator walks the decoded records of a log in offset order, across segment boundaries
+// it only holds a position, so it keeps working while records are appended and segments are removed
+type Iterator struct {
+	log    *Log
+	mux    sync.Mutex
+	offset uint64
+}
+
+// Iterator returns an iterator positioned at the given offset
+func (log *Log) Iterator(from uint64) *Iterator {
+	return &Iterator{log: log, offset: from}
+}
+
+// Next returns the record at the position of the iterator and moves past it
+// returns io.EOF once every record currently in the log was read, records appended afterwards are returned by the
+// following calls; records compacted away are skipped and so are the ones removed by retention or Truncate before
+// the iterator got to them
+func (it *Iterator) Next() (*api.LogRecord, error) {
+	it.mux.Lock()
+	defer it.mux.Unlock()
+
+	it.log.mux.RLock()
+	defer it.log.mux.RUnlock()
+
+	if len(it.log.segments) > 0 {
+		if low := it.log.segments[0].startOffset; it.offset < low {
+			it.offset = low
+		}
+	}
+
+	rec, err := it.log.read(it.offset)
+	if _, ok := err.(api.ErrOffsetOutOfRange); ok {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, err
+	}
+
+	it.offset = rec.Offset + 1
+	return rec, nil
+}
+
+// Seek moves the iterator to the given offset
+func (it *Iterator) Seek(offset uint64) {
+	it.mux.Lock()
+	defer it.mux.Unlock()
+
+	it.offset = offset
+}
+
+// Offset returns the offset of the next record the iterator will try to read
+func (it *Iterator) Offset() uint64 {
+	it.mux.Lock()
+	defer it.mux.Unlock()
+
+	return it.offset
+}
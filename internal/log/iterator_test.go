@@ -0,0 +1,56 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-iterator")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxIndexBytes: entryWidth * 3})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	appendRecords := func(from, to int) {
+		for i := from; i < to; i++ {
+			_, err := log.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record-%d", i))})
+			assert.NoError(t, err)
+		}
+	}
+	appendRecords(0, 7)
+
+	it := log.Iterator(1)
+	for i := 1; i < 7; i++ {
+		rec, err := it.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), rec.Offset)
+		assert.Equal(t, []byte(fmt.Sprintf("record-%d", i)), rec.Value)
+	}
+	_, err = it.Next()
+	assert.Equal(t, io.EOF, err)
+
+	// records appended after reaching the end
+	appendRecords(7, 9)
+	rec, err := it.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), rec.Offset)
+	assert.Equal(t, uint64(8), it.Offset())
+
+	it.Seek(2)
+	rec, err = it.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), rec.Offset)
+
+	// the segments holding the position were removed, the iterator carries on from the oldest record left
+	assert.NoError(t, log.Truncate(5))
+	rec, err = it.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), rec.Offset)
+}
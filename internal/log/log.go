@@ -2,7 +2,10 @@ package log
 
 import (
 	"EchoLog/api/v1"
+	"context"
 	"fmt"
+	"github.com/golang/protobuf/proto"
+	"go.uber.org/zap"
 	"io"
 	"io/ioutil"
 	"os"
@@ -11,14 +14,37 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Log struct {
-	mux           sync.Mutex
+	// readers share the lock, appends and everything reshaping the segments take it exclusively
+	mux sync.RWMutex
+	// serializes the compactions, which only take the lock to swap the segments they rewrote
+	compactMux    sync.Mutex
 	dir           string
 	config        Config
 	segments      []*fileSegment
 	activeSegment *fileSegment
+	recovered     []SegmentRecovery
+	logger        *zap.Logger
+
+	// sequence of the last appended record, used to track what was synced
+	appended uint64
+	// segments holding records which weren't synced yet
+	unsynced  []*fileSegment
+	syncer    *groupSyncer
+	done      chan struct{}
+	closeOnce sync.Once
+	// closed and replaced every time records are appended
+	appendedCh chan struct{}
+
+	removed RetentionStats
+
+	producers *producerTable
+
+	// set by Close and Remove, the segments are gone and every entry point fails with api.ErrLogClosed
+	closed bool
 }
 
 func NewLog(dir string, config Config) (*Log, error) {
@@ -39,26 +65,118 @@ func NewLog(dir string, config Config) (*Log, error) {
 		config.MaxStoreBytes = 1024
 	}
 
+	if config.SyncEveryRecords == 0 {
+		config.SyncEveryRecords = 1
+	}
+
+	if config.SyncInterval == 0 {
+		config.SyncInterval = 100 * time.Millisecond
+	}
+
+	if config.TimeIndexIntervalBytes == 0 {
+		config.TimeIndexIntervalBytes = defaultTimeIndexIntervalBytes
+	}
+
+	if config.Retention.CheckInterval == 0 {
+		config.Retention.CheckInterval = time.Minute
+	}
+
+	if config.Compaction.CheckInterval == 0 {
+		config.Compaction.CheckInterval = time.Minute
+	}
+
+	if config.ProducerExpiration == 0 {
+		config.ProducerExpiration = 7 * 24 * time.Hour
+	}
+
 	log := &Log{
 		dir:      dir,
 		config:   config,
 		segments: []*fileSegment{},
+		logger:   zap.L().Named("log"),
+		done:     make(chan struct{}),
+
+		appendedCh: make(chan struct{}),
 	}
+	log.syncer = newGroupSyncer(log.sync)
 
-	if err := log.setup(); err != nil {
+	var err error
+	if err = log.setup(); err != nil {
+		return nil, err
+	}
+
+	if err = log.openProducers(0); err != nil {
+		_ = log.closeSegments(false)
 		return nil, err
 	}
 
+	if config.Durability == DurabilityInterval {
+		go log.syncPeriodically()
+	}
+
+	if config.Retention.MaxAge > 0 || config.Retention.MaxBytes > 0 {
+		go log.enforceRetentionPeriodically()
+	}
+
+	if config.Compaction.Enabled {
+		go log.compactPeriodically()
+	}
+
 	return log, nil
 }
 
 func (log *Log) setup() error {
 
-	files, err := ioutil.ReadDir(log.dir)
+	baseOffsets, err := segmentBaseOffsets(log.dir)
 	if err != nil {
 		return err
 	}
 
+	for _, offset := range baseOffsets {
+		if err = log.addSegmentForOffset(offset); err != nil {
+			return err
+		}
+	}
+
+	if len(log.segments) == 0 {
+		if err = log.addSegmentForOffset(log.config.InitialOffset); err != nil {
+			return err
+		}
+	}
+
+	// a repaired segment may be left without room for new records
+	if log.activeSegment.IsFull() {
+		if err = log.addSegmentForOffset(log.activeSegment.nextOffset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Files returns the names of the files making up the log stored in dir, its segments and its producers, in
+// alphabetical order; the other files of the directory aren't listed
+func Files(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if f.Mode().IsRegular() && (snapshotFileName.MatchString(f.Name()) || f.Name() == producersFileName) {
+			names = append(names, f.Name())
+		}
+	}
+	return names, nil
+}
+
+// segmentBaseOffsets lists the base offsets of the segments found in dir, in ascending order
+func segmentBaseOffsets(dir string) ([]uint64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	processedNames := make(map[string]interface{})
 	dummy := struct {
 	}{}
@@ -82,86 +200,494 @@ func (log *Log) setup() error {
 		return baseOffsets[i] < baseOffsets[j]
 	})
 
-	for _, offset := range baseOffsets {
-		if err = log.addSegmentForOffset(offset); err != nil {
-			return err
-		}
-	}
-
-	if len(log.segments) == 0 {
-		if err = log.addSegmentForOffset(log.config.InitialOffset); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return baseOffsets, nil
 }
 
 func (log *Log) addSegmentForOffset(offset uint64) error {
 	if len(log.segments) > 0 {
-		if offset != log.segments[len(log.segments)-1].nextOffset {
+		last := log.segments[len(log.segments)-1]
+		if offset < last.nextOffset {
 			return fmt.Errorf("invalid offset")
 		}
+		// compaction removed the records at the end of the previous segment
+		last.nextOffset = offset
 	}
 	segment, err := newFileSegment(log.dir, offset, log.config)
 	if err != nil {
 		return err
 	}
 
+	if r := segment.recovery; r != nil {
+		log.recovered = append(log.recovered, *r)
+		log.logger.Warn(
+			"recovered segment after unclean shutdown",
+			zap.String("store", r.StoreFile),
+			zap.Uint64("truncated_store_bytes", r.TruncatedStoreBytes),
+			zap.Uint64("dropped_index_entries", r.DroppedIndexEntries),
+			zap.Uint64("rebuilt_index_entries", r.RebuiltIndexEntries),
+		)
+	}
+
 	log.segments = append(log.segments, segment)
 	log.activeSegment = segment
 	return nil
 }
 
 // implements server.CommitLog.Append
+// returns once the record reached the durability level set in the config
 func (log *Log) Append(rec *api.LogRecord) (appendIndex uint64, err error) {
+	appendIndex, _, err = log.AppendBatch([]*api.LogRecord{rec})
+	return
+}
+
+// implements server.CommitLog.AppendBatch
+// the records are written as a single entry of the store, in a single segment, and become visible all together: a
+// crash while they are written loses all of them, never some of them
+// returns the offsets of the first and the last record once they reached the durability level set in the config
+func (log *Log) AppendBatch(recs []*api.LogRecord) (first uint64, last uint64, err error) {
+	return log.AppendCompressed(recs, api.Compression_DEFAULT_COMPRESSION)
+}
+
+// implements server.CommitLog.AppendCompressed
+// AppendCompressed appends the records like AppendBatch, compressed together unless compression, or Config.Compression
+// when compression is DEFAULT_COMPRESSION, is NO_COMPRESSION
+func (log *Log) AppendCompressed(recs []*api.LogRecord, compression api.Compression) (first uint64, last uint64, err error) {
+	return log.appendIf(recs, compression, nil, nil)
+}
+
+// implements server.CommitLog.AppendExpected
+// AppendExpected appends the records like AppendCompressed only if the first of them gets the expected offset,
+// the next offset of the log is checked under the lock taken for the append; fails with api.ErrUnexpectedOffset
+// otherwise
+func (log *Log) AppendExpected(recs []*api.LogRecord, compression api.Compression, expected uint64) (first uint64, last uint64, err error) {
+	return log.appendIf(recs, compression, &expected, nil)
+}
+
+// appendIf appends the records when expected is nil or the next offset of the log, on behalf of producer unless it is
+// nil
+func (log *Log) appendIf(recs []*api.LogRecord, compression api.Compression, expected *uint64,
+	producer *idempotentAppend) (first uint64, last uint64, err error) {
+	if len(recs) == 0 {
+		return 0, 0, fmt.Errorf("empty batch")
+	}
+	if compression == api.Compression_DEFAULT_COMPRESSION {
+		compression = log.config.Compression
+	}
+	if err = checkCompression(compression); err != nil {
+		return 0, 0, err
+	}
+	if compression == api.Compression_DEFAULT_COMPRESSION {
+		compression = api.Compression_NO_COMPRESSION
+	}
+
+	now := time.Now().UnixNano()
+	for _, rec := range recs {
+		if rec.Timestamp == 0 {
+			rec.Timestamp = now
+		}
+		rec.ProducerId, rec.ProducerSequence = 0, 0
+		if producer != nil {
+			rec.ProducerId, rec.ProducerSequence = producer.id, producer.sequence
+		}
+	}
+
 	log.mux.Lock()
-	defer log.mux.Unlock()
-	appendIndex, err = log.activeSegment.Append(rec)
+	if err = log.checkOpen(); err != nil {
+		log.mux.Unlock()
+		return 0, 0, err
+	}
+	if next := log.activeSegment.nextOffset; expected != nil && *expected != next {
+		log.mux.Unlock()
+		return 0, 0, api.ErrUnexpectedOffset{Expected: *expected, NextOffset: next}
+	}
+	firstSeq := log.appended + 1
+	mark := log.markBatch()
+	if len(recs) == 1 && compression == api.Compression_NO_COMPRESSION {
+		first, last, err = log.appendRecord(recs[0])
+	} else {
+		first, last, err = log.appendBatch(recs, compression)
+	}
 	if err != nil {
-		return 0, err
+		if rollbackErr := log.rollbackBatch(mark); rollbackErr != nil {
+			log.logger.Error("failed to roll back batch", zap.Error(rollbackErr))
+		}
+		log.mux.Unlock()
+		return 0, 0, err
+	}
+	if producer != nil {
+		log.producers.appended(producer.state, producerAppend{Sequence: producer.sequence, FirstOffset: first, LastOffset: last})
+	}
+	// a checkpoint of the producers per segment bounds the records replayed when the log is opened
+	if len(log.segments) > mark.segments {
+		if checkpointErr := log.producers.checkpoint(log.activeSegment.nextOffset); checkpointErr != nil {
+			log.logger.Error("failed to checkpoint producers", zap.Error(checkpointErr))
+		}
+	}
+	lastSeq := log.appended
+	close(log.appendedCh)
+	log.appendedCh = make(chan struct{})
+	log.mux.Unlock()
+
+	switch log.config.Durability {
+	case DurabilityAlways:
+		err = log.syncer.waitFor(lastSeq)
+	case DurabilityEveryN:
+		// the batch crossed a multiple of SyncEveryRecords
+		n := log.config.SyncEveryRecords
+		if lastSeq/n > (firstSeq-1)/n {
+			err = log.syncer.waitFor(lastSeq)
+		}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return first, last, nil
+}
+
+// appendRecord writes a single uncompressed record to the active segment
+func (log *Log) appendRecord(rec *api.LogRecord) (first uint64, last uint64, err error) {
+	offset, err := log.activeSegment.Append(rec)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err = log.appendedToActive(1, offset); err != nil {
+		return 0, 0, err
+	}
+	return offset, offset, nil
+}
+
+// appendBatch writes the records as a single entry to the active segment, compressed unless compression is
+// NO_COMPRESSION
+func (log *Log) appendBatch(recs []*api.LogRecord, compression api.Compression) (first uint64, last uint64, err error) {
+	first = log.activeSegment.nextOffset
+	for i, rec := range recs {
+		rec.Offset = first + uint64(i)
+	}
+	last = recs[len(recs)-1].Offset
+
+	if err = log.activeSegment.appendBatch(recs, compression); err != nil {
+		return 0, 0, err
+	}
+	if err = log.appendedToActive(uint64(len(recs)), last); err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+// appendedToActive accounts for records appended to the active segment up to the given offset and rolls the segment
+// when it's full
+func (log *Log) appendedToActive(records uint64, last uint64) error {
+	log.appended += records
+	if n := len(log.unsynced); n == 0 || log.unsynced[n-1] != log.activeSegment {
+		log.unsynced = append(log.unsynced, log.activeSegment)
 	}
 
 	if log.activeSegment.IsFull() {
-		err = log.addSegmentForOffset(appendIndex + 1)
-		if err != nil {
+		return log.addSegmentForOffset(last + 1)
+	}
+	return nil
+}
+
+// batchMark is the state of the log before a batch, a failed batch rolls the log back to it
+type batchMark struct {
+	segments int
+	active   *fileSegment
+	segment  segmentMark
+	appended uint64
+	unsynced int
+}
+
+func (log *Log) markBatch() batchMark {
+	return batchMark{
+		segments: len(log.segments),
+		active:   log.activeSegment,
+		segment:  log.activeSegment.mark(),
+		appended: log.appended,
+		unsynced: len(log.unsynced),
+	}
+}
+
+func (log *Log) rollbackBatch(mark batchMark) error {
+	for _, segment := range log.segments[mark.segments:] {
+		if err := segment.Remove(); err != nil {
+			return err
+		}
+	}
+	log.segments = log.segments[:mark.segments]
+	log.activeSegment = mark.active
+	log.appended = mark.appended
+	log.unsynced = log.unsynced[:mark.unsynced]
+
+	return mark.active.rollback(mark.segment)
+}
+
+// sync forces every record appended so far to stable storage and returns the sequence of the last one
+// only the buffers are flushed under the lock, appends carry on while the disk catches up
+// the segments a failed sync didn't cover stay unsynced so the next sync tries them again
+func (log *Log) sync() (uint64, error) {
+	log.mux.Lock()
+	seq := log.appended
+	segments := log.unsynced
+	log.unsynced = nil
+	for i, segment := range segments {
+		if err := segment.store.flush(); err != nil {
+			log.requeueUnsynced(segments[i:])
+			log.mux.Unlock()
 			return 0, err
 		}
 	}
+	log.mux.Unlock()
+
+	for i, segment := range segments {
+		if err := segment.sync(); err != nil {
+			log.mux.Lock()
+			log.requeueUnsynced(segments[i:])
+			log.mux.Unlock()
+			return 0, err
+		}
+	}
+
+	return seq, nil
+}
+
+// requeueUnsynced puts back the segments a failed sync didn't cover ahead of the ones appended to since, must be
+// called with the lock held
+func (log *Log) requeueUnsynced(segments []*fileSegment) {
+	if log.closed {
+		return
+	}
+	unsynced := append([]*fileSegment(nil), segments...)
+	for _, segment := range log.unsynced {
+		if segment != unsynced[len(unsynced)-1] {
+			unsynced = append(unsynced, segment)
+		}
+	}
+	log.unsynced = unsynced
+}
 
-	return appendIndex, nil
+func (log *Log) syncPeriodically() {
+	ticker := time.NewTicker(log.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-log.done:
+			return
+		case <-ticker.C:
+			log.mux.RLock()
+			seq := log.appended
+			log.mux.RUnlock()
+
+			if err := log.syncer.waitFor(seq); err != nil {
+				log.logger.Error("failed to sync log", zap.Error(err))
+			}
+		}
+	}
 }
 
 // implements server.CommitLog.Read
+// the returned record holds a later offset than the requested one when compaction removed the requested record
 func (log *Log) Read(offset uint64) (*api.LogRecord, error) {
-	log.mux.Lock()
-	defer log.mux.Unlock()
+	log.mux.RLock()
+	defer log.mux.RUnlock()
 
-	for _, segment := range log.segments {
-		if segment.startOffset <= offset && segment.nextOffset > offset {
-			return segment.Read(offset)
+	return log.read(offset)
+}
+
+// implements server.CommitLog.ReadBatch
+// ReadBatch returns the records from the given offset on, as many as fit in maxBytes of encoded records; the first
+// record is returned even when it is larger so the reader always makes progress
+// returns no records when the offset is past the end of the log and ErrOffsetOutOfRange when it was removed from it
+func (log *Log) ReadBatch(offset uint64, maxBytes uint64) ([]*api.LogRecord, error) {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	if err := log.checkOpen(); err != nil {
+		return nil, err
+	}
+	if len(log.segments) == 0 || offset < log.segments[0].startOffset {
+		return nil, api.ErrOffsetOutOfRange{Offset: offset}
+	}
+
+	var recs []*api.LogRecord
+	var size uint64
+	for {
+		rec, err := log.read(offset)
+		if _, ok := err.(api.ErrOffsetOutOfRange); ok {
+			return recs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		size += uint64(proto.Size(rec))
+		if len(recs) > 0 && size > maxBytes {
+			return recs, nil
+		}
+		recs = append(recs, rec)
+		offset = rec.Offset + 1
+	}
+}
+
+func (log *Log) read(offset uint64) (*api.LogRecord, error) {
+	if err := log.checkOpen(); err != nil {
+		return nil, err
+	}
+	if len(log.segments) == 0 || offset < log.segments[0].startOffset {
+		return nil, api.ErrOffsetOutOfRange{Offset: offset}
+	}
+
+	// when compaction removed the record, the first one after it is returned
+	for _, segment := range log.segments[log.segmentIndex(offset):] {
+		rec, err := segment.Read(offset)
+		if err == io.EOF {
+			continue
 		}
+		return rec, err
 	}
 
 	return nil, api.ErrOffsetOutOfRange{Offset: offset}
 }
 
-func (log *Log) Close() error {
-	return log.closeSegments(false)
+// segmentIndex returns the index of the first segment which may hold the given offset or a later one
+func (log *Log) segmentIndex(offset uint64) int {
+	return sort.Search(len(log.segments), func(i int) bool {
+		return log.segments[i].nextOffset > offset
+	})
 }
 
-func (log *Log) Remove() error {
-	return log.closeSegments(true)
+// implements server.CommitLog.WaitFor
+// WaitFor blocks until a record at or after the given offset can be read, ctx is done or the log is closed
+// returns ErrOffsetOutOfRange when the offset was already removed from the log
+func (log *Log) WaitFor(ctx context.Context, offset uint64) error {
+	for {
+		log.mux.RLock()
+		ready, err := log.readable(offset)
+		appended := log.appendedCh
+		log.mux.RUnlock()
+
+		if err != nil || ready {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-log.done:
+			return api.ErrLogClosed{Dir: log.dir}
+		case <-appended:
+		}
+	}
 }
 
-func (log *Log) closeSegments(removeSegments bool) error {
+// readable tells whether a record at or after the given offset is in the log
+func (log *Log) readable(offset uint64) (bool, error) {
+	if err := log.checkOpen(); err != nil {
+		return false, err
+	}
+	if len(log.segments) == 0 || offset < log.segments[0].startOffset {
+		return false, api.ErrOffsetOutOfRange{Offset: offset}
+	}
+
+	active := log.activeSegment
+	if offset >= active.nextOffset {
+		return false, nil
+	}
+
+	// the active segment is never compacted, any record it holds is at or after the offset
+	if active.nextOffset > active.startOffset {
+		return true, nil
+	}
+
+	// compaction may have removed every record after the offset
+	_, err := log.read(offset)
+	if _, ok := err.(api.ErrOffsetOutOfRange); ok {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// OffsetForTime returns the offset of the first record with a timestamp at or after t
+// when all the records are older it returns the offset the next appended record will get
+func (log *Log) OffsetForTime(t time.Time) (uint64, error) {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	if err := log.checkOpen(); err != nil {
+		return 0, err
+	}
+	timestamp := t.UnixNano()
+	for _, segment := range log.segments {
+		offset, ok, err := segment.offsetForTime(timestamp)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return offset, nil
+		}
+	}
+
+	return log.activeSegment.nextOffset, nil
+}
+
+// Recovered returns the segments which had to be repaired when the log was opened
+func (log *Log) Recovered() []SegmentRecovery {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	return log.recovered
+}
+
+func (log *Log) Close() error {
+	log.stop()
+
 	log.mux.Lock()
 	defer log.mux.Unlock()
 
-	if len(log.segments) == 0 {
+	if log.closed {
 		return nil
 	}
-	truncateAt := -1
+	log.closed = true
+	err := log.producers.checkpoint(log.activeSegment.nextOffset)
+	if closeErr := log.closeSegments(false); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Remove closes the log and removes its files, requests still holding the log fail with api.ErrLogClosed
+func (log *Log) Remove() error {
+	log.stop()
+
+	log.mux.Lock()
+	defer log.mux.Unlock()
+
+	log.closed = true
+	if err := log.closeSegments(true); err != nil {
+		return err
+	}
+	if err := os.Remove(log.producers.file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// checkOpen must be called with the lock held
+func (log *Log) checkOpen() error {
+	if log.closed {
+		return api.ErrLogClosed{Dir: log.dir}
+	}
+	return nil
+}
+
+// stop terminates the background work owned by the log
+func (log *Log) stop() {
+	log.closeOnce.Do(func() {
+		close(log.done)
+	})
+}
+
+// closeSegments must be called with the lock held
+func (log *Log) closeSegments(removeSegments bool) error {
 	for i, segment := range log.segments {
 		var err error
 
@@ -172,31 +698,36 @@ func (log *Log) closeSegments(removeSegments bool) error {
 		}
 
 		if err != nil {
+			log.segments = log.segments[i:]
 			return err
 		}
-		truncateAt = i
-		if log.activeSegment == segment {
-			log.activeSegment = nil
-		}
-	}
-
-	if truncateAt >= 0 {
-		log.segments = log.segments[truncateAt:]
 	}
 
+	log.segments = nil
+	log.activeSegment = nil
+	log.unsynced = nil
 	return nil
 }
 
 func (log *Log) Reset() error {
-	if err := log.Remove(); err != nil {
+	log.mux.Lock()
+	defer log.mux.Unlock()
+
+	if err := log.checkOpen(); err != nil {
+		return err
+	}
+	if err := log.closeSegments(true); err != nil {
 		return err
 	}
-	return log.setup()
+	if err := log.setup(); err != nil {
+		return err
+	}
+	return log.producers.reset(log.activeSegment.nextOffset)
 }
 
 func (log *Log) Offsets() (low uint64, high uint64) {
-	log.mux.Lock()
-	defer log.mux.Unlock()
+	log.mux.RLock()
+	defer log.mux.RUnlock()
 
 	if len(log.segments) > 0 {
 		low = log.segments[0].startOffset
@@ -208,10 +739,29 @@ func (log *Log) Offsets() (low uint64, high uint64) {
 	return
 }
 
+// implements server.CommitLog.Watermarks
+// Watermarks returns the offset of the first record of the log and the one the next appended record gets, they are
+// equal when the log holds no records; unlike Offsets it tells an empty log from one holding a single record
+func (log *Log) Watermarks() (low uint64, high uint64) {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	if len(log.segments) > 0 {
+		low = log.segments[0].startOffset
+		high = log.activeSegment.nextOffset
+	}
+	return
+}
+
+// Truncate removes the segments holding only records up to lowest
+// the offsets of the removed records aren't handed out again, so idempotent producers keep being answered with them
 func (log *Log) Truncate(lowest uint64) error {
 	log.mux.Lock()
 	defer log.mux.Unlock()
 
+	if err := log.checkOpen(); err != nil {
+		return err
+	}
 	for idx, segm := range log.segments {
 		if segm.nextOffset <= lowest+1 {
 			if segm == log.activeSegment {
@@ -230,8 +780,8 @@ func (log *Log) Truncate(lowest uint64) error {
 }
 
 func (log *Log) Reader() io.Reader {
-	log.mux.Lock()
-	defer log.mux.Unlock()
+	log.mux.RLock()
+	defer log.mux.RUnlock()
 
 	readers := make([]io.Reader, len(log.segments))
 	for idx, segment := range log.segments {
@@ -2,10 +2,14 @@ package log
 
 import (
 	"EchoLog/api/v1"
+	"context"
 	"fmt"
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 	"testing"
 	"time"
 )
@@ -45,3 +49,390 @@ func testAppendRead(t *testing.T, log *Log) {
 	assert.Equal(t, high, uint64(4))
 
 }
+
+func TestLog_Watermarks(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-watermarks")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{InitialOffset: 10})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	low, high := log.Watermarks()
+	assert.Equal(t, uint64(10), low)
+	assert.Equal(t, uint64(10), high)
+
+	_, err = log.Append(&api.LogRecord{Value: []byte("first")})
+	assert.NoError(t, err)
+	low, high = log.Watermarks()
+	assert.Equal(t, uint64(10), low)
+	assert.Equal(t, uint64(11), high)
+}
+
+func TestLog_Durability(t *testing.T) {
+	// size of the store file as seen by the OS past its header, buffered records are not part of it
+	onDisk := func(log *Log) uint64 {
+		stat, err := os.Stat(log.activeSegment.store.Name())
+		assert.NoError(t, err)
+		return uint64(stat.Size()) - fileHeaderSize
+	}
+
+	t.Run("always", func(t *testing.T) {
+		dir, _ := ioutil.TempDir(os.TempDir(), "log-durability")
+		defer os.RemoveAll(dir)
+		log, err := NewLog(dir, Config{MaxStoreBytes: 1 << 20, MaxIndexBytes: 1 << 20, Durability: DurabilityAlways})
+		assert.NoError(t, err)
+		defer log.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := log.Append(&api.LogRecord{Value: []byte("durable")})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, log.activeSegment.store.size, onDisk(log))
+		assert.Equal(t, uint64(20), log.syncer.synced)
+	})
+
+	t.Run("every n", func(t *testing.T) {
+		dir, _ := ioutil.TempDir(os.TempDir(), "log-durability")
+		defer os.RemoveAll(dir)
+		log, err := NewLog(dir, Config{Durability: DurabilityEveryN, SyncEveryRecords: 3})
+		assert.NoError(t, err)
+		defer log.Close()
+
+		for i := 0; i < 2; i++ {
+			_, err = log.Append(&api.LogRecord{Value: []byte("durable")})
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, uint64(0), onDisk(log))
+
+		_, err = log.Append(&api.LogRecord{Value: []byte("durable")})
+		assert.NoError(t, err)
+		assert.Equal(t, log.activeSegment.store.size, onDisk(log))
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		dir, _ := ioutil.TempDir(os.TempDir(), "log-durability")
+		defer os.RemoveAll(dir)
+		log, err := NewLog(dir, Config{Durability: DurabilityInterval, SyncInterval: 10 * time.Millisecond})
+		assert.NoError(t, err)
+		defer log.Close()
+
+		_, err = log.Append(&api.LogRecord{Value: []byte("durable")})
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			return onDisk(log) == log.activeSegment.store.size
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("failed sync", func(t *testing.T) {
+		dir, _ := ioutil.TempDir(os.TempDir(), "log-durability")
+		defer os.RemoveAll(dir)
+		log, err := NewLog(dir, Config{Durability: DurabilityAlways})
+		assert.NoError(t, err)
+		defer log.Close()
+
+		// fsync fails on a pipe
+		file := log.activeSegment.store.File
+		r, w, err := os.Pipe()
+		assert.NoError(t, err)
+		defer r.Close()
+		defer w.Close()
+		log.activeSegment.store.File = w
+
+		_, err = log.Append(&api.LogRecord{Value: []byte("durable")})
+		assert.Error(t, err)
+		assert.Equal(t, []*fileSegment{log.activeSegment}, log.unsynced)
+
+		// the segment is synced again along with the next append
+		log.activeSegment.store.File = file
+		offset, err := log.Append(&api.LogRecord{Value: []byte("durable")})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), offset)
+		assert.Empty(t, log.unsynced)
+		assert.Equal(t, uint64(2), log.syncer.synced)
+	})
+}
+
+func TestLog_Headers(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-headers")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+
+	headers := []*api.Header{
+		{Key: "content-type", Value: []byte("application/json")},
+		{Key: "schema-version", Value: []byte("2")},
+	}
+	off, err := log.Append(&api.LogRecord{Value: []byte(`{}`), Headers: headers})
+	assert.NoError(t, err)
+	assert.NoError(t, log.Close())
+
+	log, err = NewLog(dir, Config{})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	rec, err := log.Read(off)
+	assert.NoError(t, err)
+	assert.Len(t, rec.Headers, 2)
+
+	value, ok := rec.Header("schema-version")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), value)
+
+	_, ok = rec.Header("trace-id")
+	assert.False(t, ok)
+}
+
+func TestLog_AppendBatch(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-batch")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxIndexBytes: entryWidth * 3})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	batch := func(values ...string) []*api.LogRecord {
+		recs := make([]*api.LogRecord, 0, len(values))
+		for _, v := range values {
+			recs = append(recs, &api.LogRecord{Value: []byte(v)})
+		}
+		return recs
+	}
+
+	first, last, err := log.AppendBatch(batch("a", "b", "c", "d", "e"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), first)
+	assert.Equal(t, uint64(4), last)
+	// a batch is a single entry of the store, it isn't split across segments
+	assert.Len(t, log.segments, 1)
+
+	// the invalid header key can't be marshalled, the whole batch is rolled back
+	recs := batch("f", "g", "h")
+	recs[2].Headers = []*api.Header{{Key: "\xff"}}
+	_, _, err = log.AppendBatch(recs)
+	assert.Error(t, err)
+	assert.Len(t, log.segments, 1)
+
+	_, high := log.Offsets()
+	assert.Equal(t, uint64(4), high)
+	_, err = log.Read(5)
+	assert.Error(t, err)
+
+	first, last, err = log.AppendBatch(batch("f", "g"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), first)
+	assert.Equal(t, uint64(6), last)
+
+	for i, v := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		rec, err := log.Read(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(v), rec.Value)
+	}
+
+	_, _, err = log.AppendBatch(nil)
+	assert.Error(t, err)
+
+	// a batch torn by a crash is lost as a whole
+	assert.NoError(t, log.Close())
+	log, err = NewLog(dir, Config{MaxIndexBytes: entryWidth * 4})
+	assert.NoError(t, err)
+	_, _, err = log.AppendBatch(batch("h", "i", "j"))
+	assert.NoError(t, err)
+	assert.NoError(t, log.Close())
+	stat, _ := os.Stat(path.Join(dir, "0.store"))
+	assert.NoError(t, os.Truncate(path.Join(dir, "0.store"), stat.Size()-2))
+	log, err = NewLog(dir, Config{MaxIndexBytes: entryWidth * 4})
+	assert.NoError(t, err)
+	defer log.Close()
+	_, high = log.Watermarks()
+	assert.Equal(t, uint64(7), high)
+}
+
+func TestLog_AppendExpected(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-expected")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	first, last, err := log.AppendExpected([]*api.LogRecord{{Value: []byte("a")}, {Value: []byte("b")}},
+		api.Compression_DEFAULT_COMPRESSION, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), first)
+	assert.Equal(t, uint64(1), last)
+
+	// another writer appended since offset 0 was read
+	_, _, err = log.AppendExpected([]*api.LogRecord{{Value: []byte("c")}}, api.Compression_DEFAULT_COMPRESSION, 0)
+	assert.Equal(t, api.ErrUnexpectedOffset{Expected: 0, NextOffset: 2}, err)
+	_, high := log.Offsets()
+	assert.Equal(t, uint64(1), high)
+
+	first, _, err = log.AppendExpected([]*api.LogRecord{{Value: []byte("c")}}, api.Compression_GZIP, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), first)
+}
+
+func TestLog_WaitFor(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-wait")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+
+	_, err = log.Append(&api.LogRecord{Value: []byte("first")})
+	assert.NoError(t, err)
+
+	// already written
+	assert.NoError(t, log.WaitFor(context.Background(), 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, log.WaitFor(ctx, 1))
+
+	waited := make(chan error)
+	go func() {
+		waited <- log.WaitFor(context.Background(), 1)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_, err = log.Append(&api.LogRecord{Value: []byte("second")})
+	assert.NoError(t, err)
+	assert.NoError(t, <-waited)
+
+	go func() {
+		waited <- log.WaitFor(context.Background(), 5)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, log.Remove())
+	assert.Equal(t, api.ErrLogClosed{Dir: dir}, <-waited)
+}
+
+func TestLog_Closed(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-closed")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+	_, err = log.Append(&api.LogRecord{Value: []byte("first")})
+	assert.NoError(t, err)
+	assert.NoError(t, log.Remove())
+	assert.NoError(t, log.Close())
+
+	closed := api.ErrLogClosed{Dir: dir}
+	_, err = log.Append(&api.LogRecord{Value: []byte("second")})
+	assert.Equal(t, closed, err)
+	_, err = log.Read(0)
+	assert.Equal(t, closed, err)
+	_, err = log.ReadBatch(0, 1024)
+	assert.Equal(t, closed, err)
+	_, err = log.OffsetForTime(time.Now())
+	assert.Equal(t, closed, err)
+	_, err = log.RegisterProducer()
+	assert.Equal(t, closed, err)
+	_, err = log.Compact()
+	assert.Equal(t, closed, err)
+	_, err = log.EnforceRetention()
+	assert.Equal(t, closed, err)
+	_, err = log.SegmentStats()
+	assert.Equal(t, closed, err)
+	assert.Equal(t, closed, log.Truncate(0))
+	assert.Equal(t, closed, log.Reset())
+	assert.Equal(t, closed, log.WaitFor(context.Background(), 1))
+
+	low, high := log.Watermarks()
+	assert.Zero(t, low)
+	assert.Zero(t, high)
+}
+
+func TestLog_ReadBatch(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-read-batch")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxStoreBytes: 256})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record-%d", i))})
+		assert.NoError(t, err)
+	}
+	rec, err := log.Read(2)
+	assert.NoError(t, err)
+	size := uint64(proto.Size(rec))
+
+	// across segment boundaries, up to the budget
+	recs, err := log.ReadBatch(2, 4*size)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(recs))
+	for i, rec := range recs {
+		assert.Equal(t, uint64(2+i), rec.Offset)
+	}
+
+	// the first record is returned even if it doesn't fit
+	recs, err = log.ReadBatch(0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(recs))
+
+	recs, err = log.ReadBatch(8, 100*size)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(recs))
+
+	recs, err = log.ReadBatch(10, 100*size)
+	assert.NoError(t, err)
+	assert.Empty(t, recs)
+}
+
+func TestLog_ConcurrentReads(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-concurrent")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxStoreBytes: 256, MaxIndexBytes: entryWidth * 4})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	const records = 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < records; i++ {
+			_, err := log.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record %d", i))})
+			assert.NoError(t, err)
+		}
+	}()
+
+	// every reader follows the producer across segments and sees every record in order
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for offset := uint64(0); offset < records; offset++ {
+				assert.NoError(t, log.WaitFor(context.Background(), offset))
+				rec, err := log.Read(offset)
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, offset, rec.Offset)
+				assert.Equal(t, []byte(fmt.Sprintf("record %d", offset)), rec.Value)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the segment holding an offset is found in the middle of the log as well as at its ends
+	assert.Greater(t, len(log.segments), 10)
+	for _, offset := range []uint64{0, records / 2, records - 1} {
+		rec, err := log.Read(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, offset, rec.Offset)
+	}
+}
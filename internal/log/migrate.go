@@ -0,0 +1,264 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// number of records of a store checked against the framing of the older version before it gets a header
+const legacyCheckedRecords = 16
+
+// Migrate upgrades in place the segment files of the log stored in dir which were written before the files had a
+// header, files which already have one are left untouched
+// every file is rewritten next to the original and renamed over it once synced, so an interrupted migration can be
+// run again; returns the names of the upgraded files
+// stores of the first version, whose records were only framed with their length, are rewritten with a checksum for
+// every record and their indexes are rebuilt for the new positions
+// a store framed neither way fails the migration, the files of its segment are left as they are
+// the log must not be open while it is migrated
+func Migrate(dir string) ([]string, error) {
+	baseOffsets, err := segmentBaseOffsets(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := make([]string, 0)
+	for _, baseOffset := range baseOffsets {
+		// the records of a segment with a key file were sealed with its data key
+		var storeFlags uint8
+		if _, err := os.Stat(keyFileName(dir, baseOffset)); err == nil {
+			storeFlags |= fileFlagEncrypted
+		} else if !os.IsNotExist(err) {
+			return migrated, err
+		}
+
+		encrypted := storeFlags&fileFlagEncrypted != 0
+		if err = checkLegacyStore(dir, baseOffset, encrypted); errors.Is(err, ErrUnsupportedFormat) && !encrypted {
+			names, baselineErr := migrateBaselineSegment(dir, baseOffset)
+			if errors.Is(baselineErr, ErrUnsupportedFormat) {
+				return migrated, err
+			} else if baselineErr != nil {
+				return migrated, baselineErr
+			}
+			migrated = append(migrated, names...)
+			continue
+		} else if err != nil {
+			return migrated, err
+		}
+
+		files := []struct {
+			ext   string
+			kind  fileKind
+			flags uint8
+		}{
+			{ext: "store", kind: storeFileKind, flags: storeFlags},
+			{ext: "index", kind: indexFileKind},
+			{ext: "timeindex", kind: timeIndexFileKind},
+		}
+		for _, f := range files {
+			name := path.Join(dir, fmt.Sprintf("%d.%s", baseOffset, f.ext))
+			ok, err := migrateFile(name, fileHeader{
+				Version:    formatVersion,
+				Kind:       f.kind,
+				Flags:      f.flags,
+				BaseOffset: baseOffset,
+			})
+			if err != nil {
+				return migrated, err
+			}
+			if ok {
+				migrated = append(migrated, name)
+			}
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrateFile prepends the header to the named file unless it already has one
+// the file keeps its modification time as creation time, returns false when there was nothing to do
+func migrateFile(name string, header fileHeader) (bool, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	if _, err = readFileHeader(f, header.Kind, header.BaseOffset); err == nil {
+		return false, nil
+	} else if !errors.Is(err, ErrLegacyFormat) {
+		return false, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	header.CreatedAt = stat.ModTime()
+
+	tmp := name + ".migrate"
+	out, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, stat.Mode().Perm())
+	if err != nil {
+		return false, err
+	}
+	if _, err = out.Write(header.encode()); err != nil {
+		_ = out.Close()
+		return false, err
+	}
+	if _, err = io.Copy(out, f); err != nil {
+		_ = out.Close()
+		return false, err
+	}
+	if err = out.Sync(); err != nil {
+		_ = out.Close()
+		return false, err
+	}
+	if err = out.Close(); err != nil {
+		return false, err
+	}
+
+	return true, os.Rename(tmp, name)
+}
+
+// checkLegacyStore checks the first records of the store of the segment, when it has no header yet, are framed the
+// way the older version wrote them: a length, a checksum of the data and, unless the segment is encrypted, an entry
+// holding offsets from the base offset on; a torn record is only accepted after an intact one
+func checkLegacyStore(dir string, baseOffset uint64, encrypted bool) error {
+	name := path.Join(dir, fmt.Sprintf("%d.store", baseOffset))
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = readFileHeader(f, storeFileKind, baseOffset); !errors.Is(err, ErrLegacyFormat) {
+		return nil
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := uint64(stat.Size())
+	next := baseOffset
+	for pos, i := uint64(0), 0; pos < size && i < legacyCheckedRecords; i++ {
+		if size-pos < recordHeaderByteSize {
+			if i == 0 {
+				return fmt.Errorf("%s: %w: %d bytes can't hold a record", name, ErrUnsupportedFormat, size)
+			}
+			break
+		}
+		header := make([]byte, recordHeaderByteSize)
+		if _, err = f.ReadAt(header, int64(pos)); err != nil {
+			return err
+		}
+		dataSize := binary.BigEndian.Uint64(header[:recordLengthByteSize])
+		if dataSize > size-pos-recordHeaderByteSize {
+			if i == 0 {
+				return fmt.Errorf("%s: %w: first record is %d bytes long, the file holds %d",
+					name, ErrUnsupportedFormat, dataSize, size)
+			}
+			break
+		}
+
+		data := make([]byte, dataSize)
+		if _, err = f.ReadAt(data, int64(pos+recordHeaderByteSize)); err != nil {
+			return err
+		}
+		if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(header[recordLengthByteSize:]) {
+			return fmt.Errorf("%s: %w: record at byte %d doesn't match its checksum", name, ErrUnsupportedFormat, pos)
+		}
+		if !encrypted {
+			h, err := readEntryHeader(data)
+			if err != nil || h.firstOffset < next {
+				return fmt.Errorf("%s: %w: record at byte %d doesn't hold offset %d or more",
+					name, ErrUnsupportedFormat, pos, next)
+			}
+			next = h.lastOffset + 1
+		}
+		pos += recordHeaderByteSize + dataSize
+	}
+	return nil
+}
+
+// migrateBaselineSegment rewrites the segment of the first version: its store framed every record as
+// [length][record] and its index had an entry for every record
+// the records get a checksum, then the indexes are rebuilt for the new positions and renamed before the store, so an
+// interrupted migration finds the store unchanged and starts over; a torn record after the last intact one is
+// dropped, returns the names of the rewritten files
+func migrateBaselineSegment(dir string, baseOffset uint64) ([]string, error) {
+	name := path.Join(dir, fmt.Sprintf("%d.store", baseOffset))
+	stat, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fileHeader{
+		Version:    formatVersion,
+		Kind:       storeFileKind,
+		BaseOffset: baseOffset,
+		CreatedAt:  stat.ModTime(),
+	}
+	store := header.encode()
+	var offsets []uint32
+	var positions []uint64
+	var timestamps []int64
+	next := baseOffset
+	for pos := uint64(0); pos < uint64(len(data)); {
+		left := uint64(len(data)) - pos
+		if left < recordLengthByteSize || binary.BigEndian.Uint64(data[pos:]) > left-recordLengthByteSize {
+			if len(positions) == 0 {
+				return nil, fmt.Errorf("%s: %w: %d bytes don't hold a record", name, ErrUnsupportedFormat, len(data))
+			}
+			break
+		}
+		end := pos + recordLengthByteSize + binary.BigEndian.Uint64(data[pos:])
+		record := data[pos+recordLengthByteSize : end]
+
+		rec := &api.LogRecord{}
+		if err = proto.Unmarshal(record, rec); err != nil || rec.Offset < next {
+			return nil, fmt.Errorf("%s: %w: record at byte %d doesn't hold offset %d or more",
+				name, ErrUnsupportedFormat, pos, next)
+		}
+		offsets = append(offsets, uint32(rec.Offset-baseOffset))
+		positions = append(positions, uint64(len(store))-fileHeaderSize)
+		timestamps = append(timestamps, rec.Timestamp)
+
+		frame := make([]byte, recordHeaderByteSize)
+		binary.BigEndian.PutUint64(frame[:recordLengthByteSize], uint64(len(record)))
+		binary.BigEndian.PutUint32(frame[recordLengthByteSize:], crc32.Checksum(record, crcTable))
+		store = append(append(store, frame...), record...)
+		next = rec.Offset + 1
+		pos = end
+	}
+
+	indexName := path.Join(dir, fmt.Sprintf("%d.index", baseOffset))
+	if _, err = rebuildIndex(indexName, baseOffset, offsets, positions, 0); err != nil {
+		return nil, err
+	}
+	timeIndexName := path.Join(dir, fmt.Sprintf("%d.timeindex", baseOffset))
+	if _, err = rebuildTimeIndex(timeIndexName, baseOffset, offsets, positions, timestamps,
+		defaultTimeIndexIntervalBytes); err != nil {
+		return nil, err
+	}
+	if err = replaceFile(name, store); err != nil {
+		return nil, err
+	}
+	return []string{name, indexName, timeIndexName}, nil
+}
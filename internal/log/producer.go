@@ -0,0 +1,381 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// idempotent producers register an id and number their appends with increasing sequences, an append carrying a
+// sequence the producer already used is answered with the offsets it was appended at instead of being appended again
+// the records of an idempotent append carry the id and the sequence of the producer, so the log itself tells which
+// appends happened: producers.json next to the segments holds the registered producers along with a checkpoint of
+// their last appends taken at a given next offset of the log, it is written when a producer registers, when the active
+// segment rolls and when the log is closed; opening the log forgets the appends whose records were lost in a crash
+// and replays the ones of the records written after the checkpoint
+
+const producersFileName = "producers.json"
+
+// number of appends per producer whose offsets are remembered to answer retries
+const producerWindow = 32
+
+type producerAppend struct {
+	Sequence    uint64
+	FirstOffset uint64
+	LastOffset  uint64
+}
+
+type producerState struct {
+	// serializes the appends of the producer
+	mux sync.Mutex
+	// the last appends of the producer, oldest first
+	Appends []producerAppend
+	// when the producer last registered or appended, see Config.ProducerExpiration
+	LastSeen time.Time
+}
+
+// idempotentAppend is the producer on whose behalf records are appended
+type idempotentAppend struct {
+	id       uint64
+	sequence uint64
+	state    *producerState
+}
+
+type producerTable struct {
+	// guards the content of the table and its file, the appends of the producers are only changed under it while the
+	// lock of the log is held for writing
+	mux        sync.Mutex
+	file       string
+	expiration time.Duration
+	LastID     uint64
+	// the next offset of the log when the table was written, the appends of the records from it on aren't in the file
+	Offset    uint64
+	Producers map[uint64]*producerState
+}
+
+// loadProducers reads the producers registered with the log stored in dir
+func loadProducers(dir string, expiration time.Duration) (*producerTable, error) {
+	t := &producerTable{
+		file:       path.Join(dir, producersFileName),
+		expiration: expiration,
+		Producers:  map[uint64]*producerState{},
+	}
+
+	data, err := ioutil.ReadFile(t.file)
+	if os.IsNotExist(err) {
+		return t, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("%s: %w", t.file, err)
+	}
+	// producers written before they were seen start expiring now
+	for _, p := range t.Producers {
+		if p.LastSeen.IsZero() {
+			p.LastSeen = time.Now()
+		}
+	}
+	return t, nil
+}
+
+// openProducers loads the producers of the log and reconciles their appends with its records, the next registered
+// producer gets an id greater than lastID
+// must be called with the lock held for writing, or before the log is shared
+func (log *Log) openProducers(lastID uint64) error {
+	t, err := loadProducers(log.dir, log.config.ProducerExpiration)
+	if err != nil {
+		return err
+	}
+	if t.LastID < lastID {
+		t.LastID = lastID
+	}
+
+	low, high := log.segments[0].startOffset, log.activeSegment.nextOffset
+	// the appends whose records didn't reach the disk before a crash are forgotten, their offsets are handed out again
+	for _, p := range t.Producers {
+		for i, a := range p.Appends {
+			if a.LastOffset >= high {
+				p.Appends = p.Appends[:i]
+				break
+			}
+		}
+	}
+
+	from := t.Offset
+	if from > high {
+		from = high
+	}
+	if from < low {
+		from = low
+	}
+	if len(t.Producers) > 0 {
+		if err = log.replayProducers(t, from); err != nil {
+			return err
+		}
+	}
+	log.producers = t
+	return nil
+}
+
+// replayProducers adds to the table the appends of the records stored from the given offset on
+// must be called with the lock held, or before the log is shared
+func (log *Log) replayProducers(t *producerTable, from uint64) error {
+	for offset := from; ; {
+		rec, err := log.read(offset)
+		if _, ok := err.(api.ErrOffsetOutOfRange); ok {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		offset = rec.Offset + 1
+
+		p, ok := t.Producers[rec.ProducerId]
+		if rec.ProducerId == 0 || !ok {
+			continue
+		}
+		if seen := time.Unix(0, rec.Timestamp); seen.After(p.LastSeen) {
+			p.LastSeen = seen
+		}
+		n := len(p.Appends)
+		if n > 0 && p.Appends[n-1].Sequence == rec.ProducerSequence {
+			if rec.Offset > p.Appends[n-1].LastOffset {
+				p.Appends[n-1].LastOffset = rec.Offset
+			}
+		} else if n == 0 || rec.ProducerSequence > p.Appends[n-1].Sequence {
+			p.add(producerAppend{Sequence: rec.ProducerSequence, FirstOffset: rec.Offset, LastOffset: rec.Offset})
+		}
+	}
+}
+
+// persist writes the table as of the given next offset of the log, must be called with the lock held
+func (t *producerTable) persist(offset uint64) error {
+	t.Offset = offset
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	if err = writeFileSync(t.file+".tmp", data); err != nil {
+		return err
+	}
+	return os.Rename(t.file+".tmp", t.file)
+}
+
+// checkpoint writes the table as of the given next offset of the log after forgetting the expired producers, the
+// lock of the log must be held; logs which never had a producer have no table to write
+func (t *producerTable) checkpoint(offset uint64) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.LastID == 0 {
+		return nil
+	}
+	t.expire()
+	return t.persist(offset)
+}
+
+// marshal encodes the table as of the given next offset of the log the way checkpoint writes it, nil when the log
+// never had a producer; the lock of the log must be held
+func (t *producerTable) marshal(offset uint64) ([]byte, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.LastID == 0 {
+		return nil, nil
+	}
+	written := t.Offset
+	t.Offset = offset
+	data, err := json.Marshal(t)
+	t.Offset = written
+	return data, err
+}
+
+// expire forgets the producers which neither registered nor appended for longer than the expiration, must be called
+// with the lock held
+func (t *producerTable) expire() {
+	deadline := time.Now().Add(-t.expiration)
+	for id, p := range t.Producers {
+		if p.LastSeen.Before(deadline) {
+			delete(t.Producers, id)
+		}
+	}
+}
+
+// register hands out a new producer id, the table is written as of the given next offset of the log
+func (t *producerTable) register(offset uint64) (uint64, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.expire()
+	id := t.LastID + 1
+	t.LastID = id
+	t.Producers[id] = &producerState{LastSeen: time.Now()}
+	if err := t.persist(offset); err != nil {
+		t.LastID = id - 1
+		delete(t.Producers, id)
+		return 0, err
+	}
+	return id, nil
+}
+
+// get returns the producer, seen now so it doesn't expire while it appends
+func (t *producerTable) get(id uint64) (*producerState, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	p, ok := t.Producers[id]
+	if !ok {
+		return nil, api.ErrUnknownProducer{ProducerID: id}
+	}
+	p.LastSeen = time.Now()
+	return p, nil
+}
+
+// duplicate returns the earlier append of the producer carrying the sequence, false when the sequence is new
+// a sequence which is neither new nor remembered is out of order
+func (t *producerTable) duplicate(id uint64, p *producerState, sequence uint64) (producerAppend, bool, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	n := len(p.Appends)
+	if n == 0 || sequence > p.Appends[n-1].Sequence {
+		return producerAppend{}, false, nil
+	}
+	for _, a := range p.Appends {
+		if a.Sequence == sequence {
+			return a, true, nil
+		}
+	}
+	return producerAppend{}, false, api.ErrOutOfOrderSequence{
+		ProducerID:   id,
+		Sequence:     sequence,
+		LastSequence: p.Appends[n-1].Sequence,
+	}
+}
+
+// appended remembers an append of the producer, the lock of the log must be held for writing
+func (t *producerTable) appended(p *producerState, a producerAppend) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	p.add(a)
+	p.LastSeen = time.Now()
+}
+
+func (p *producerState) add(a producerAppend) {
+	p.Appends = append(p.Appends, a)
+	if len(p.Appends) > producerWindow {
+		p.Appends = append([]producerAppend(nil), p.Appends[len(p.Appends)-producerWindow:]...)
+	}
+}
+
+// add registers a producer id handed out by another log, the table is written as of the given next offset of the log
+func (t *producerTable) add(id uint64, offset uint64) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if p, ok := t.Producers[id]; ok {
+		p.LastSeen = time.Now()
+		return nil
+	}
+	lastID := t.LastID
+	if id > t.LastID {
+		t.LastID = id
+	}
+	t.Producers[id] = &producerState{LastSeen: time.Now()}
+	if err := t.persist(offset); err != nil {
+		t.LastID = lastID
+		delete(t.Producers, id)
+		return err
+	}
+	return nil
+}
+
+// reset forgets the appends of every producer, whose offsets are about to be handed out again
+func (t *producerTable) reset(offset uint64) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.LastID == 0 {
+		return nil
+	}
+	for _, p := range t.Producers {
+		p.Appends = nil
+	}
+	return t.persist(offset)
+}
+
+func (t *producerTable) ids() []uint64 {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	ids := make([]uint64, 0, len(t.Producers))
+	for id := range t.Producers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// RegisterProducer hands out a new producer id for AppendIdempotent
+func (log *Log) RegisterProducer() (uint64, error) {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	if err := log.checkOpen(); err != nil {
+		return 0, err
+	}
+	return log.producers.register(log.activeSegment.nextOffset)
+}
+
+// AddProducer registers a producer id handed out by another log, so a producer appending to several logs keeps a
+// single id; ids handed out by RegisterProducer from then on are greater than it
+func (log *Log) AddProducer(id uint64) error {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	if err := log.checkOpen(); err != nil {
+		return err
+	}
+	return log.producers.add(id, log.activeSegment.nextOffset)
+}
+
+// ProducerIDs returns the registered producer ids in ascending order
+func (log *Log) ProducerIDs() []uint64 {
+	return log.producers.ids()
+}
+
+// AppendIdempotent appends the records like AppendCompressed on behalf of a registered producer
+// sequence must be greater than the one of the previous append of the producer; a sequence the producer already used
+// returns the offsets the records were appended at the first time without appending them again, the last appends of
+// every producer are remembered and an older sequence fails with api.ErrOutOfOrderSequence
+// the records carry the producer id and the sequence; an append whose records were lost in a crash, which
+// DurabilityOS allows, is forgotten when the log is opened again so its retry is appended anew
+func (log *Log) AppendIdempotent(producerID uint64, sequence uint64, recs []*api.LogRecord, compression api.Compression) (first uint64, last uint64, err error) {
+	if sequence == 0 {
+		return 0, 0, fmt.Errorf("sequences start at 1")
+	}
+	p, err := log.producers.get(producerID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	a, ok, err := log.producers.duplicate(producerID, p, sequence)
+	if err != nil {
+		return 0, 0, err
+	}
+	if ok {
+		return a.FirstOffset, a.LastOffset, nil
+	}
+
+	return log.appendIf(recs, compression, nil, &idempotentAppend{id: producerID, sequence: sequence, state: p})
+}
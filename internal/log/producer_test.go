@@ -0,0 +1,245 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestLog_AppendIdempotent(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-producer")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+
+	record := func(value string) []*api.LogRecord {
+		return []*api.LogRecord{{Value: []byte(value)}}
+	}
+
+	id, err := log.RegisterProducer()
+	assert.NoError(t, err)
+	other, err := log.RegisterProducer()
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, other)
+
+	first, _, err := log.AppendIdempotent(id, 1, record("a"), api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	_, _, err = log.AppendIdempotent(other, 1, record("b"), api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	// sequences may skip numbers
+	batchFirst, batchLast, err := log.AppendIdempotent(id, 5, []*api.LogRecord{
+		{Value: []byte("c")}, {Value: []byte("d")},
+	}, api.Compression_GZIP)
+	assert.NoError(t, err)
+
+	// retries are answered with the offsets of the first append
+	offset, _, err := log.AppendIdempotent(id, 1, record("a"), api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	assert.Equal(t, first, offset)
+	f, l, err := log.AppendIdempotent(id, 5, record("c"), api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	assert.Equal(t, batchFirst, f)
+	assert.Equal(t, batchLast, l)
+	_, high := log.Offsets()
+	assert.Equal(t, uint64(3), high)
+
+	// a sequence between two appends was never used
+	_, _, err = log.AppendIdempotent(id, 3, record("x"), api.Compression_DEFAULT_COMPRESSION)
+	assert.Equal(t, api.ErrOutOfOrderSequence{ProducerID: id, Sequence: 3, LastSequence: 5}, err)
+
+	_, _, err = log.AppendIdempotent(other+1, 1, record("x"), api.Compression_DEFAULT_COMPRESSION)
+	assert.Equal(t, api.ErrUnknownProducer{ProducerID: other + 1}, err)
+	_, _, err = log.AppendIdempotent(id, 0, record("x"), api.Compression_DEFAULT_COMPRESSION)
+	assert.Error(t, err)
+
+	// the producers survive the log being reopened, only the last appends of each are remembered
+	assert.NoError(t, log.Close())
+	log, err = NewLog(dir, Config{})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	offset, _, err = log.AppendIdempotent(id, 1, record("a"), api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	assert.Equal(t, first, offset)
+
+	for i := 0; i < producerWindow; i++ {
+		_, _, err = log.AppendIdempotent(id, uint64(6+i), record(fmt.Sprintf("%d", i)), api.Compression_DEFAULT_COMPRESSION)
+		assert.NoError(t, err)
+	}
+	_, _, err = log.AppendIdempotent(id, 1, record("a"), api.Compression_DEFAULT_COMPRESSION)
+	assert.Equal(t, api.ErrOutOfOrderSequence{ProducerID: id, Sequence: 1, LastSequence: 5 + producerWindow}, err)
+
+	id2, err := log.RegisterProducer()
+	assert.NoError(t, err)
+	assert.Equal(t, other+1, id2)
+}
+
+func TestLog_AddProducer(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-producer")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, log.AddProducer(7))
+	assert.NoError(t, log.AddProducer(7))
+	assert.NoError(t, log.AddProducer(3))
+	assert.Equal(t, []uint64{3, 7}, log.ProducerIDs())
+
+	// ids handed out afterwards don't collide with the added ones
+	id, err := log.RegisterProducer()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), id)
+
+	_, _, err = log.AppendIdempotent(3, 1, []*api.LogRecord{{Value: []byte("a")}}, api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	assert.NoError(t, log.Close())
+}
+
+func TestLog_ProducersReconciled(t *testing.T) {
+	record := func(value string) []*api.LogRecord {
+		return []*api.LogRecord{{Value: []byte(value)}}
+	}
+
+	t.Run("lost records", func(t *testing.T) {
+		dir, _ := ioutil.TempDir(os.TempDir(), "log-producer")
+		defer os.RemoveAll(dir)
+
+		log, err := NewLog(dir, Config{})
+		assert.NoError(t, err)
+		id, err := log.RegisterProducer()
+		assert.NoError(t, err)
+		for i := 1; i <= 3; i++ {
+			_, _, err = log.AppendIdempotent(id, uint64(i), record("a"), api.Compression_DEFAULT_COMPRESSION)
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, log.Close())
+
+		// the last record didn't reach the disk while producers.json did
+		assert.NoError(t, os.Truncate(path.Join(dir, "0.index"), 1024))
+		stat, _ := os.Stat(path.Join(dir, "0.store"))
+		assert.NoError(t, os.Truncate(path.Join(dir, "0.store"), stat.Size()-1))
+
+		log, err = NewLog(dir, Config{})
+		assert.NoError(t, err)
+		defer log.Close()
+		_, high := log.Watermarks()
+		assert.Equal(t, uint64(2), high)
+
+		// another producer takes the offset of the lost record, the retry is appended again instead of being answered
+		// with it
+		other, err := log.RegisterProducer()
+		assert.NoError(t, err)
+		taken, _, err := log.AppendIdempotent(other, 1, record("b"), api.Compression_DEFAULT_COMPRESSION)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), taken)
+		offset, _, err := log.AppendIdempotent(id, 3, record("a"), api.Compression_DEFAULT_COMPRESSION)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), offset)
+		offset, _, err = log.AppendIdempotent(id, 2, record("a"), api.Compression_DEFAULT_COMPRESSION)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), offset)
+	})
+
+	t.Run("records after the checkpoint", func(t *testing.T) {
+		dir, _ := ioutil.TempDir(os.TempDir(), "log-producer")
+		defer os.RemoveAll(dir)
+
+		crashed, err := NewLog(dir, Config{Durability: DurabilityAlways})
+		assert.NoError(t, err)
+		id, err := crashed.RegisterProducer()
+		assert.NoError(t, err)
+		first, last, err := crashed.AppendIdempotent(id, 1, []*api.LogRecord{
+			{Value: []byte("a")}, {Value: []byte("b")},
+		}, api.Compression_NO_COMPRESSION)
+		assert.NoError(t, err)
+		// producers.json was written when the producer registered, the log isn't closed
+		crashed.stop()
+
+		log, err := NewLog(dir, Config{})
+		assert.NoError(t, err)
+		defer log.Close()
+		f, l, err := log.AppendIdempotent(id, 1, record("a"), api.Compression_DEFAULT_COMPRESSION)
+		assert.NoError(t, err)
+		assert.Equal(t, first, f)
+		assert.Equal(t, last, l)
+		_, high := log.Watermarks()
+		assert.Equal(t, uint64(2), high)
+	})
+}
+
+func TestLog_ProducersSnapshotRestore(t *testing.T) {
+	srcDir, _ := ioutil.TempDir(os.TempDir(), "log-producer-src")
+	defer os.RemoveAll(srcDir)
+	src, err := NewLog(srcDir, Config{})
+	assert.NoError(t, err)
+	defer src.Close()
+
+	id, err := src.RegisterProducer()
+	assert.NoError(t, err)
+	first, _, err := src.AppendIdempotent(id, 1, []*api.LogRecord{{Value: []byte("a")}}, api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	buf := &bytes.Buffer{}
+	assert.NoError(t, src.Snapshot(buf))
+
+	dstDir, _ := ioutil.TempDir(os.TempDir(), "log-producer-dst")
+	defer os.RemoveAll(dstDir)
+	dst, err := NewLog(dstDir, Config{})
+	assert.NoError(t, err)
+	defer dst.Close()
+	for i := 0; i < 3; i++ {
+		_, err = dst.RegisterProducer()
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, dst.Restore(buf))
+
+	offset, _, err := dst.AppendIdempotent(id, 1, []*api.LogRecord{{Value: []byte("a")}}, api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	assert.Equal(t, first, offset)
+	// the ids dst handed out before the restore aren't handed out again
+	next, err := dst.RegisterProducer()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), next)
+
+	// a reset log hands out its offsets again, the appends made at them are forgotten
+	assert.NoError(t, dst.Reset())
+	offset, _, err = dst.AppendIdempotent(id, 1, []*api.LogRecord{{Value: []byte("b")}}, api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+	rec, err := dst.Read(offset)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b"), rec.Value)
+}
+
+func TestLog_ProducerExpiration(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-producer")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{ProducerExpiration: 200 * time.Millisecond})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	idle, err := log.RegisterProducer()
+	assert.NoError(t, err)
+	active, err := log.RegisterProducer()
+	assert.NoError(t, err)
+	for i := 1; i <= 3; i++ {
+		time.Sleep(80 * time.Millisecond)
+		_, _, err = log.AppendIdempotent(active, uint64(i), []*api.LogRecord{{Value: []byte("a")}}, api.Compression_DEFAULT_COMPRESSION)
+		assert.NoError(t, err)
+	}
+
+	// expired producers are forgotten when another one registers
+	_, err = log.RegisterProducer()
+	assert.NoError(t, err)
+	_, _, err = log.AppendIdempotent(idle, 1, []*api.LogRecord{{Value: []byte("a")}}, api.Compression_DEFAULT_COMPRESSION)
+	assert.Equal(t, api.ErrUnknownProducer{ProducerID: idle}, err)
+	_, _, err = log.AppendIdempotent(active, 4, []*api.LogRecord{{Value: []byte("a")}}, api.Compression_DEFAULT_COMPRESSION)
+	assert.NoError(t, err)
+}
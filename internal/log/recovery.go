@@ -0,0 +1,166 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"fmt"
+	"math"
+)
+
+// SegmentRecovery describes what had to be repaired in a segment which wasn't closed cleanly
+type SegmentRecovery struct {
+	BaseOffset uint64
+	StoreFile  string
+	IndexFile  string
+	// number of bytes cut from the tail of the store because they held a torn or corrupt record
+	TruncatedStoreBytes uint64
+	// number of index entries which pointed to missing or mismatching records
+	DroppedIndexEntries uint64
+	// number of index entries written for records the index didn't know about
+	RebuiltIndexEntries uint64
+}
+
+// isConsistent cheaply checks whether the index and the store agree with each other
+// the last index entry must point to a record of the store, none of the records stored after it may have been due an
+// entry, and the last of them must end exactly where the store ends
+func (fs *fileSegment) isConsistent() bool {
+	if fs.index.size%entryWidth != 0 || fs.index.size > uint64(len(fs.index.data)) {
+		return false
+	}
+
+	entries := fs.index.entries()
+	if entries == 0 {
+		return fs.store.size == 0
+	}
+
+	// offsets grow at least by one per entry, a zero-filled tail breaks that
+	off, indexedPos, err := fs.index.Read(-1)
+	if err != nil || uint64(off) < entries-1 {
+		return false
+	}
+
+	offset := fs.startOffset + uint64(off)
+	pos := indexedPos
+	for pos < fs.store.size {
+		if pos != indexedPos && pos-indexedPos >= fs.config.IndexIntervalBytes {
+			return false
+		}
+
+		data, size, err := fs.store.readRecord(pos)
+		if err != nil {
+			return false
+		}
+
+		h, err := readEntryHeader(data)
+		if err != nil || h.firstOffset < offset {
+			return false
+		}
+		if pos == indexedPos && h.firstOffset != offset {
+			return false
+		}
+		offset = h.lastOffset + 1
+		pos += size
+	}
+
+	return pos == fs.store.size && pos > indexedPos
+}
+
+// recover reconciles the index with the store after an unclean shutdown
+// the store is the source of truth: its tail is truncated to the last fully written record, index entries which don't
+// match a record are dropped and records the index never saw are indexed again
+// only a trailing partial record is cut: an invalid first record, a complete record failing its checksum, or records
+// the index can't hold entries for fail the recovery instead
+// returns nil when the segment was already consistent
+func (fs *fileSegment) recover() (*SegmentRecovery, error) {
+	if fs.isConsistent() {
+		return nil, nil
+	}
+
+	report := &SegmentRecovery{
+		BaseOffset: fs.startOffset,
+		StoreFile:  fs.store.Name(),
+		IndexFile:  fs.index.Name(),
+	}
+
+	if max := uint64(len(fs.index.data)); fs.index.size > max {
+		fs.index.size = max
+	}
+	fs.index.size -= fs.index.size % entryWidth
+
+	// offsets of the first records of the entries relative to the segment, compaction may leave gaps between them
+	offsets := make([]uint32, 0)
+	positions := make([]uint64, 0)
+	next := fs.startOffset
+	validSize, err := fs.store.scan(func(pos uint64, data []byte) error {
+		h, err := readEntryHeader(data)
+		if err != nil {
+			return errCorruptRecord
+		}
+		if h.firstOffset < next || h.lastOffset-fs.startOffset > math.MaxUint32 {
+			return errCorruptRecord
+		}
+		offsets = append(offsets, uint32(h.firstOffset-fs.startOffset))
+		positions = append(positions, pos)
+		next = h.lastOffset + 1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// a store whose first record is invalid wasn't written by this version, or is damaged beyond a torn write
+	if validSize < fs.store.size {
+		torn, err := fs.store.tornTail(validSize)
+		if err != nil {
+			return nil, err
+		}
+		if validSize == 0 || !torn {
+			return nil, fmt.Errorf("%s: %w at position %d, only a trailing partial record is truncated",
+				fs.store.Name(), api.ErrCorruptRecord{Offset: next, BaseOffset: fs.startOffset}, validSize)
+		}
+	}
+
+	written := writtenEntries(fs.index.data[:fs.index.size])
+
+	// keep the index entries which agree with the store, the first record must be indexed
+	var valid uint64
+	// number of records up to the one pointed to by the last valid entry
+	var indexed int
+	for record := 0; valid < written; record++ {
+		off, pos, err := fs.index.Read(int32(valid))
+		if err != nil {
+			return nil, err
+		}
+		for record < len(positions) && positions[record] < pos {
+			record++
+		}
+		if record == len(positions) || positions[record] != pos || offsets[record] != off || (valid == 0 && record != 0) {
+			break
+		}
+		fs.indexedPos = pos
+		valid++
+		indexed = record + 1
+	}
+	report.DroppedIndexEntries = written - valid
+	fs.index.truncate(valid)
+
+	// index the records written after the last valid entry
+	for i := indexed; i < len(positions); i++ {
+		if !fs.indexDue(positions[i]) {
+			continue
+		}
+		if err := fs.index.Write(offsets[i], positions[i]); err != nil {
+			return nil, fmt.Errorf("%s: the index can't hold the entries of the records stored from position %d, "+
+				"MaxIndexBytes must be raised: %w", fs.index.Name(), positions[i], err)
+		}
+		fs.indexedPos = positions[i]
+		report.RebuiltIndexEntries++
+	}
+
+	if validSize < fs.store.size {
+		report.TruncatedStoreBytes = fs.store.size - validSize
+		if err := fs.store.truncate(validSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
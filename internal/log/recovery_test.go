@@ -0,0 +1,212 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+// writes count records to a fresh segment and closes it cleanly
+func setupRecoverySegment(t *testing.T, dir string, startOffset uint64, count int) (recordSizes []uint64) {
+	segm, err := newFileSegment(dir, startOffset, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	assert.NoError(t, err)
+	assert.Nil(t, segm.recovery)
+
+	for i := 0; i < count; i++ {
+		before := segm.store.size
+		_, err = segm.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record-%d", i))})
+		assert.NoError(t, err)
+		recordSizes = append(recordSizes, segm.store.size-before)
+	}
+	assert.NoError(t, segm.Close())
+	return
+}
+
+func TestSegment_RecoverTornStore(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_recovery")
+	defer os.RemoveAll(dir)
+
+	startOffset := uint64(8)
+	sizes := setupRecoverySegment(t, dir, startOffset, 3)
+
+	// the index keeps its pre-allocated size and the last record is only partially written
+	indexPath := path.Join(dir, fmt.Sprintf("%d.index", startOffset))
+	storePath := path.Join(dir, fmt.Sprintf("%d.store", startOffset))
+	assert.NoError(t, os.Truncate(indexPath, 1024))
+	stat, _ := os.Stat(storePath)
+	assert.NoError(t, os.Truncate(storePath, stat.Size()-3))
+
+	segm, err := newFileSegment(dir, startOffset, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	assert.NoError(t, err)
+	defer segm.Remove()
+
+	assert.Equal(t, &SegmentRecovery{
+		BaseOffset:          startOffset,
+		StoreFile:           storePath,
+		IndexFile:           indexPath,
+		TruncatedStoreBytes: sizes[2] - 3,
+		DroppedIndexEntries: 1,
+	}, segm.recovery)
+	assert.Equal(t, startOffset+2, segm.nextOffset)
+	assert.Equal(t, sizes[0]+sizes[1], segm.store.size)
+
+	off, err := segm.Append(&api.LogRecord{Value: []byte("after-crash")})
+	assert.NoError(t, err)
+	assert.Equal(t, startOffset+2, off)
+
+	rec, err := segm.Read(off)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("after-crash"), rec.Value)
+}
+
+func TestSegment_RecoverCorruptRecordFollowedByIntactOnes(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_recovery")
+	defer os.RemoveAll(dir)
+
+	sizes := setupRecoverySegment(t, dir, 0, 3)
+
+	// a byte of the second record is damaged, the third one is intact and must not be cut
+	indexPath := path.Join(dir, "0.index")
+	storePath := path.Join(dir, "0.store")
+	assert.NoError(t, os.Truncate(indexPath, 1024))
+	f, err := os.OpenFile(storePath, os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, int64(fileHeaderSize+sizes[0]+sizes[1]-1))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	before, _ := os.Stat(storePath)
+
+	_, err = newFileSegment(dir, 0, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	assert.Error(t, err)
+	var corrupt api.ErrCorruptRecord
+	assert.True(t, errors.As(err, &corrupt))
+	assert.Equal(t, uint64(1), corrupt.Offset)
+
+	after, _ := os.Stat(storePath)
+	assert.Equal(t, before.Size(), after.Size())
+}
+
+func TestSegment_RecoverCorruptLastRecord(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_recovery")
+	defer os.RemoveAll(dir)
+
+	setupRecoverySegment(t, dir, 0, 3)
+
+	// the last record was written completely, a damaged byte isn't a torn write
+	storePath := path.Join(dir, "0.store")
+	assert.NoError(t, os.Truncate(path.Join(dir, "0.index"), 1024))
+	before, _ := os.Stat(storePath)
+	f, err := os.OpenFile(storePath, os.O_RDWR, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, before.Size()-1)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	_, err = newFileSegment(dir, 0, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	var corrupt api.ErrCorruptRecord
+	assert.True(t, errors.As(err, &corrupt))
+	assert.Equal(t, uint64(2), corrupt.Offset)
+
+	after, _ := os.Stat(storePath)
+	assert.Equal(t, before.Size(), after.Size())
+}
+
+func TestSegment_RecoverInvalidFirstRecord(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_recovery")
+	defer os.RemoveAll(dir)
+
+	sizes := setupRecoverySegment(t, dir, 0, 1)
+
+	// a store holding nothing valid is never wiped, even when its only record looks torn
+	storePath := path.Join(dir, "0.store")
+	assert.NoError(t, os.Truncate(storePath, int64(fileHeaderSize+sizes[0]-1)))
+	before, _ := os.Stat(storePath)
+
+	_, err := newFileSegment(dir, 0, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	var corrupt api.ErrCorruptRecord
+	assert.True(t, errors.As(err, &corrupt))
+	assert.Equal(t, uint64(0), corrupt.Offset)
+
+	after, _ := os.Stat(storePath)
+	assert.Equal(t, before.Size(), after.Size())
+}
+
+func TestSegment_RecoverIndexFull(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_recovery")
+	defer os.RemoveAll(dir)
+
+	setupRecoverySegment(t, dir, 0, 3)
+	storePath := path.Join(dir, "0.store")
+	before, _ := os.Stat(storePath)
+
+	// reopened with an index too small for the records of the store, they are kept and the recovery fails
+	_, err := newFileSegment(dir, 0, Config{MaxStoreBytes: 1024, MaxIndexBytes: entryWidth})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "MaxIndexBytes")
+
+	after, _ := os.Stat(storePath)
+	assert.Equal(t, before.Size(), after.Size())
+}
+
+func TestSegment_RecoverMissingIndexEntries(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_recovery")
+	defer os.RemoveAll(dir)
+
+	startOffset := uint64(0)
+	setupRecoverySegment(t, dir, startOffset, 3)
+
+	// only the first index entry reached the disk, the rest of the file is zero-filled
+	indexPath := path.Join(dir, fmt.Sprintf("%d.index", startOffset))
+	assert.NoError(t, os.Truncate(indexPath, int64(entryWidth)))
+	assert.NoError(t, os.Truncate(indexPath, 1024))
+
+	segm, err := newFileSegment(dir, startOffset, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	assert.NoError(t, err)
+	defer segm.Remove()
+
+	assert.NotNil(t, segm.recovery)
+	assert.Equal(t, uint64(0), segm.recovery.TruncatedStoreBytes)
+	assert.Equal(t, uint64(2), segm.recovery.RebuiltIndexEntries)
+	assert.Equal(t, startOffset+3, segm.nextOffset)
+
+	for i := 0; i < 3; i++ {
+		rec, err := segm.Read(startOffset + uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(fmt.Sprintf("record-%d", i)), rec.Value)
+	}
+}
+
+func TestLog_Recovered(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_recovery")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte("record")})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, log.Close())
+	assert.Empty(t, log.Recovered())
+
+	assert.NoError(t, os.Truncate(path.Join(dir, "0.index"), 1024))
+	stat, _ := os.Stat(path.Join(dir, "0.store"))
+	assert.NoError(t, os.Truncate(path.Join(dir, "0.store"), stat.Size()-1))
+
+	log, err = NewLog(dir, Config{})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	assert.Len(t, log.Recovered(), 1)
+	_, high := log.Offsets()
+	assert.Equal(t, uint64(1), high)
+
+	off, err := log.Append(&api.LogRecord{Value: []byte("record")})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), off)
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+// RemovedSegment describes a segment deleted by the retention rules
+type RemovedSegment struct {
+	BaseOffset uint64
+	NextOffset uint64
+	// bytes taken by every file of the segment
+	Bytes uint64
+	// last time the segment was written to
+	ModTime time.Time
+}
+
+// RetentionStats sums up everything removed by the retention rules since the log was opened
+type RetentionStats struct {
+	Segments uint64
+	Records  uint64
+	Bytes    uint64
+}
+
+// EnforceRetention removes the sealed segments which break the retention rules and returns them
+func (log *Log) EnforceRetention() ([]RemovedSegment, error) {
+	log.mux.Lock()
+	defer log.mux.Unlock()
+
+	if err := log.checkOpen(); err != nil {
+		return nil, err
+	}
+	rules := log.config.Retention
+	if len(log.segments) == 0 {
+		return nil, nil
+	}
+
+	var totalBytes uint64
+	segmentBytes := make([]uint64, len(log.segments))
+	for i, segment := range log.segments {
+		bytes, err := segment.diskBytes()
+		if err != nil {
+			return nil, err
+		}
+		segmentBytes[i] = bytes
+		totalBytes += bytes
+	}
+	nextOffset := log.segments[len(log.segments)-1].nextOffset
+
+	removed := make([]RemovedSegment, 0)
+	now := time.Now()
+	for i, segment := range log.segments {
+		if segment == log.activeSegment {
+			break
+		}
+
+		// records left once the segment is gone
+		if nextOffset-segment.nextOffset < rules.MinRecords {
+			break
+		}
+
+		stat, err := os.Stat(segment.store.Name())
+		if err != nil {
+			return removed, err
+		}
+
+		bytes := segmentBytes[i]
+		expired := rules.MaxAge > 0 && now.Sub(stat.ModTime()) > rules.MaxAge
+		oversized := rules.MaxBytes > 0 && totalBytes > rules.MaxBytes
+		if !expired && !oversized {
+			break
+		}
+
+		if err = segment.Remove(); err != nil {
+			return removed, err
+		}
+		log.segments = log.segments[1:]
+		totalBytes -= bytes
+
+		r := RemovedSegment{
+			BaseOffset: segment.startOffset,
+			NextOffset: segment.nextOffset,
+			Bytes:      bytes,
+			ModTime:    stat.ModTime(),
+		}
+		removed = append(removed, r)
+		log.removed.Segments++
+		log.removed.Records += r.NextOffset - r.BaseOffset
+		log.removed.Bytes += r.Bytes
+	}
+
+	return removed, nil
+}
+
+// RetentionStats returns what the retention rules removed so far
+func (log *Log) RetentionStats() RetentionStats {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	return log.removed
+}
+
+func (log *Log) enforceRetentionPeriodically() {
+	ticker := time.NewTicker(log.config.Retention.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-log.done:
+			return
+		case <-ticker.C:
+			removed, err := log.EnforceRetention()
+			for _, r := range removed {
+				log.logger.Info(
+					"removed segment by retention",
+					zap.Uint64("base_offset", r.BaseOffset),
+					zap.Uint64("next_offset", r.NextOffset),
+					zap.Uint64("bytes", r.Bytes),
+					zap.Time("mod_time", r.ModTime),
+				)
+			}
+			if err != nil {
+				log.logger.Error("failed to enforce retention", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// creates a log of 4 segments holding 3, 3, 3 and 1 records
+func setupRetentionLog(t *testing.T, retention Retention) (*Log, func()) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-retention")
+	log, err := NewLog(dir, Config{
+		MaxStoreBytes: 1024,
+		MaxIndexBytes: entryWidth * 3,
+		Retention:     retention,
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte("retained")})
+		assert.NoError(t, err)
+	}
+	assert.Len(t, log.segments, 4)
+
+	return log, func() {
+		_ = log.Remove()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestLog_RetentionMaxAge(t *testing.T) {
+	log, teardown := setupRetentionLog(t, Retention{MaxAge: time.Hour})
+	defer teardown()
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, segment := range log.segments[:2] {
+		assert.NoError(t, os.Chtimes(segment.store.Name(), old, old))
+	}
+
+	removed, err := log.EnforceRetention()
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+	assert.Equal(t, uint64(0), removed[0].BaseOffset)
+	assert.Equal(t, uint64(6), removed[1].NextOffset)
+
+	low, high := log.Offsets()
+	assert.Equal(t, uint64(6), low)
+	assert.Equal(t, uint64(9), high)
+
+	_, err = log.Read(5)
+	assert.Error(t, err)
+	assert.Equal(t, RetentionStats{Segments: 2, Records: 6, Bytes: removed[0].Bytes + removed[1].Bytes}, log.RetentionStats())
+}
+
+func TestLog_RetentionMaxBytes(t *testing.T) {
+	log, teardown := setupRetentionLog(t, Retention{})
+	defer teardown()
+
+	// the time index counts against the cap along with the store and the index
+	segment := log.segments[0]
+	bytes, err := segment.diskBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, 3*fileHeaderSize+segment.store.size+segment.index.size+segment.timeIndex.size, bytes)
+	// room left for the last sealed segment and the active one
+	lastBytes, err := log.segments[2].diskBytes()
+	assert.NoError(t, err)
+	activeBytes, err := log.activeSegment.diskBytes()
+	assert.NoError(t, err)
+	log.config.Retention.MaxBytes = lastBytes + activeBytes
+
+	removed, err := log.EnforceRetention()
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+	assert.Equal(t, bytes, removed[0].Bytes)
+
+	low, _ := log.Offsets()
+	assert.Equal(t, uint64(6), low)
+}
+
+func TestLog_RetentionKeepsActiveAndMinRecords(t *testing.T) {
+	log, teardown := setupRetentionLog(t, Retention{MaxBytes: 1, MinRecords: 5})
+	defer teardown()
+
+	removed, err := log.EnforceRetention()
+	assert.NoError(t, err)
+	// removing the segment starting at 3 would leave only 4 records
+	assert.Len(t, removed, 1)
+
+	log.config.Retention.MinRecords = 0
+	removed, err = log.EnforceRetention()
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+	assert.Len(t, log.segments, 1)
+	assert.Equal(t, log.activeSegment, log.segments[0])
+}
+
+func TestLog_RetentionBackground(t *testing.T) {
+	log, teardown := setupRetentionLog(t, Retention{MaxBytes: 1, CheckInterval: 10 * time.Millisecond})
+	defer teardown()
+
+	assert.Eventually(t, func() bool {
+		low, _ := log.Offsets()
+		return low == 9
+	}, time.Second, 10*time.Millisecond)
+}
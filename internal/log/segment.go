@@ -4,8 +4,10 @@ import (
 	"EchoLog/api/v1"
 	"fmt"
 	"github.com/golang/protobuf/proto"
+	"io"
 	"os"
 	"path"
+	"sync"
 )
 
 func newFileSegment(dirName string, startOffset uint64, config Config) (segment *fileSegment, err error) {
@@ -22,9 +24,39 @@ func newFileSegment(dirName string, startOffset uint64, config Config) (segment
 		return nil, err
 	}
 
+	// a segment written by an older version must be migrated before a data key is generated for it
+	if stat, err := storeFile.Stat(); err != nil {
+		return nil, err
+	} else if stat.Size() > 0 {
+		if _, err = readFileHeader(storeFile, storeFileKind, startOffset); err != nil {
+			return nil, err
+		}
+	}
+
+	aead, keyFile, err := setupDataKey(dirName, startOffset, config.Keys)
+	if err != nil {
+		return nil, err
+	}
+	segment.keyFile = keyFile
+
+	var flags uint8
+	if aead != nil {
+		flags |= fileFlagEncrypted
+	}
+	header, err := setupFileHeader(storeFile, storeFileKind, startOffset, flags)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted := header.Flags&fileFlagEncrypted != 0; encrypted && aead == nil {
+		return nil, fmt.Errorf("%s: segment is encrypted and its key file is missing", storeFile.Name())
+	} else if !encrypted && aead != nil {
+		return nil, fmt.Errorf("%s: segment is stored in plaintext and has a key file", storeFile.Name())
+	}
+
 	if segment.store, err = newFileStore(storeFile); err != nil {
 		return nil, err
 	}
+	segment.store.aead = aead
 
 	indexFile, err := os.OpenFile(path.Join(dirName, fmt.Sprintf("%d.index", startOffset)),
 		os.O_RDWR|os.O_CREATE,
@@ -35,14 +67,39 @@ func newFileSegment(dirName string, startOffset uint64, config Config) (segment
 		return nil, err
 	}
 
+	if _, err = setupFileHeader(indexFile, indexFileKind, startOffset, 0); err != nil {
+		return nil, err
+	}
 	if segment.index, err = newFileIndex(indexFile, config.MaxIndexBytes); err != nil {
 		return nil, err
 	}
 
-	if off, _, err := segment.index.Read(-1); err != nil {
+	if segment.recovery, err = segment.recover(); err != nil {
+		return nil, err
+	}
+
+	if off, pos, err := segment.index.Read(-1); err != nil {
 		segment.nextOffset = startOffset
 	} else {
+		segment.indexedPos = pos
 		segment.nextOffset = startOffset + uint64(off) + 1
+		// with a sparse index the last records of the segment come after the last entry
+		for pos < segment.store.size {
+			data, size, err := segment.store.readRecord(pos)
+			if err != nil {
+				return nil, err
+			}
+			h, err := readEntryHeader(data)
+			if err != nil {
+				return nil, api.ErrCorruptRecord{Offset: segment.nextOffset, BaseOffset: segment.startOffset}
+			}
+			segment.nextOffset = h.lastOffset + 1
+			pos += size
+		}
+	}
+
+	if err = segment.setupTimeIndex(dirName); err != nil {
+		return nil, err
 	}
 
 	return segment, nil
@@ -51,56 +108,240 @@ func newFileSegment(dirName string, startOffset uint64, config Config) (segment
 type fileSegment struct {
 	store       *fileStore
 	index       *fileIndex
+	timeIndex   *fileIndex
 	nextOffset  uint64
 	startOffset uint64
 	config      Config
+	// what was repaired when the segment was opened, nil if it was closed cleanly
+	recovery *SegmentRecovery
+	// highest record timestamp of the segment
+	maxTimestamp int64
+	// store position of the record pointed to by the last time index entry
+	timeIndexedPos uint64
+	// store position of the record pointed to by the last offset index entry
+	indexedPos uint64
+	// file holding the data key of an encrypted segment, empty when the segment is stored in plaintext
+	keyFile string
+
+	// serializes sync, which runs without the lock of the log, with Close
+	syncMux sync.Mutex
+	closed  bool
 }
 
 func (fs *fileSegment) Append(rec *api.LogRecord) (appendIndex uint64, err error) {
 	rec.Offset = fs.nextOffset
-	appendIndex = fs.nextOffset
+	return rec.Offset, fs.appendRecord(rec)
+}
 
+// appendRecord writes the record under the offset it already holds, which mustn't be before the next offset
+// of the segment; a rewritten segment keeps the offsets of the records it was compacted from
+func (fs *fileSegment) appendRecord(rec *api.LogRecord) error {
 	data, err := proto.Marshal(rec)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	_, pos, err := fs.store.Append(data)
+	return fs.appendEntry(data, rec.Offset, rec.Offset, rec.Timestamp)
+}
+
+// appendBatch writes the records, which already hold their offsets, compressed together as a single entry
+func (fs *fileSegment) appendBatch(recs []*api.LogRecord, compression api.Compression) error {
+	data, err := encodeBatch(recs, compression)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	err = fs.index.Write(
-		uint32(fs.nextOffset-fs.startOffset),
-		pos,
-	)
+	var maxTimestamp int64
+	for _, rec := range recs {
+		if rec.Timestamp > maxTimestamp {
+			maxTimestamp = rec.Timestamp
+		}
+	}
+
+	return fs.appendEntry(data, recs[0].Offset, recs[len(recs)-1].Offset, maxTimestamp)
+}
 
+// appendEntry writes an entry holding the records from first to last offset to the store and indexes it
+func (fs *fileSegment) appendEntry(data []byte, first uint64, last uint64, maxTimestamp int64) error {
+	if first < fs.nextOffset {
+		return fmt.Errorf("offset %d is before the next offset of the segment: %d", first, fs.nextOffset)
+	}
+
+	_, pos, err := fs.store.Append(data)
 	if err != nil {
-		return 0, err
+		return err
+	}
+
+	if fs.indexDue(pos) {
+		err = fs.index.Write(
+			uint32(first-fs.startOffset),
+			pos,
+		)
+
+		if err != nil {
+			return err
+		}
+		fs.indexedPos = pos
 	}
 
-	appendIndex = fs.nextOffset
-	fs.nextOffset += 1
-	return
+	// every record of the entry is before the time index entry, so it's keyed with the last one
+	fs.indexTime(uint32(last-fs.startOffset), pos, maxTimestamp)
+
+	fs.nextOffset = last + 1
+	return nil
+}
+
+// indexDue tells whether the entry stored at pos gets an entry in the offset index
+// the first entry of the segment always gets one, the next ones when Config.IndexIntervalBytes were stored since
+// the previous one
+func (fs *fileSegment) indexDue(pos uint64) bool {
+	return fs.index.size == 0 || pos-fs.indexedPos >= fs.config.IndexIntervalBytes
 }
 
+// Read returns the record stored under the given offset or, when compaction removed it, the first record after it
+// returns io.EOF when the segment holds no record at or after the offset
 func (fs *fileSegment) Read(offset uint64) (*api.LogRecord, error) {
-	_, pos, err := fs.index.Read(int32(offset - fs.startOffset))
+	rec, _, err := fs.seek(offset)
+	return rec, err
+}
+
+// seek returns the first record at or after the given offset and its position in the store
+// the store is scanned forward from the closest index entry before the offset
+func (fs *fileSegment) seek(offset uint64) (*api.LogRecord, uint64, error) {
+	if offset < fs.startOffset {
+		offset = fs.startOffset
+	}
+	if offset >= fs.nextOffset {
+		return nil, 0, io.EOF
+	}
+
+	// the first record of the segment is always indexed, compaction may have removed the ones before it
+	entry, _ := fs.index.floor(uint32(offset - fs.startOffset))
+	off, pos, err := fs.index.Read(int32(entry))
 	if err != nil {
+		return nil, 0, err
+	}
+
+	for next := fs.startOffset + uint64(off); pos < fs.store.size; {
+		// the exact offset of a corrupt record isn't known past the index entry
+		if next < offset {
+			next = offset
+		}
+		entry, err := fs.readAt(next, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, rec := range entry.records {
+			if rec.Offset >= offset {
+				return rec, pos, nil
+			}
+		}
+		next = entry.lastOffset + 1
+		pos += entry.size
+	}
+
+	return nil, 0, io.EOF
+}
+
+// readAt decodes the entry stored at pos
+// offset is reported when the entry turns out to be corrupt
+func (fs *fileSegment) readAt(offset uint64, pos uint64) (*storeEntry, error) {
+	data, size, err := fs.store.readRecord(pos)
+	if err == errCorruptRecord {
+		return nil, api.ErrCorruptRecord{Offset: offset, BaseOffset: fs.startOffset}
+	} else if err != nil {
 		return nil, err
 	}
 
-	data, err := fs.store.Read(pos)
+	h, recs, err := decodeEntry(data)
 	if err != nil {
-		return nil, err
+		return nil, api.ErrCorruptRecord{Offset: offset, BaseOffset: fs.startOffset}
 	}
 
-	rec := &api.LogRecord{}
-	if err = proto.Unmarshal(data, rec); err != nil {
-		return nil, err
+	return &storeEntry{entryHeader: h, records: recs, size: size}, nil
+}
+
+// forEachEntry calls fn with every entry of the segment, in order
+func (fs *fileSegment) forEachEntry(fn func(*storeEntry) error) error {
+	for pos, next := uint64(0), fs.startOffset; pos < fs.store.size; {
+		entry, err := fs.readAt(next, pos)
+		if err != nil {
+			return err
+		}
+		if err = fn(entry); err != nil {
+			return err
+		}
+		next = entry.lastOffset + 1
+		pos += entry.size
 	}
+	return nil
+}
+
+// forEach calls fn with every record of the segment, in order
+func (fs *fileSegment) forEach(fn func(*api.LogRecord) error) error {
+	return fs.forEachEntry(func(entry *storeEntry) error {
+		for _, rec := range entry.records {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// segmentMark is the state of a segment before a batch was appended to it
+type segmentMark struct {
+	storeSize      uint64
+	indexEntries   uint64
+	timeEntries    uint64
+	nextOffset     uint64
+	maxTimestamp   int64
+	timeIndexedPos uint64
+	indexedPos     uint64
+}
+
+func (fs *fileSegment) mark() segmentMark {
+	return segmentMark{
+		storeSize:      fs.store.size,
+		indexEntries:   fs.index.entries(),
+		timeEntries:    fs.timeIndex.entries(),
+		nextOffset:     fs.nextOffset,
+		maxTimestamp:   fs.maxTimestamp,
+		timeIndexedPos: fs.timeIndexedPos,
+		indexedPos:     fs.indexedPos,
+	}
+}
 
-	return rec, nil
+// rollback discards everything appended to the segment since the mark was taken
+func (fs *fileSegment) rollback(m segmentMark) error {
+	if err := fs.store.truncate(m.storeSize); err != nil {
+		return err
+	}
+	fs.index.truncate(m.indexEntries)
+	fs.timeIndex.truncate(m.timeEntries)
+	fs.nextOffset = m.nextOffset
+	fs.maxTimestamp = m.maxTimestamp
+	fs.timeIndexedPos = m.timeIndexedPos
+	fs.indexedPos = m.indexedPos
+	return nil
+}
+
+// sync forces the records already handed to the OS and the entries of both indexes to stable storage
+// a segment closed in the meantime was synced by Close
+func (fs *fileSegment) sync() error {
+	fs.syncMux.Lock()
+	defer fs.syncMux.Unlock()
+
+	if fs.closed {
+		return nil
+	}
+	if err := fs.store.File.Sync(); err != nil {
+		return err
+	}
+	if err := fs.index.mmap.Flush(); err != nil {
+		return err
+	}
+	return fs.timeIndex.mmap.Flush()
 }
 
 func (fs *fileSegment) IsFull() bool {
@@ -108,6 +349,10 @@ func (fs *fileSegment) IsFull() bool {
 }
 
 func (fs *fileSegment) Close() error {
+	fs.syncMux.Lock()
+	defer fs.syncMux.Unlock()
+
+	fs.closed = true
 	if err := fs.store.Close(); err != nil {
 		return err
 	}
@@ -116,9 +361,51 @@ func (fs *fileSegment) Close() error {
 		return err
 	}
 
+	if err := fs.timeIndex.Close(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// segmentFile is a file of a segment along with the number of its bytes holding data
+type segmentFile struct {
+	name string
+	size uint64
+}
+
+// files lists every file making up the segment, the index files are pre-allocated while the segment is open so
+// only their header and entries are counted
+func (fs *fileSegment) files() ([]segmentFile, error) {
+	files := []segmentFile{
+		{name: fs.store.Name(), size: fileHeaderSize + fs.store.size},
+		{name: fs.index.Name(), size: fileHeaderSize + fs.index.size},
+		{name: fs.timeIndex.Name(), size: fileHeaderSize + fs.timeIndex.size},
+	}
+	// the data key travels with the segment, still wrapped with the master key
+	if fs.keyFile != "" {
+		stat, err := os.Stat(fs.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, segmentFile{name: fs.keyFile, size: uint64(stat.Size())})
+	}
+	return files, nil
+}
+
+// diskBytes sums the sizes of every file of the segment
+func (fs *fileSegment) diskBytes() (uint64, error) {
+	files, err := fs.files()
+	if err != nil {
+		return 0, err
+	}
+	var bytes uint64
+	for _, f := range files {
+		bytes += f.size
+	}
+	return bytes, nil
+}
+
 func (fs *fileSegment) Remove() error {
 	if err := fs.Close(); err != nil {
 		return err
@@ -126,6 +413,10 @@ func (fs *fileSegment) Remove() error {
 
 	_ = os.Remove(fs.store.Name())
 	_ = os.Remove(fs.index.Name())
+	_ = os.Remove(fs.timeIndex.Name())
+	if fs.keyFile != "" {
+		_ = os.Remove(fs.keyFile)
+	}
 
 	return nil
 }
@@ -2,11 +2,15 @@ package log
 
 import (
 	"EchoLog/api/v1"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"testing"
 )
 
@@ -39,7 +43,7 @@ func TestSegment(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, startOffset+i, off)
 
-		read, err := segm.Read(i)
+		read, err := segm.Read(startOffset + i)
 		assert.NoError(t, err)
 		assert.Equal(t, rec.Value, read.Value)
 	}
@@ -51,3 +55,147 @@ func TestSegment(t *testing.T) {
 
 	assert.NoError(t, segm.Remove())
 }
+
+func TestSegment_ReadCorrupt(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_segment")
+	defer os.RemoveAll(dir)
+
+	startOffset := uint64(16)
+	segm, err := newFileSegment(dir, startOffset, Config{
+		MaxStoreBytes: 1024,
+		MaxIndexBytes: 1024,
+	})
+	assert.NoError(t, err)
+
+	off, err := segm.Append(&api.LogRecord{Value: []byte("corrupt-me")})
+	assert.NoError(t, err)
+	_, err = segm.Append(&api.LogRecord{Value: []byte("keep-me")})
+	assert.NoError(t, err)
+	assert.NoError(t, segm.Close())
+
+	// damage the first record on disk, leaving the tail intact
+	storePath := path.Join(dir, fmt.Sprintf("%d.store", startOffset))
+	data, err := ioutil.ReadFile(storePath)
+	assert.NoError(t, err)
+	data[fileHeaderSize+recordHeaderByteSize] ^= 0xff
+	assert.NoError(t, ioutil.WriteFile(storePath, data, 0644))
+
+	segm, err = newFileSegment(dir, startOffset, Config{
+		MaxStoreBytes: 1024,
+		MaxIndexBytes: 1024,
+	})
+	assert.NoError(t, err)
+
+	assert.Nil(t, segm.recovery)
+
+	_, err = segm.Read(off)
+	assert.Equal(t, api.ErrCorruptRecord{Offset: off, BaseOffset: startOffset}, err)
+
+	rec, err := segm.Read(off + 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("keep-me"), rec.Value)
+
+	assert.NoError(t, segm.Remove())
+}
+
+func TestSegment_SparseIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_segment")
+	defer os.RemoveAll(dir)
+
+	startOffset := uint64(16)
+	config := Config{
+		MaxStoreBytes:      4096,
+		MaxIndexBytes:      1024,
+		IndexIntervalBytes: 100,
+	}
+	segm, err := newFileSegment(dir, startOffset, config)
+	assert.NoError(t, err)
+
+	const records = 20
+	for i := uint64(0); i < records; i++ {
+		_, err = segm.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("segment-%d", i))})
+		assert.NoError(t, err)
+	}
+	// only the records 100 store bytes apart are indexed
+	assert.Less(t, segm.index.entries(), uint64(records/2))
+
+	check := func(segm *fileSegment) {
+		assert.Equal(t, startOffset+records, segm.nextOffset)
+		for i := uint64(0); i < records; i++ {
+			rec, err := segm.Read(startOffset + i)
+			assert.NoError(t, err)
+			assert.Equal(t, startOffset+i, rec.Offset)
+			assert.Equal(t, []byte(fmt.Sprintf("segment-%d", i)), rec.Value)
+		}
+		_, err := segm.Read(startOffset + records)
+		assert.Equal(t, io.EOF, err)
+	}
+	check(segm)
+
+	// the records after the last entry are found again when the segment is reopened
+	assert.NoError(t, segm.Close())
+	segm, err = newFileSegment(dir, startOffset, config)
+	assert.NoError(t, err)
+	assert.Nil(t, segm.recovery)
+	check(segm)
+	assert.NoError(t, segm.Close())
+
+	// an index which lost the entries due after its first one is rebuilt
+	indexPath := path.Join(dir, fmt.Sprintf("%d.index", startOffset))
+	assert.NoError(t, os.Truncate(indexPath, fileHeaderSize+int64(entryWidth)))
+	segm, err = newFileSegment(dir, startOffset, config)
+	assert.NoError(t, err)
+	defer segm.Remove()
+	assert.NotNil(t, segm.recovery)
+	assert.NotZero(t, segm.recovery.RebuiltIndexEntries)
+	check(segm)
+}
+
+// writeBaselineStore writes the records the way the first version framed them, their length followed by their data
+func writeBaselineStore(t *testing.T, name string, recs []*api.LogRecord) []byte {
+	t.Helper()
+
+	var store []byte
+	for _, rec := range recs {
+		data, err := proto.Marshal(rec)
+		assert.NoError(t, err)
+		length := make([]byte, recordLengthByteSize)
+		binary.BigEndian.PutUint64(length, uint64(len(data)))
+		store = append(append(store, length...), data...)
+	}
+	assert.NoError(t, ioutil.WriteFile(name, store, 0644))
+	return store
+}
+
+func TestSegment_BaselineStore(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_segment")
+	defer os.RemoveAll(dir)
+
+	name := path.Join(dir, "0.store")
+	store := writeBaselineStore(t, name, []*api.LogRecord{
+		{Value: []byte("first"), Offset: 0},
+		{Value: []byte("second"), Offset: 1},
+	})
+
+	// the records of an older version are refused until migrated, they aren't reported as corrupt
+	_, err := newFileSegment(dir, 0, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	assert.True(t, errors.Is(err, ErrLegacyFormat))
+	data, err := ioutil.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, store, data)
+}
+
+func TestSegment_SyncAfterClose(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_segment")
+	defer os.RemoveAll(dir)
+
+	segm, err := newFileSegment(dir, 0, Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024})
+	assert.NoError(t, err)
+	_, err = segm.Append(&api.LogRecord{Value: []byte("record")})
+	assert.NoError(t, err)
+	assert.NoError(t, segm.sync())
+
+	// a sync racing with the removal of the segment finds it synced by Close
+	assert.NoError(t, segm.Close())
+	assert.NoError(t, segm.sync())
+}
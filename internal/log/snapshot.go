@@ -0,0 +1,433 @@
+package log
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"time"
+)
+
+// a snapshot is a tar archive holding the segment files followed by a manifest describing them
+const (
+	snapshotVersion      = 1
+	snapshotManifestName = "manifest.json"
+	// suffix of the directory, next to the log directory, where a snapshot is extracted before replacing the log
+	restoreSuffix = ".restore"
+	// suffix the log directory is renamed with while a restored one takes its place
+	replacedSuffix = ".replaced"
+)
+
+var snapshotFileName = regexp.MustCompile(`^[0-9]+\.(store|index|timeindex|key)$`)
+
+type snapshotManifest struct {
+	Version   int
+	CreatedAt time.Time
+	Segments  []snapshotSegment
+	// the producers.json of the log, absent when the log never had an idempotent producer
+	Producers json.RawMessage `json:",omitempty"`
+	// the Config.IndexIntervalBytes the indexes are verified with, absent from the snapshots written before it was
+	// recorded whose indexes only need to point to the first record
+	IndexIntervalBytes *uint64 `json:",omitempty"`
+}
+
+type snapshotSegment struct {
+	BaseOffset uint64
+	NextOffset uint64
+	Files      []snapshotFile
+}
+
+type snapshotFile struct {
+	Name   string
+	Size   int64
+	CRC32C uint32
+}
+
+// snapshotSource is a segment file captured when the snapshot was taken
+type snapshotSource struct {
+	file *os.File
+	size int64
+}
+
+// Snapshot writes a consistent point-in-time copy of the log to w
+// the state of the segments is captured under the lock, the copy itself runs while records keep being appended
+func (log *Log) Snapshot(w io.Writer) error {
+	manifest := snapshotManifest{
+		Version:            snapshotVersion,
+		CreatedAt:          time.Now(),
+		IndexIntervalBytes: &log.config.IndexIntervalBytes,
+	}
+	sources := make([]snapshotSource, 0)
+	defer func() {
+		for _, s := range sources {
+			_ = s.file.Close()
+		}
+	}()
+
+	log.mux.Lock()
+	if err := log.checkOpen(); err != nil {
+		log.mux.Unlock()
+		return err
+	}
+	for _, segment := range log.segments {
+		if err := segment.store.flush(); err != nil {
+			log.mux.Unlock()
+			return err
+		}
+
+		s := snapshotSegment{
+			BaseOffset: segment.startOffset,
+			NextOffset: segment.nextOffset,
+		}
+		files, err := segment.files()
+		if err != nil {
+			log.mux.Unlock()
+			return err
+		}
+
+		// the files are opened again so removing or compacting the segment doesn't affect the copy
+		for _, f := range files {
+			file, err := os.Open(f.name)
+			if err != nil {
+				log.mux.Unlock()
+				return err
+			}
+			sources = append(sources, snapshotSource{file: file, size: int64(f.size)})
+			s.Files = append(s.Files, snapshotFile{Name: path.Base(f.name), Size: int64(f.size)})
+		}
+		manifest.Segments = append(manifest.Segments, s)
+	}
+	producers, err := log.producers.marshal(log.activeSegment.nextOffset)
+	log.mux.Unlock()
+	if err != nil {
+		return err
+	}
+	manifest.Producers = producers
+
+	return writeSnapshot(w, &manifest, sources)
+}
+
+// SnapshotDir writes a snapshot of the log stored in dir like Snapshot does, copying the files as they are on disk
+// without opening the log, which must not be open; the segments must pass Verify so the snapshot can be restored
+// the segments are verified with config like Verify does
+func SnapshotDir(dir string, w io.Writer, config Config) error {
+	reports, err := Verify(dir, false, config)
+	if err != nil {
+		return err
+	}
+
+	manifest := snapshotManifest{
+		Version:            snapshotVersion,
+		CreatedAt:          time.Now(),
+		IndexIntervalBytes: &config.IndexIntervalBytes,
+	}
+	sources := make([]snapshotSource, 0)
+	defer func() {
+		for _, s := range sources {
+			_ = s.file.Close()
+		}
+	}()
+
+	for i, r := range reports {
+		if len(r.Errors) > 0 {
+			return fmt.Errorf("%s: segment %d: %s", dir, r.BaseOffset, r.Errors[0])
+		}
+
+		s := snapshotSegment{BaseOffset: r.BaseOffset, NextOffset: r.BaseOffset}
+		if i+1 < len(reports) {
+			s.NextOffset = reports[i+1].BaseOffset
+		} else if r.Records > 0 {
+			s.NextOffset = r.LastOffset + 1
+		}
+
+		names := []string{
+			r.StoreFile,
+			r.IndexFile,
+			path.Join(dir, fmt.Sprintf("%d.timeindex", r.BaseOffset)),
+		}
+		if r.Encrypted {
+			names = append(names, keyFileName(dir, r.BaseOffset))
+		}
+		for _, name := range names {
+			file, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			stat, err := file.Stat()
+			if err != nil {
+				_ = file.Close()
+				return err
+			}
+			sources = append(sources, snapshotSource{file: file, size: stat.Size()})
+			s.Files = append(s.Files, snapshotFile{Name: path.Base(name), Size: stat.Size()})
+		}
+		manifest.Segments = append(manifest.Segments, s)
+	}
+
+	// the producers are reconciled with the records when the restored log is opened
+	producers, err := ioutil.ReadFile(path.Join(dir, producersFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	manifest.Producers = producers
+
+	return writeSnapshot(w, &manifest, sources)
+}
+
+// writeSnapshot writes the files of the manifest, read from the sources in the same order, and the manifest to w
+func writeSnapshot(w io.Writer, manifest *snapshotManifest, sources []snapshotSource) error {
+	tw := tar.NewWriter(w)
+	i := 0
+	for si := range manifest.Segments {
+		for fi := range manifest.Segments[si].Files {
+			f := &manifest.Segments[si].Files[fi]
+			hdr := &tar.Header{
+				Name:    f.Name,
+				Mode:    0644,
+				Size:    f.Size,
+				ModTime: manifest.CreatedAt,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+
+			hash := crc32.New(crcTable)
+			src := io.NewSectionReader(sources[i].file, 0, sources[i].size)
+			if _, err := io.Copy(io.MultiWriter(tw, hash), src); err != nil {
+				return err
+			}
+			f.CRC32C = hash.Sum32()
+			i++
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    snapshotManifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: manifest.CreatedAt,
+	}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err = tw.Write(data); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// Restore replaces the content of the log with a snapshot written by Snapshot
+// the snapshot is extracted and its integrity verified next to the log directory, which is swapped with it once the
+// restored segments are opened; on error the log is left untouched
+func (log *Log) Restore(r io.Reader) error {
+	tmpDir, _, err := stageSnapshot(log.dir, r, log.config.Keys)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	log.mux.Lock()
+	defer log.mux.Unlock()
+
+	if err = log.checkOpen(); err != nil {
+		return err
+	}
+
+	replaced, err := swapDir(log.dir, tmpDir)
+	if err != nil {
+		return err
+	}
+
+	segments, activeSegment, unsynced, producers := log.segments, log.activeSegment, log.unsynced, log.producers
+	log.segments, log.activeSegment, log.unsynced = nil, nil, nil
+	err = log.setup()
+	// the producers are the ones of the snapshot, ids registered since aren't handed out again
+	if err == nil {
+		err = log.openProducers(producers.LastID)
+	}
+	if err == nil {
+		err = log.producers.checkpoint(log.activeSegment.nextOffset)
+	}
+	if err != nil {
+		// the restored segments are closed, the old ones are still open and their directory goes back in place
+		_ = log.closeSegments(false)
+		log.segments, log.activeSegment, log.unsynced, log.producers = segments, activeSegment, unsynced, producers
+		if swapErr := os.Rename(log.dir, tmpDir); swapErr != nil {
+			return fmt.Errorf("%v, the log was left in %s: %w", err, replaced, swapErr)
+		}
+		if swapErr := os.Rename(replaced, log.dir); swapErr != nil {
+			return fmt.Errorf("%v, the log was left in %s: %w", err, replaced, swapErr)
+		}
+		return err
+	}
+
+	for _, segment := range segments {
+		_ = segment.Close()
+	}
+	if err = os.RemoveAll(replaced); err != nil {
+		return err
+	}
+
+	// consumers waiting for records get to see the restored ones
+	close(log.appendedCh)
+	log.appendedCh = make(chan struct{})
+	return nil
+}
+
+// RestoreDir replaces the content of the log stored in dir with a snapshot written by Snapshot, without opening the
+// log, which must not be open; dir is created when it doesn't exist
+// the snapshot is extracted and verified next to dir and swapped with it once complete, on error dir is left untouched
+func RestoreDir(dir string, r io.Reader, keys KeyProvider) error {
+	tmpDir, _, err := stageSnapshot(dir, r, keys)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	replaced, err := swapDir(dir, tmpDir)
+	if err != nil || replaced == "" {
+		return err
+	}
+	return os.RemoveAll(replaced)
+}
+
+// stageSnapshot extracts the snapshot in a directory next to dir and verifies it, the directory is removed on error
+func stageSnapshot(dir string, r io.Reader, keys KeyProvider) (string, *snapshotManifest, error) {
+	tmpDir := path.Clean(dir) + restoreSuffix
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		return "", nil, err
+	}
+
+	manifest, err := extractSnapshot(r, tmpDir)
+	if err == nil && manifest.Producers != nil {
+		err = writeFileSync(path.Join(tmpDir, producersFileName), manifest.Producers)
+	}
+	if err == nil {
+		config := Config{Keys: keys, IndexIntervalBytes: math.MaxUint64}
+		if manifest.IndexIntervalBytes != nil {
+			config.IndexIntervalBytes = *manifest.IndexIntervalBytes
+		}
+		err = verifySnapshot(tmpDir, config)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return "", nil, err
+	}
+	return tmpDir, manifest, nil
+}
+
+func verifySnapshot(dir string, config Config) error {
+	reports, err := Verify(dir, false, config)
+	if err != nil {
+		return err
+	}
+	for _, report := range reports {
+		if len(report.Errors) > 0 {
+			return fmt.Errorf("invalid snapshot: segment %d: %s", report.BaseOffset, report.Errors[0])
+		}
+	}
+	return nil
+}
+
+// swapDir moves dir aside and staged in its place, it returns where dir was moved, empty when it didn't exist
+// on error dir is left in place
+func swapDir(dir string, staged string) (string, error) {
+	replaced := path.Clean(dir) + replacedSuffix
+	if err := os.RemoveAll(replaced); err != nil {
+		return "", err
+	}
+	if err := os.Rename(dir, replaced); os.IsNotExist(err) {
+		replaced = ""
+	} else if err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(staged, dir); err != nil {
+		if replaced != "" {
+			_ = os.Rename(replaced, dir)
+		}
+		return "", err
+	}
+	return replaced, nil
+}
+
+// extractSnapshot writes the files of the snapshot to dir and checks them against the manifest
+func extractSnapshot(r io.Reader, dir string) (*snapshotManifest, error) {
+	type extracted struct {
+		size   int64
+		crc32c uint32
+	}
+	files := make(map[string]extracted)
+	var manifest *snapshotManifest
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if hdr.Name == snapshotManifestName {
+			manifest = &snapshotManifest{}
+			if err = json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("invalid snapshot manifest: %w", err)
+			}
+			continue
+		}
+
+		if !snapshotFileName.MatchString(hdr.Name) {
+			return nil, fmt.Errorf("invalid snapshot: unexpected file %q", hdr.Name)
+		}
+
+		f, err := os.OpenFile(path.Join(dir, hdr.Name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, err
+		}
+		hash := crc32.New(crcTable)
+		n, err := io.Copy(io.MultiWriter(f, hash), tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = extracted{size: n, crc32c: hash.Sum32()}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("invalid snapshot: manifest missing")
+	}
+	if manifest.Version != snapshotVersion {
+		return nil, fmt.Errorf("invalid snapshot: unsupported version %d", manifest.Version)
+	}
+
+	for _, s := range manifest.Segments {
+		for _, f := range s.Files {
+			e, ok := files[f.Name]
+			if !ok {
+				return nil, fmt.Errorf("invalid snapshot: %s missing", f.Name)
+			}
+			if e.size != f.Size || e.crc32c != f.CRC32C {
+				return nil, fmt.Errorf("invalid snapshot: %s doesn't match its checksum", f.Name)
+			}
+		}
+	}
+
+	return manifest, nil
+}
@@ -0,0 +1,185 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestLog_SnapshotRestore(t *testing.T) {
+	config := Config{
+		MaxStoreBytes: 1024,
+		MaxIndexBytes: entryWidth * 3,
+	}
+
+	srcDir, _ := ioutil.TempDir(os.TempDir(), "log-snapshot-src")
+	defer os.RemoveAll(srcDir)
+	src, err := NewLog(srcDir, config)
+	assert.NoError(t, err)
+
+	for i := 0; i < 8; i++ {
+		_, err = src.Append(&api.LogRecord{Key: []byte{byte('a' + i%2)}, Value: []byte{byte(i)}})
+		assert.NoError(t, err)
+	}
+	// compaction leaves gaps which the restored log must keep
+	_, err = src.Compact()
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, src.Snapshot(buf))
+	// records appended after the snapshot aren't part of it
+	_, err = src.Append(&api.LogRecord{Value: []byte("late")})
+	assert.NoError(t, err)
+
+	dstDir, _ := ioutil.TempDir(os.TempDir(), "log-snapshot-dst")
+	defer os.RemoveAll(dstDir)
+	dst, err := NewLog(dstDir, config)
+	assert.NoError(t, err)
+	_, err = dst.Append(&api.LogRecord{Value: []byte("replaced")})
+	assert.NoError(t, err)
+
+	assert.NoError(t, dst.Restore(bytes.NewReader(buf.Bytes())))
+
+	for offset := uint64(0); offset < 8; offset++ {
+		expected, err := src.Read(offset)
+		assert.NoError(t, err)
+		rec, err := dst.Read(offset)
+		assert.NoError(t, err)
+		assert.Equal(t, expected.Offset, rec.Offset, "reading offset %d", offset)
+		assert.Equal(t, expected.Value, rec.Value)
+	}
+	_, err = dst.Read(8)
+	assert.Error(t, err)
+
+	// the restored log keeps on appending after the offsets of the snapshot
+	offset, err := dst.Append(&api.LogRecord{Value: []byte("next")})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), offset)
+
+	assert.NoError(t, src.Close())
+	assert.NoError(t, dst.Close())
+
+	// nothing is left over from the extraction and the restored directory opens cleanly
+	_, err = os.Stat(dstDir + restoreSuffix)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(dstDir + replacedSuffix)
+	assert.True(t, os.IsNotExist(err))
+	reports, err := Verify(dstDir, false, config)
+	assert.NoError(t, err)
+	for _, r := range reports {
+		assert.Empty(t, r.Errors)
+	}
+}
+
+func TestLog_RestoreCorrupted(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-snapshot-corrupt")
+	defer os.RemoveAll(dir)
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte("record")})
+		assert.NoError(t, err)
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, log.Snapshot(buf))
+
+	_, err = log.Append(&api.LogRecord{Value: []byte("kept")})
+	assert.NoError(t, err)
+
+	// flip a byte of the first record's value
+	data := buf.Bytes()
+	i := bytes.Index(data, []byte("record"))
+	assert.Greater(t, i, 0)
+	data[i] ^= 0xff
+	assert.Error(t, log.Restore(bytes.NewReader(data)))
+
+	assert.Error(t, log.Restore(bytes.NewReader([]byte("not a snapshot"))))
+
+	// the log is left as it was
+	lowest, highest := log.Offsets()
+	assert.Equal(t, uint64(0), lowest)
+	assert.Equal(t, uint64(3), highest)
+	rec, err := log.Read(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("kept"), rec.Value)
+}
+
+func TestLog_RestoreWakesWaiters(t *testing.T) {
+	srcDir, _ := ioutil.TempDir(os.TempDir(), "log-snapshot-src")
+	defer os.RemoveAll(srcDir)
+	src, err := NewLog(srcDir, Config{})
+	assert.NoError(t, err)
+	defer src.Close()
+	for i := 0; i < 2; i++ {
+		_, err = src.Append(&api.LogRecord{Value: []byte("record")})
+		assert.NoError(t, err)
+	}
+	buf := &bytes.Buffer{}
+	assert.NoError(t, src.Snapshot(buf))
+
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-snapshot-dst")
+	defer os.RemoveAll(dir)
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	waited := make(chan error)
+	go func() {
+		waited <- log.WaitFor(ctx, 1)
+	}()
+
+	assert.NoError(t, log.Restore(buf))
+	assert.NoError(t, <-waited)
+}
+
+func TestSnapshotDir(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-snapshot-dir")
+	defer os.RemoveAll(dir)
+	log, err := NewLog(dir, Config{})
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte("record")})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, log.Close())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, SnapshotDir(dir, buf, Config{}))
+
+	// a failed restore leaves the directory as it was
+	assert.Error(t, RestoreDir(dir, bytes.NewReader([]byte("not a snapshot")), nil))
+	_, err = os.Stat(dir + restoreSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	dstDir, _ := ioutil.TempDir(os.TempDir(), "log-snapshot-dir-dst")
+	defer os.RemoveAll(dstDir)
+	assert.NoError(t, RestoreDir(dstDir, bytes.NewReader(buf.Bytes()), nil))
+	_, err = os.Stat(dstDir + replacedSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	dst, err := NewLog(dstDir, Config{})
+	assert.NoError(t, err)
+	rec, err := dst.Read(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("record"), rec.Value)
+	assert.NoError(t, dst.Close())
+
+	// a store which doesn't verify isn't snapshotted
+	f, err := os.OpenFile(path.Join(dir, "0.store"), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("garbage"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Error(t, SnapshotDir(dir, &bytes.Buffer{}, Config{}))
+}
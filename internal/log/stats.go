@@ -0,0 +1,70 @@
+package log
+
+import "EchoLog/api/v1"
+
+// SegmentStats describes the content of a segment of the log
+type SegmentStats struct {
+	BaseOffset uint64
+	NextOffset uint64
+	Records    uint64
+	StoreBytes uint64
+	IndexBytes uint64
+	// number of records stored in compressed batches
+	CompressedRecords uint64
+	// number of store bytes the records would take if none of them was compressed
+	UncompressedBytes uint64
+}
+
+// CompressionRatio returns how many times smaller compression made the store, 1 when nothing is compressed
+func (s SegmentStats) CompressionRatio() float64 {
+	if s.StoreBytes == 0 {
+		return 1
+	}
+	return float64(s.UncompressedBytes) / float64(s.StoreBytes)
+}
+
+// SegmentStats walks the segments of the log and describes each of them
+// the headers of the compressed batches hold their uncompressed size, nothing is decompressed
+func (log *Log) SegmentStats() ([]SegmentStats, error) {
+	log.mux.RLock()
+	defer log.mux.RUnlock()
+
+	if err := log.checkOpen(); err != nil {
+		return nil, err
+	}
+	stats := make([]SegmentStats, 0, len(log.segments))
+	for _, segment := range log.segments {
+		s := SegmentStats{
+			BaseOffset: segment.startOffset,
+			NextOffset: segment.nextOffset,
+			StoreBytes: segment.store.size,
+			IndexBytes: segment.index.size,
+		}
+
+		for pos := uint64(0); pos < segment.store.size; {
+			data, size, err := segment.store.readRecord(pos)
+			if err == errCorruptRecord {
+				return nil, api.ErrCorruptRecord{Offset: s.BaseOffset, BaseOffset: segment.startOffset}
+			} else if err != nil {
+				return nil, err
+			}
+			h, err := readEntryHeader(data)
+			if err != nil {
+				return nil, api.ErrCorruptRecord{Offset: s.BaseOffset, BaseOffset: segment.startOffset}
+			}
+
+			s.Records += h.records
+			if h.compression != api.Compression_NO_COMPRESSION {
+				s.CompressedRecords += h.records
+				s.UncompressedBytes += h.uncompressedBytes
+			} else {
+				s.UncompressedBytes += size
+			}
+			pos += size
+		}
+
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
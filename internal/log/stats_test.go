@@ -0,0 +1,43 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLog_SegmentStats(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-stats")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxStoreBytes: 4096, MaxIndexBytes: 1024})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	value := bytes.Repeat([]byte(`{"status":"active"}`), 10)
+	recs := make([]*api.LogRecord, 10)
+	for i := range recs {
+		recs[i] = &api.LogRecord{Value: value}
+	}
+	_, _, err = log.AppendCompressed(recs, api.Compression_GZIP)
+	assert.NoError(t, err)
+	_, err = log.Append(&api.LogRecord{Value: value})
+	assert.NoError(t, err)
+
+	stats, err := log.SegmentStats()
+	assert.NoError(t, err)
+	assert.Len(t, stats, 1)
+	s := stats[0]
+	assert.Equal(t, uint64(0), s.BaseOffset)
+	assert.Equal(t, uint64(11), s.NextOffset)
+	assert.Equal(t, uint64(11), s.Records)
+	assert.Equal(t, uint64(10), s.CompressedRecords)
+	assert.Equal(t, log.segments[0].store.size, s.StoreBytes)
+	assert.Greater(t, s.UncompressedBytes, 11*uint64(len(value)))
+	assert.Greater(t, s.CompressionRatio(), 5.0)
+
+	assert.Equal(t, 1.0, SegmentStats{}.CompressionRatio())
+}
@@ -2,21 +2,31 @@ package log
 
 import (
 	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
+// newFileStore expects the file to start with its header, positions in the store are relative to the end of it
 func newFileStore(f *os.File) (*fileStore, error) {
 	if stat, err := os.Stat(f.Name()); err != nil {
 		return nil, err
+	} else if stat.Size() < fileHeaderSize {
+		return nil, fmt.Errorf("%s: %w", f.Name(), ErrLegacyFormat)
 	} else {
-		size := uint64(stat.Size())
+		size := uint64(stat.Size()) - fileHeaderSize
 		return &fileStore{
-			File: f,
-			buf:  bufio.NewWriter(f),
-			size: size,
+			File:    f,
+			buf:     bufio.NewWriter(f),
+			size:    size,
+			flushed: size,
 		}, nil
 	}
 }
@@ -25,23 +35,50 @@ func newFileStore(f *os.File) (*fileStore, error) {
 // * because uint64 will always take 8 bytes
 const recordLengthByteSize = 8
 
+// number of bytes used for storing the CRC32 checksum of the record data
+const recordChecksumByteSize = 4
+
+// every record is framed as [length][checksum][data]
+const recordHeaderByteSize = recordLengthByteSize + recordChecksumByteSize
+
+// returned by fileStore.Read when the framing or the checksum of a record doesn't match its data
+var errCorruptRecord = errors.New("corrupt record")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
 type fileStore struct {
 	*os.File
 	mux  sync.Mutex
 	buf  *bufio.Writer
 	size uint64
+	// number of bytes handed to the file, always at a record boundary; accessed atomically so readers only take
+	// the mutex when the record they want is still buffered
+	flushed uint64
+	// seals the records of an encrypted segment, nil when the segment is stored in plaintext
+	aead cipher.AEAD
 }
 
 func (fs *fileStore) Append(data []byte) (byteSize uint64, offset uint64, err error) {
 	fs.mux.Lock()
 	defer fs.mux.Unlock()
 
+	if fs.aead != nil {
+		if data, err = fs.seal(fs.size, data); err != nil {
+			return
+		}
+	}
+
 	recordLength := uint64(len(data))
 
 	// write data byte size
 	if err = binary.Write(fs.buf, binary.BigEndian, recordLength); err != nil {
 		return
 	}
+
+	// write data checksum
+	if err = binary.Write(fs.buf, binary.BigEndian, crc32.Checksum(data, crcTable)); err != nil {
+		return
+	}
 	var nn int
 
 	// write actual data
@@ -49,48 +86,191 @@ func (fs *fileStore) Append(data []byte) (byteSize uint64, offset uint64, err er
 		return
 	}
 
-	byteSize = uint64(nn) + recordLengthByteSize
+	byteSize = uint64(nn) + recordHeaderByteSize
 	offset = fs.size
 	fs.size += byteSize
 	return
 
 }
 
+// Read returns the data of the record stored at the given position
+// the file is read with positional reads so concurrent readers don't contend with each other
 func (fs *fileStore) Read(offset uint64) ([]byte, error) {
-	fs.mux.Lock()
-	defer fs.mux.Unlock()
+	data, _, err := fs.readRecord(offset)
+	return data, err
+}
 
-	// flush the buffer before attempting to access the file
-	if err := fs.buf.Flush(); err != nil {
-		return nil, err
+// readRecord returns the data of the record stored at the given position and the number of bytes it takes in the
+// store, which differs from the size of the data when the segment is encrypted
+func (fs *fileStore) readRecord(offset uint64) ([]byte, uint64, error) {
+	flushed, err := fs.flushedPast(offset)
+	if err != nil {
+		return nil, 0, err
 	}
-	if _, err := fs.File.Seek(int64(offset), 0); err != nil {
-		return nil, err
+
+	header := make([]byte, recordHeaderByteSize)
+	if _, err := fs.File.ReadAt(header, int64(offset)+fileHeaderSize); err != nil {
+		if err == io.EOF && offset < flushed {
+			return nil, 0, errCorruptRecord
+		}
+		return nil, 0, err
 	}
 
-	var dataSize uint64
-	if err := binary.Read(fs.File, binary.BigEndian, &dataSize); err != nil {
-		return nil, err
+	dataSize := binary.BigEndian.Uint64(header[:recordLengthByteSize])
+	checksum := binary.BigEndian.Uint32(header[recordLengthByteSize:])
+
+	// a damaged length prefix must not make us allocate past the end of the file
+	if dataSize > flushed-offset-recordHeaderByteSize {
+		return nil, 0, errCorruptRecord
 	}
 
 	dataBytes := make([]byte, dataSize)
 
-	if _, err := fs.File.Read(dataBytes); err != nil {
+	if _, err := fs.File.ReadAt(dataBytes, int64(offset+recordHeaderByteSize)+fileHeaderSize); err != nil {
+		if err == io.EOF {
+			return nil, 0, errCorruptRecord
+		}
+		return nil, 0, err
+	}
+
+	if crc32.Checksum(dataBytes, crcTable) != checksum {
+		return nil, 0, errCorruptRecord
+	}
+
+	size := dataSize + recordHeaderByteSize
+	if fs.aead != nil {
+		if dataBytes, err = fs.open(offset, dataBytes); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return dataBytes, size, nil
+}
+
+// seal encrypts the data of the record stored at pos, the position is authenticated so records can't be moved around
+// the sealed data is [nonce][ciphertext]
+func (fs *fileStore) seal(pos uint64, data []byte) ([]byte, error) {
+	nonce := make([]byte, fs.aead.NonceSize(), fs.aead.NonceSize()+len(data)+fs.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
 		return nil, err
 	}
 
-	return dataBytes, nil
+	return fs.aead.Seal(nonce, nonce, data, additionalData(pos)), nil
+}
+
+// open decrypts the data of the record stored at pos, returns errCorruptRecord when it doesn't authenticate
+func (fs *fileStore) open(pos uint64, sealed []byte) ([]byte, error) {
+	n := fs.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errCorruptRecord
+	}
+
+	data, err := fs.aead.Open(nil, sealed[:n], sealed[n:], additionalData(pos))
+	if err != nil {
+		return nil, errCorruptRecord
+	}
+	return data, nil
+}
+
+// flushedPast makes sure the bytes from the given position onwards which are still buffered reach the file and
+// returns the number of bytes in the file
+func (fs *fileStore) flushedPast(offset uint64) (uint64, error) {
+	if flushed := atomic.LoadUint64(&fs.flushed); offset < flushed {
+		return flushed, nil
+	}
+
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	if err := fs.flushLocked(); err != nil {
+		return 0, err
+	}
+	return fs.size, nil
+}
+
+func (fs *fileStore) flushLocked() error {
+	if err := fs.buf.Flush(); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&fs.flushed, fs.size)
+	return nil
 }
 
 func (fs *fileStore) ReadAt(p []byte, off int64) (n int, err error) {
+	if uint64(off)+uint64(len(p)) > atomic.LoadUint64(&fs.flushed) {
+		if err = fs.flush(); err != nil {
+			return
+		}
+	}
+
+	return fs.File.ReadAt(p, off+fileHeaderSize)
+}
+
+// scan walks the records from the beginning of the store and calls fn for each of them
+// it stops at the end of the file or at the first torn or corrupt record (fn may also reject a record by
+// returning errCorruptRecord) and returns the number of bytes holding valid records
+func (fs *fileStore) scan(fn func(pos uint64, data []byte) error) (uint64, error) {
+	var pos uint64
+	for {
+		data, size, err := fs.readRecord(pos)
+		if err == io.EOF || err == errCorruptRecord {
+			return pos, nil
+		} else if err != nil {
+			return pos, err
+		}
+
+		if err = fn(pos, data); err == errCorruptRecord {
+			return pos, nil
+		} else if err != nil {
+			return pos, err
+		}
+		pos += size
+	}
+}
+
+// tornTail tells whether the bytes from pos to the end of the store are a single record whose write didn't complete,
+// pos being where scan found a torn or corrupt record: only such a tail may be cut from the store
+func (fs *fileStore) tornTail(pos uint64) (bool, error) {
+	if err := fs.flush(); err != nil {
+		return false, err
+	}
+	if pos >= fs.size {
+		return false, nil
+	}
+	if fs.size-pos < recordHeaderByteSize {
+		return true, nil
+	}
+	header := make([]byte, recordLengthByteSize)
+	if _, err := fs.File.ReadAt(header, int64(pos)+fileHeaderSize); err != nil {
+		return false, err
+	}
+	// a complete record failing its checksum is damaged, not torn
+	return binary.BigEndian.Uint64(header) > fs.size-pos-recordHeaderByteSize, nil
+}
+
+// truncate discards everything stored after the given byte size
+func (fs *fileStore) truncate(size uint64) error {
 	fs.mux.Lock()
 	defer fs.mux.Unlock()
 
-	if err = fs.buf.Flush(); err != nil {
-		return
+	if err := fs.buf.Flush(); err != nil {
+		return err
+	}
+
+	if err := fs.File.Truncate(int64(size) + fileHeaderSize); err != nil {
+		return err
 	}
+	fs.size = size
+	atomic.StoreUint64(&fs.flushed, size)
+	return nil
+}
 
-	return fs.File.ReadAt(p, off)
+// flush hands the buffered records to the OS without waiting for them to reach the disk
+func (fs *fileStore) flush() error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+
+	return fs.flushLocked()
 }
 
 func (fs *fileStore) Close() (err error) {
@@ -101,6 +281,10 @@ func (fs *fileStore) Close() (err error) {
 		return err
 	}
 
+	if err = fs.File.Sync(); err != nil {
+		return err
+	}
+
 	return fs.File.Close()
 }
 
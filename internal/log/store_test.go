@@ -18,6 +18,8 @@ func TestFileStore_Append(t *testing.T) {
 	assert.NoError(t, err)
 	defer os.Remove(f.Name())
 
+	_, err = setupFileHeader(f, storeFileKind, 0, 0)
+	assert.NoError(t, err)
 	fs, err := newFileStore(f)
 	assert.NoError(t, err)
 
@@ -33,7 +35,7 @@ func do_fileStore_Append(t *testing.T, fs *fileStore) {
 		byteSize, offset, err := fs.Append(data)
 		assert.NoError(t, err)
 
-		fsOffset += uint64(len(data) + recordLengthByteSize)
+		fsOffset += uint64(len(data) + recordHeaderByteSize)
 
 		assert.Equal(t, byteSize+offset, fsOffset)
 	}
@@ -47,7 +49,36 @@ func test_fileStore_Read(t *testing.T, fs *fileStore) {
 		readData, err := fs.Read(offset)
 		assert.NoError(t, err)
 		assert.Equal(t, txt, string(readData))
-		offset += uint64(len(readData) + recordLengthByteSize)
+		offset += uint64(len(readData) + recordHeaderByteSize)
 	}
 
 }
+
+func TestFileStore_ReadCorrupt(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "file.tmp")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	_, err = setupFileHeader(f, storeFileKind, 0, 0)
+	assert.NoError(t, err)
+	fs, err := newFileStore(f)
+	assert.NoError(t, err)
+
+	do_fileStore_Append(t, fs)
+	assert.NoError(t, fs.buf.Flush())
+
+	// flip a bit in the data of the second record
+	pos := fileHeaderSize + int64(len(testWrites[0])+recordHeaderByteSize) + recordHeaderByteSize
+	b := make([]byte, 1)
+	_, err = fs.File.ReadAt(b, pos)
+	assert.NoError(t, err)
+	b[0] ^= 0x01
+	_, err = fs.File.WriteAt(b, pos)
+	assert.NoError(t, err)
+
+	_, err = fs.Read(0)
+	assert.NoError(t, err)
+
+	_, err = fs.Read(uint64(len(testWrites[0]) + recordHeaderByteSize))
+	assert.Equal(t, errCorruptRecord, err)
+}
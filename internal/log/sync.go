@@ -0,0 +1,51 @@
+package log
+
+import "sync"
+
+// groupSyncer lets concurrent appenders share a single sync: the first appender asking for one syncs everything
+// appended so far while the others wait for it to complete
+type groupSyncer struct {
+	mux     sync.Mutex
+	cond    *sync.Cond
+	running bool
+	// sequence of the last record known to be on stable storage
+	synced uint64
+	// syncs everything appended so far and returns the sequence of the last record it covered
+	sync func() (uint64, error)
+}
+
+func newGroupSyncer(syncFn func() (uint64, error)) *groupSyncer {
+	g := &groupSyncer{sync: syncFn}
+	g.cond = sync.NewCond(&g.mux)
+	return g
+}
+
+// waitFor blocks until the record with the given sequence is on stable storage
+func (g *groupSyncer) waitFor(seq uint64) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	for g.synced < seq {
+		if g.running {
+			g.cond.Wait()
+			continue
+		}
+
+		g.running = true
+		g.mux.Unlock()
+		covered, err := g.sync()
+		g.mux.Lock()
+		g.running = false
+
+		if err == nil && covered > g.synced {
+			g.synced = covered
+		}
+		g.cond.Broadcast()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+)
+
+// the time index is a sparse fileIndex whose entries map the relative offset of a record to its timestamp
+// an entry is only written when a record raises the highest timestamp of the segment and at least
+// Config.TimeIndexIntervalBytes were appended since the previous entry, so the timestamps of the entries are
+// strictly increasing and every record before an entry has a timestamp lower or equal to the entry's
+
+// Config.TimeIndexIntervalBytes when it isn't set
+const defaultTimeIndexIntervalBytes = 4096
+
+func (fs *fileSegment) setupTimeIndex(dirName string) (err error) {
+	timeIndexFile, err := os.OpenFile(path.Join(dirName, fmt.Sprintf("%d.timeindex", fs.startOffset)),
+		os.O_RDWR|os.O_CREATE,
+		0644,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err = setupFileHeader(timeIndexFile, timeIndexFileKind, fs.startOffset, 0); err != nil {
+		return err
+	}
+	if fs.timeIndex, err = newFileIndex(timeIndexFile, fs.config.MaxIndexBytes); err != nil {
+		return err
+	}
+
+	// keep the entries which are ordered and point to records still in the segment
+	records := fs.nextOffset - fs.startOffset
+	if max := uint64(len(fs.timeIndex.data)); fs.timeIndex.size > max {
+		fs.timeIndex.size = max
+	}
+	var valid uint64
+	var prevRel uint32
+	var prevTimestamp uint64
+	for ; valid < fs.timeIndex.entries(); valid++ {
+		rel, timestamp := readEntry(fs.timeIndex.data, valid)
+		if timestamp == 0 || uint64(rel) >= records || (valid > 0 && (rel <= prevRel || timestamp <= prevTimestamp)) {
+			break
+		}
+		prevRel, prevTimestamp = rel, timestamp
+	}
+	fs.timeIndex.truncate(valid)
+
+	// records appended after the last entry may still have raised the highest timestamp
+	first := uint64(0)
+	if valid > 0 {
+		fs.maxTimestamp = int64(prevTimestamp)
+		first = uint64(prevRel) + 1
+		_, fs.timeIndexedPos, err = fs.seek(fs.startOffset + uint64(prevRel))
+		if _, ok := err.(api.ErrCorruptRecord); !ok && err != nil {
+			return err
+		}
+	}
+	for offset := fs.startOffset + first; offset < fs.nextOffset; {
+		rec, err := fs.Read(offset)
+		if corrupt, ok := err.(api.ErrCorruptRecord); ok {
+			// reported when the record is read, it mustn't keep the segment from opening
+			offset = corrupt.Offset + 1
+			continue
+		} else if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if rec.Timestamp > fs.maxTimestamp {
+			fs.maxTimestamp = rec.Timestamp
+		}
+		offset = rec.Offset + 1
+	}
+
+	return nil
+}
+
+// indexTime records the timestamp of the record appended at pos if it's due an entry
+func (fs *fileSegment) indexTime(rel uint32, pos uint64, timestamp int64) {
+	if timestamp <= fs.maxTimestamp {
+		return
+	}
+	fs.maxTimestamp = timestamp
+
+	if fs.timeIndex.size > 0 && pos-fs.timeIndexedPos < fs.config.TimeIndexIntervalBytes {
+		return
+	}
+
+	// a full time index only makes the lookups scan further
+	if err := fs.timeIndex.Write(rel, uint64(timestamp)); err == nil {
+		fs.timeIndexedPos = pos
+	}
+}
+
+// offsetForTime returns the offset of the first record of the segment with a timestamp at or after the given one
+// the second return value is false when all the records of the segment are older
+func (fs *fileSegment) offsetForTime(timestamp int64) (uint64, bool, error) {
+	if timestamp > fs.maxTimestamp {
+		return 0, false, nil
+	}
+
+	// the records up to the last entry older than the timestamp are all older too
+	entries := int(fs.timeIndex.entries())
+	i := sort.Search(entries, func(i int) bool {
+		_, ts := readEntry(fs.timeIndex.data, uint64(i))
+		return int64(ts) >= timestamp
+	})
+	first := uint64(0)
+	if i > 0 {
+		rel, _ := readEntry(fs.timeIndex.data, uint64(i-1))
+		first = uint64(rel) + 1
+	}
+
+	for offset := fs.startOffset + first; offset < fs.nextOffset; {
+		rec, err := fs.Read(offset)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, false, err
+		}
+		if rec.Timestamp >= timestamp {
+			return rec.Offset, true, nil
+		}
+		offset = rec.Offset + 1
+	}
+
+	return 0, false, nil
+}
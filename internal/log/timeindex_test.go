@@ -0,0 +1,95 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSegment_OffsetForTime(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_timeindex")
+	defer os.RemoveAll(dir)
+
+	config := Config{MaxStoreBytes: 1024, MaxIndexBytes: 1024, TimeIndexIntervalBytes: 40}
+	segm, err := newFileSegment(dir, 10, config)
+	assert.NoError(t, err)
+
+	// producer timestamps don't have to be ordered
+	timestamps := []int64{100, 200, 150, 300, 400, 350, 500}
+	for _, ts := range timestamps {
+		_, err = segm.Append(&api.LogRecord{Value: []byte("timed"), Timestamp: ts})
+		assert.NoError(t, err)
+	}
+	assert.Less(t, segm.timeIndex.entries(), uint64(len(timestamps)))
+
+	check := func(segm *fileSegment) {
+		for _, c := range []struct {
+			ts     int64
+			offset uint64
+		}{
+			{ts: 0, offset: 10},
+			{ts: 100, offset: 10},
+			{ts: 101, offset: 11},
+			{ts: 160, offset: 11},
+			{ts: 201, offset: 13},
+			{ts: 360, offset: 14},
+			{ts: 500, offset: 16},
+		} {
+			offset, ok, err := segm.offsetForTime(c.ts)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, c.offset, offset, "timestamp %d", c.ts)
+		}
+
+		_, ok, err := segm.offsetForTime(501)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	check(segm)
+	assert.NoError(t, segm.Close())
+
+	segm, err = newFileSegment(dir, 10, config)
+	assert.NoError(t, err)
+	defer segm.Remove()
+	assert.Equal(t, int64(500), segm.maxTimestamp)
+	check(segm)
+}
+
+func TestLog_OffsetForTime(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "log-timeindex")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxIndexBytes: entryWidth * 2})
+	assert.NoError(t, err)
+	defer log.Remove()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte("timed"), Timestamp: start.Add(time.Duration(i) * time.Minute).UnixNano()})
+		assert.NoError(t, err)
+	}
+
+	// the log sets the append time on records without a timestamp
+	before := time.Now()
+	_, err = log.Append(&api.LogRecord{Value: []byte("now")})
+	assert.NoError(t, err)
+	rec, err := log.Read(5)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, rec.Timestamp, before.UnixNano())
+
+	offset, err := log.OffsetForTime(start.Add(150 * time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), offset)
+
+	offset, err = log.OffsetForTime(start.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), offset)
+
+	offset, err = log.OffsetForTime(start.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), offset)
+}
@@ -0,0 +1,339 @@
+package log
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"time"
+)
+
+// SegmentReport describes a segment found on disk by Verify
+type SegmentReport struct {
+	BaseOffset uint64
+	StoreFile  string
+	IndexFile  string
+	// number of valid records held by the store
+	Records uint64
+	// range of offsets held by the valid records, only meaningful when Records > 0
+	FirstOffset uint64
+	LastOffset  uint64
+	StoreBytes  uint64
+	IndexBytes  uint64
+	// everything that doesn't add up between the store and the index
+	Errors []string
+	// whether the index file was regenerated from the store
+	Rebuilt bool
+	// the errors of the index which was regenerated, they are no longer in Errors
+	Repaired []string
+	// whether the records of the segment are encrypted
+	Encrypted bool
+}
+
+// Verify walks the segments of the log stored in dir, validates the framing of every store against its index and,
+// when rebuild is set, regenerates the indexes which are missing or inconsistent
+// the indexes must hold an entry at least every config.IndexIntervalBytes, the largest interval the log was written
+// with; config.Keys unwraps the data keys of encrypted segments, it may be nil when the log isn't encrypted
+// the log must not be open while it is verified
+func Verify(dir string, rebuild bool, config Config) ([]SegmentReport, error) {
+	baseOffsets, err := segmentBaseOffsets(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]SegmentReport, 0, len(baseOffsets))
+	for i, baseOffset := range baseOffsets {
+		report, err := verifySegment(dir, baseOffset, rebuild, config)
+		if err != nil {
+			return nil, err
+		}
+
+		// compaction may leave gaps between segments but they never overlap
+		if i > 0 {
+			prev := reports[i-1]
+			if prev.Records > 0 && prev.LastOffset >= baseOffset {
+				report.Errors = append(report.Errors, fmt.Sprintf(
+					"segment starts at offset %d, previous segment ends at %d", baseOffset, prev.LastOffset+1))
+			}
+		}
+		reports = append(reports, *report)
+	}
+
+	return reports, nil
+}
+
+func verifySegment(dir string, baseOffset uint64, rebuild bool, config Config) (*SegmentReport, error) {
+	report := &SegmentReport{
+		BaseOffset: baseOffset,
+		StoreFile:  path.Join(dir, fmt.Sprintf("%d.store", baseOffset)),
+		IndexFile:  path.Join(dir, fmt.Sprintf("%d.index", baseOffset)),
+	}
+
+	// the records of an encrypted segment can't be checked without its data key
+	aead, keyFile, err := loadDataKey(dir, baseOffset, config.Keys)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("key: %s", err))
+		return report, nil
+	}
+	report.Encrypted = keyFile != ""
+
+	// the records of a segment written by an older version can't be located until it is migrated
+	if err = verifyStoreHeader(report); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("store: %s", err))
+		return report, nil
+	}
+
+	offsets, positions, err := verifyStore(report, aead)
+	if err != nil {
+		return nil, err
+	}
+
+	storeErrors := len(report.Errors)
+	indexValid, err := verifyIndex(report, offsets, positions, config.IndexIntervalBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if !indexValid && rebuild {
+		entries, err := rebuildIndex(report.IndexFile, baseOffset, offsets, positions, config.IndexIntervalBytes)
+		if err != nil {
+			return nil, err
+		}
+		report.Rebuilt = true
+		report.IndexBytes = entries * entryWidth
+		// the errors of the store remain, the rebuilt index only covers the records before them
+		report.Repaired = report.Errors[storeErrors:]
+		report.Errors = report.Errors[:storeErrors:storeErrors]
+	}
+
+	return report, nil
+}
+
+// verifyStoreHeader checks the header of the store describes the segment and agrees with its key file
+// a missing store is reported by verifyStore
+func verifyStoreHeader(report *SegmentReport) error {
+	f, err := os.Open(report.StoreFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h, err := readFileHeader(f, storeFileKind, report.BaseOffset)
+	if err != nil {
+		return err
+	}
+	if encrypted := h.Flags&fileFlagEncrypted != 0; encrypted && !report.Encrypted {
+		return fmt.Errorf("segment is encrypted and its key file is missing")
+	} else if !encrypted && report.Encrypted {
+		return fmt.Errorf("segment is stored in plaintext and has a key file")
+	}
+	return nil
+}
+
+// verifyStore reads the entries of the store and returns the offsets of their first records relative to the segment
+// and their positions
+func verifyStore(report *SegmentReport, aead cipher.AEAD) ([]uint32, []uint64, error) {
+	f, err := os.Open(report.StoreFile)
+	if os.IsNotExist(err) {
+		report.Errors = append(report.Errors, "store: file missing")
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	store, err := newFileStore(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	store.aead = aead
+	report.StoreBytes = store.size
+
+	offsets := make([]uint32, 0)
+	positions := make([]uint64, 0)
+	validSize, err := store.scan(func(pos uint64, data []byte) error {
+		h, err := readEntryHeader(data)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("store: record at byte %d can't be decoded", pos))
+			return errCorruptRecord
+		}
+		// offsets grow from one record to the next, compaction may leave gaps between them
+		expected := report.BaseOffset
+		if report.Records > 0 {
+			expected = report.LastOffset + 1
+		}
+		if h.firstOffset < expected || h.lastOffset-report.BaseOffset > math.MaxUint32 {
+			report.Errors = append(report.Errors, fmt.Sprintf(
+				"store: record at byte %d holds offset %d, want %d or more", pos, h.firstOffset, expected))
+			return errCorruptRecord
+		}
+		if report.Records == 0 {
+			report.FirstOffset = h.firstOffset
+		}
+		report.LastOffset = h.lastOffset
+		report.Records += h.records
+		offsets = append(offsets, uint32(h.firstOffset-report.BaseOffset))
+		positions = append(positions, pos)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if validSize < store.size {
+		report.Errors = append(report.Errors, fmt.Sprintf(
+			"store: %d unreadable bytes after byte %d", store.size-validSize, validSize))
+	}
+
+	return offsets, positions, nil
+}
+
+// verifyIndex checks every index entry against the offsets and positions of the records in the store
+// the index may be sparse: it must point to the first record and its entries to records of the store, in order, and
+// no record may be stored intervalBytes or more after the entry before it without having its own
+func verifyIndex(report *SegmentReport, offsets []uint32, positions []uint64, intervalBytes uint64) (bool, error) {
+	data, err := ioutil.ReadFile(report.IndexFile)
+	if os.IsNotExist(err) {
+		report.Errors = append(report.Errors, "index: file missing")
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if _, err = checkFileHeader(report.IndexFile, data, indexFileKind, report.BaseOffset); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("index: %s", err))
+		return false, nil
+	}
+	data = data[fileHeaderSize:]
+	report.IndexBytes = uint64(len(data))
+
+	valid := true
+	written := writtenEntries(data)
+	if written == 0 && len(positions) > 0 {
+		report.Errors = append(report.Errors, fmt.Sprintf("index: no entries, store has %d records", len(positions)))
+		valid = false
+	}
+
+	// the records pointed to by an entry
+	indexed := make([]bool, len(positions))
+	record := 0
+	for i := uint64(0); i < written; i++ {
+		off, pos := readEntry(data, i)
+		for record < len(positions) && positions[record] < pos {
+			record++
+		}
+		if i == 0 && record != 0 && len(positions) > 0 {
+			report.Errors = append(report.Errors, fmt.Sprintf(
+				"index: first entry points to byte %d, record %d is at byte %d",
+				pos, report.BaseOffset+uint64(offsets[0]), positions[0]))
+			valid = false
+		} else if record == len(positions) || positions[record] != pos || offsets[record] != off {
+			report.Errors = append(report.Errors, fmt.Sprintf(
+				"index: entry %d points offset %d to byte %d, no such record in the store",
+				i, report.BaseOffset+uint64(off), pos))
+			valid = false
+		} else {
+			indexed[record] = true
+		}
+	}
+
+	// reads scan the store forward from the entry before the offset, an index missing entries makes them scan further
+	// than the log promises; only the first record missing its entry is reported
+	if valid {
+		var indexedPos uint64
+		for i, pos := range positions {
+			if indexed[i] {
+				indexedPos = pos
+			} else if pos-indexedPos >= intervalBytes {
+				report.Errors = append(report.Errors, fmt.Sprintf(
+					"index: record %d at byte %d has no entry, the previous one points to byte %d",
+					report.BaseOffset+uint64(offsets[i]), pos, indexedPos))
+				valid = false
+				break
+			}
+		}
+	}
+
+	if unwritten := uint64(len(data)) - written*entryWidth; unwritten > 0 {
+		report.Errors = append(report.Errors, fmt.Sprintf("index: %d unwritten bytes", unwritten))
+		valid = false
+	}
+
+	return valid, nil
+}
+
+// rebuildIndex replaces the index file with one holding the entries the log writes for the given records when it
+// indexes them every intervalBytes, it returns the number of entries
+func rebuildIndex(name string, baseOffset uint64, offsets []uint32, positions []uint64, intervalBytes uint64) (uint64, error) {
+	header := fileHeader{
+		Version:    formatVersion,
+		Kind:       indexFileKind,
+		BaseOffset: baseOffset,
+		CreatedAt:  time.Now(),
+	}
+	data := header.encode()
+	var entries, indexedPos uint64
+	for i, pos := range positions {
+		if entries > 0 && pos-indexedPos < intervalBytes {
+			continue
+		}
+		data = appendIndexEntry(data, offsets[i], pos)
+		indexedPos = pos
+		entries++
+	}
+
+	return entries, replaceFile(name, data)
+}
+
+// rebuildTimeIndex replaces the time index file with one holding the entries the log writes for the given records
+// when it indexes them every intervalBytes, it returns the number of entries
+func rebuildTimeIndex(name string, baseOffset uint64, offsets []uint32, positions []uint64, timestamps []int64,
+	intervalBytes uint64) (uint64, error) {
+	header := fileHeader{
+		Version:    formatVersion,
+		Kind:       timeIndexFileKind,
+		BaseOffset: baseOffset,
+		CreatedAt:  time.Now(),
+	}
+	data := header.encode()
+	var entries, indexedPos uint64
+	var maxTimestamp int64
+	for i, pos := range positions {
+		if timestamps[i] <= maxTimestamp {
+			continue
+		}
+		maxTimestamp = timestamps[i]
+		if entries > 0 && pos-indexedPos < intervalBytes {
+			continue
+		}
+		data = appendIndexEntry(data, offsets[i], uint64(timestamps[i]))
+		indexedPos = pos
+		entries++
+	}
+
+	return entries, replaceFile(name, data)
+}
+
+func appendIndexEntry(data []byte, off uint32, value uint64) []byte {
+	entry := make([]byte, entryWidth)
+	binary.BigEndian.PutUint32(entry[:offsetWidth], off)
+	binary.BigEndian.PutUint64(entry[offsetWidth:], value)
+	return append(data, entry...)
+}
+
+// replaceFile writes data next to the named file and renames it over the file once synced, a crash leaves either
+// the old file or the complete new one
+func replaceFile(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		return err
+	}
+	return syncDir(path.Dir(name))
+}
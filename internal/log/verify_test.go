@@ -0,0 +1,114 @@
+package log
+
+import (
+	"EchoLog/api/v1"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_verify")
+	defer os.RemoveAll(dir)
+
+	config := Config{MaxIndexBytes: entryWidth * 3, MaxStoreBytes: 1024}
+	log, err := NewLog(dir, config)
+	assert.NoError(t, err)
+	for i := 0; i < 7; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record-%d", i))})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, log.Close())
+
+	reports, err := Verify(dir, false, config)
+	assert.NoError(t, err)
+	assert.Len(t, reports, 3)
+	for _, r := range reports {
+		assert.Empty(t, r.Errors)
+	}
+	assert.Equal(t, uint64(3), reports[1].Records)
+	assert.Equal(t, uint64(3), reports[1].FirstOffset)
+	assert.Equal(t, uint64(5), reports[1].LastOffset)
+	assert.Equal(t, uint64(1), reports[2].Records)
+
+	// lose the first index and point an entry of the second one to the middle of a record
+	assert.NoError(t, os.Remove(path.Join(dir, "0.index")))
+	data, err := ioutil.ReadFile(path.Join(dir, "3.index"))
+	assert.NoError(t, err)
+	binary.BigEndian.PutUint64(data[fileHeaderSize+entryWidth+offsetWidth:fileHeaderSize+2*entryWidth], 5)
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "3.index"), data, 0644))
+
+	reports, err = Verify(dir, false, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"index: file missing"}, reports[0].Errors)
+	assert.Equal(t, []string{"index: entry 1 points offset 4 to byte 5, no such record in the store"}, reports[1].Errors)
+	assert.False(t, reports[0].Rebuilt)
+
+	reports, err = Verify(dir, true, config)
+	assert.NoError(t, err)
+	assert.True(t, reports[0].Rebuilt)
+	assert.True(t, reports[1].Rebuilt)
+	assert.False(t, reports[2].Rebuilt)
+	assert.Equal(t, []string{"index: file missing"}, reports[0].Repaired)
+	assert.Empty(t, reports[0].Errors)
+
+	reports, err = Verify(dir, false, config)
+	assert.NoError(t, err)
+	for _, r := range reports {
+		assert.Empty(t, r.Errors)
+	}
+
+	log, err = NewLog(dir, config)
+	assert.NoError(t, err)
+	defer log.Remove()
+	assert.Empty(t, log.Recovered())
+	for i := 0; i < 7; i++ {
+		rec, err := log.Read(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(fmt.Sprintf("record-%d", i)), rec.Value)
+	}
+}
+
+func TestVerify_SparseIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir(os.TempDir(), "temp_verify")
+	defer os.RemoveAll(dir)
+
+	log, err := NewLog(dir, Config{MaxStoreBytes: 4096, IndexIntervalBytes: 64})
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = log.Append(&api.LogRecord{Value: []byte(fmt.Sprintf("record-%d", i))})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, log.Close())
+
+	reports, err := Verify(dir, false, Config{IndexIntervalBytes: 64})
+	assert.NoError(t, err)
+	assert.Len(t, reports, 1)
+	assert.Empty(t, reports[0].Errors)
+	assert.Equal(t, uint64(10), reports[0].Records)
+	assert.Less(t, reports[0].IndexBytes, 10*entryWidth)
+
+	// a log indexing every record needs more entries
+	reports, err = Verify(dir, false, Config{})
+	assert.NoError(t, err)
+	assert.Len(t, reports[0].Errors, 1)
+	assert.Contains(t, reports[0].Errors[0], "has no entry")
+
+	// an index pointing to the first record only isn't dense enough either
+	name := path.Join(dir, "0.index")
+	data, err := ioutil.ReadFile(name)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(name, data[:fileHeaderSize+entryWidth], 0644))
+	reports, err = Verify(dir, true, Config{IndexIntervalBytes: 64})
+	assert.NoError(t, err)
+	assert.True(t, reports[0].Rebuilt)
+	assert.Len(t, reports[0].Repaired, 1)
+
+	rebuilt, err := ioutil.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, data[fileHeaderSize:], rebuilt[fileHeaderSize:])
+}
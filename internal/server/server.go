@@ -3,7 +3,11 @@ package server
 import (
 	"EchoLog/api/v1"
 	"EchoLog/internal/auth"
+	"EchoLog/internal/filter"
+	"EchoLog/internal/group"
+	"EchoLog/internal/topic"
 	"context"
+	"github.com/golang/protobuf/proto"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	"google.golang.org/grpc"
@@ -11,6 +15,7 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"time"
 )
 
 type subjectContextKey struct {
@@ -38,18 +43,34 @@ var authenticateMiddlewareFunc grpc_auth.AuthFunc = func(ctx context.Context) (c
 
 type CommitLog interface {
 	Append(*api.LogRecord) (uint64, error)
+	AppendBatch([]*api.LogRecord) (uint64, uint64, error)
+	AppendCompressed([]*api.LogRecord, api.Compression) (uint64, uint64, error)
+	AppendIdempotent(uint64, uint64, []*api.LogRecord, api.Compression) (uint64, uint64, error)
+	AppendExpected([]*api.LogRecord, api.Compression, uint64) (uint64, uint64, error)
+	RegisterProducer() (uint64, error)
 	Read(uint64) (*api.LogRecord, error)
+	ReadBatch(uint64, uint64) ([]*api.LogRecord, error)
+	OffsetForTime(time.Time) (uint64, error)
+	Watermarks() (uint64, uint64)
+	WaitFor(context.Context, uint64) error
 }
 
 type Config struct {
-	CommitLog  CommitLog
+	Topics     *topic.Manager
+	Groups     *group.Coordinator
 	Authorizer *auth.Authorizer
 }
 
+// the casbin object of the produce and consume actions is the name of the topic, the one of the actions on the
+// topics themselves is the name of the topic they create or delete
 const (
 	objectWildcard = "*"
 	produceAction  = "produce"
 	consumeAction  = "consume"
+	createAction   = "create"
+	deleteAction   = "delete"
+	alterAction    = "alter"
+	listAction     = "list"
 )
 
 type grpcServer struct {
@@ -85,34 +106,393 @@ func NewGrpcServer(config *Config, opts ...grpc.ServerOption) (*grpc.Server, err
 }
 
 func (s *grpcServer) Produce(ctx context.Context, req *api.ProduceRequest) (*api.ProduceResponse, error) {
+	t, err := s.topic(ctx, req.Topic, produceAction)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCompression(req.Compression); err != nil {
+		return nil, err
+	}
+	recs := []*api.LogRecord{req.Record}
+	partition, clog, err := partitionFor(t, req.Partitioner, req.Partition, req.ProducerId, recs)
+	if err != nil {
+		return nil, err
+	}
+	offset, _, err := s.append(clog, req.ProducerId, req.Sequence, req.ExpectedOffset, recs, req.Compression)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ProduceResponse{Offset: offset, Partition: partition}, nil
+}
+
+func (s *grpcServer) ProduceBatch(ctx context.Context, req *api.ProduceBatchRequest) (*api.ProduceBatchResponse, error) {
+	t, err := s.topic(ctx, req.Topic, produceAction)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Records) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty batch")
+	}
+	if err := checkCompression(req.Compression); err != nil {
+		return nil, err
+	}
+	partition, clog, err := partitionFor(t, req.Partitioner, req.Partition, req.ProducerId, req.Records)
+	if err != nil {
+		return nil, err
+	}
+	first, last, err := s.append(clog, req.ProducerId, req.Sequence, req.ExpectedOffset, req.Records, req.Compression)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ProduceBatchResponse{FirstOffset: first, LastOffset: last, Partition: partition}, nil
+}
+
+// partitionFor picks the partition of the topic the records go to with the partitioner selected by the request
+func partitionFor(t *topic.Topic, partitioner api.Partitioner, partition uint32, producerID uint64,
+	recs []*api.LogRecord) (uint32, CommitLog, error) {
+	p, err := t.Partitioner(partitioner, partition, producerID)
+	if err != nil {
+		return 0, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	partition, clog, err := t.PartitionFor(p, recs)
+	if err != nil {
+		return 0, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return partition, clog, nil
+}
+
+// append deduplicates the records by their sequence when they come from an idempotent producer, or only appends them
+// at the expected offset when there is one
+func (s *grpcServer) append(clog CommitLog, producerID uint64, sequence uint64, expected *api.ExpectedOffset, recs []*api.LogRecord,
+	compression api.Compression) (uint64, uint64, error) {
+	if producerID == 0 {
+		if expected != nil {
+			return clog.AppendExpected(recs, compression, expected.NextOffset)
+		}
+		return clog.AppendCompressed(recs, compression)
+	}
+	if expected != nil {
+		return 0, 0, status.Error(codes.InvalidArgument, "an expected offset can't be combined with a producer id")
+	}
+	if sequence == 0 {
+		return 0, 0, status.Error(codes.InvalidArgument, "sequences start at 1")
+	}
+	return clog.AppendIdempotent(producerID, sequence, recs, compression)
+}
+
+func (s *grpcServer) RegisterProducer(ctx context.Context, req *api.RegisterProducerRequest) (
+	*api.RegisterProducerResponse, error) {
+	t, err := s.topic(ctx, req.Topic, produceAction)
+	if err != nil {
+		return nil, err
+	}
+	id, err := t.RegisterProducer()
+	if err != nil {
+		return nil, err
+	}
+	return &api.RegisterProducerResponse{ProducerId: id}, nil
+}
+
+// topic authorizes the action on the topic and returns it, the default topic when name is empty
+func (s *grpcServer) topic(ctx context.Context, name string, action string) (*topic.Topic, error) {
+	if name == "" {
+		name = topic.DefaultTopic
+	}
 	if err := s.Authorizer.Authorize(
 		getSubjectFromContext(ctx),
-		objectWildcard,
-		produceAction,
+		name,
+		action,
 	); err != nil {
 		return nil, err
 	}
-	offset, err := s.CommitLog.Append(req.Record)
+	return s.Topics.Topic(name)
+}
+
+// commitLog authorizes the action on the topic and returns the log of the partition
+func (s *grpcServer) commitLog(ctx context.Context, name string, partition uint32, action string) (CommitLog, error) {
+	t, err := s.topic(ctx, name, action)
 	if err != nil {
 		return nil, err
 	}
-	return &api.ProduceResponse{Offset: offset}, nil
+	clog, err := t.Partition(partition)
+	if err != nil {
+		return nil, err
+	}
+	return clog, nil
 }
 
-func (s *grpcServer) Consume(ctx context.Context, req *api.ConsumeRequest) (
-	*api.ConsumeResponse, error) {
+func (s *grpcServer) CreateTopic(ctx context.Context, req *api.CreateTopicRequest) (*api.CreateTopicResponse, error) {
+	if err := s.Authorizer.Authorize(
+		getSubjectFromContext(ctx),
+		req.Name,
+		createAction,
+	); err != nil {
+		return nil, err
+	}
+	if err := topic.CheckName(req.Name); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.Topics.Create(req.Name, req.Partitions); err != nil {
+		return nil, err
+	}
+	return &api.CreateTopicResponse{}, nil
+}
+
+func (s *grpcServer) DeleteTopic(ctx context.Context, req *api.DeleteTopicRequest) (*api.DeleteTopicResponse, error) {
+	if err := s.Authorizer.Authorize(
+		getSubjectFromContext(ctx),
+		req.Name,
+		deleteAction,
+	); err != nil {
+		return nil, err
+	}
+	if req.Name == topic.DefaultTopic {
+		return nil, status.Error(codes.FailedPrecondition, "the default topic can't be deleted")
+	}
+	if err := s.Topics.Delete(req.Name); err != nil {
+		return nil, err
+	}
+	return &api.DeleteTopicResponse{}, nil
+}
+
+func (s *grpcServer) ListTopics(ctx context.Context, req *api.ListTopicsRequest) (*api.ListTopicsResponse, error) {
 	if err := s.Authorizer.Authorize(
 		getSubjectFromContext(ctx),
 		objectWildcard,
-		consumeAction,
+		listAction,
 	); err != nil {
 		return nil, err
 	}
-	record, err := s.CommitLog.Read(req.Offset)
+	res := &api.ListTopicsResponse{}
+	for _, name := range s.Topics.List() {
+		// deleted since it was listed
+		t, err := s.Topics.Topic(name)
+		if err != nil {
+			continue
+		}
+		res.Topics = append(res.Topics, &api.Topic{Name: name, Partitions: t.Partitions()})
+	}
+	return res, nil
+}
+
+func (s *grpcServer) AddPartitions(ctx context.Context, req *api.AddPartitionsRequest) (*api.AddPartitionsResponse, error) {
+	if err := s.Authorizer.Authorize(
+		getSubjectFromContext(ctx),
+		req.Name,
+		alterAction,
+	); err != nil {
+		return nil, err
+	}
+	t, err := s.Topics.Topic(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if req.Partitions < t.Partitions() {
+		return nil, status.Errorf(codes.InvalidArgument, "topic %s has %d partitions, their number can't be decreased",
+			req.Name, t.Partitions())
+	}
+	if err = t.AddPartitions(req.Partitions); err != nil {
+		return nil, err
+	}
+	return &api.AddPartitionsResponse{}, nil
+}
+
+// the consumer group actions are authorized as consuming the topic of the group
+
+func (s *grpcServer) JoinGroup(ctx context.Context, req *api.JoinGroupRequest) (*api.JoinGroupResponse, error) {
+	t, err := s.topic(ctx, req.Topic, consumeAction)
+	if err != nil {
+		return nil, err
+	}
+	if req.Group == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty group")
+	}
+	memberID, generation, partitions, err := s.Groups.Join(req.Group, t.Name(), req.MemberId)
+	if err != nil {
+		return nil, err
+	}
+	return &api.JoinGroupResponse{MemberId: memberID, Generation: generation, Partitions: partitions}, nil
+}
+
+func (s *grpcServer) Heartbeat(ctx context.Context, req *api.HeartbeatRequest) (*api.HeartbeatResponse, error) {
+	if err := s.authorizeGroup(ctx, req.Group, req.MemberId); err != nil {
+		return nil, err
+	}
+	generation, partitions, err := s.Groups.Heartbeat(req.Group, req.MemberId)
+	if err != nil {
+		return nil, err
+	}
+	return &api.HeartbeatResponse{Generation: generation, Partitions: partitions}, nil
+}
+
+func (s *grpcServer) LeaveGroup(ctx context.Context, req *api.LeaveGroupRequest) (*api.LeaveGroupResponse, error) {
+	if err := s.authorizeGroup(ctx, req.Group, req.MemberId); err != nil {
+		return nil, err
+	}
+	if err := s.Groups.Leave(req.Group, req.MemberId); err != nil {
+		return nil, err
+	}
+	return &api.LeaveGroupResponse{}, nil
+}
+
+// authorizeGroup authorizes consuming the topic of the group, the member of a group without members is unknown
+func (s *grpcServer) authorizeGroup(ctx context.Context, groupName string, memberID string) error {
+	name, ok := s.Groups.Topic(groupName)
+	if !ok {
+		return api.ErrUnknownMember{Group: groupName, MemberID: memberID}
+	}
+	return s.Authorizer.Authorize(
+		getSubjectFromContext(ctx),
+		name,
+		consumeAction,
+	)
+}
+
+func (s *grpcServer) CommitOffset(ctx context.Context, req *api.CommitOffsetRequest) (*api.CommitOffsetResponse, error) {
+	t, err := s.topic(ctx, req.Topic, consumeAction)
+	if err != nil {
+		return nil, err
+	}
+	if req.Group == "" {
+		return nil, status.Error(codes.InvalidArgument, "empty group")
+	}
+	if err = s.Groups.Commit(req.Group, t.Name(), req.Partition, req.Offset, req.MemberId, req.Generation); err != nil {
+		return nil, err
+	}
+	return &api.CommitOffsetResponse{}, nil
+}
+
+func (s *grpcServer) FetchOffset(ctx context.Context, req *api.FetchOffsetRequest) (*api.FetchOffsetResponse, error) {
+	t, err := s.topic(ctx, req.Topic, consumeAction)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := s.Groups.Fetch(req.Group, t.Name(), req.Partition)
+	if err != nil {
+		return nil, err
+	}
+	return &api.FetchOffsetResponse{Offset: offset}, nil
+}
+
+func checkCompression(compression api.Compression) error {
+	if _, ok := api.Compression_name[int32(compression)]; !ok {
+		return status.Errorf(codes.InvalidArgument, "unknown compression: %d", compression)
+	}
+	return nil
+}
+
+func (s *grpcServer) Consume(ctx context.Context, req *api.ConsumeRequest) (
+	*api.ConsumeResponse, error) {
+	clog, err := s.commitLog(ctx, req.Topic, req.Partition, consumeAction)
+	if err != nil {
+		return nil, err
+	}
+	if req.Filter != "" {
+		return nil, status.Error(codes.InvalidArgument, "filters only apply to ConsumeStream")
+	}
+	offset, err := startOffset(clog, req)
 	if err != nil {
 		return nil, err
 	}
-	return &api.ConsumeResponse{Record: record}, nil
+	record, err := clog.Read(offset)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ConsumeResponse{Record: record, NextOffset: record.Offset + 1}, nil
+}
+
+// startOffset resolves the start position of the request against the log
+func startOffset(clog CommitLog, req *api.ConsumeRequest) (uint64, error) {
+	low, high := clog.Watermarks()
+	switch req.StartPosition {
+	case api.StartPosition_START_OFFSET:
+		if req.StartTimestamp != 0 {
+			return clog.OffsetForTime(time.Unix(0, req.StartTimestamp))
+		}
+		return req.Offset, nil
+	case api.StartPosition_START_EARLIEST:
+		return low, nil
+	case api.StartPosition_START_LATEST:
+		return high, nil
+	case api.StartPosition_START_LATEST_MINUS:
+		if high-low < req.LatestMinus {
+			return low, nil
+		}
+		return high - req.LatestMinus, nil
+	case api.StartPosition_START_TIMESTAMP:
+		return clog.OffsetForTime(time.Unix(0, req.StartTimestamp))
+	}
+	return 0, status.Errorf(codes.InvalidArgument, "unknown start position: %d", req.StartPosition)
+}
+
+func (s *grpcServer) GetOffsets(ctx context.Context, req *api.GetOffsetsRequest) (*api.GetOffsetsResponse, error) {
+	clog, err := s.commitLog(ctx, req.Topic, req.Partition, consumeAction)
+	if err != nil {
+		return nil, err
+	}
+	low, high := clog.Watermarks()
+	return &api.GetOffsetsResponse{LowWatermark: low, HighWatermark: high}, nil
+}
+
+const (
+	// the max bytes of a fetch which doesn't set it
+	defaultFetchMaxBytes = 1 << 20
+	// the max bytes of a fetch asking for more, the response is buffered until it is sent and must fit in the 4 MiB
+	// gRPC limits the messages clients receive to by default
+	maxFetchMaxBytes = 4<<20 - 1<<10
+)
+
+func (s *grpcServer) Fetch(ctx context.Context, req *api.FetchRequest) (*api.FetchResponse, error) {
+	clog, err := s.commitLog(ctx, req.Topic, req.Partition, consumeAction)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes := req.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = defaultFetchMaxBytes
+	} else if maxBytes > maxFetchMaxBytes {
+		maxBytes = maxFetchMaxBytes
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Duration(req.MaxWaitMs)*time.Millisecond)
+	defer cancel()
+
+	res := &api.FetchResponse{NextOffset: req.Offset}
+	// bytes the records take in the response
+	var size uint64
+	for {
+		// every wake-up reads on from the records fetched so far
+		recs, err := clog.ReadBatch(res.NextOffset, maxBytes-size)
+		if err != nil {
+			// the records already fetched were read before the offset was removed from the log
+			if len(res.Records) > 0 {
+				return res, nil
+			}
+			return nil, err
+		}
+		full := false
+		for _, rec := range recs {
+			n := proto.Size(rec)
+			recSize := uint64(1 + proto.SizeVarint(uint64(n)) + n)
+			if len(res.Records) > 0 && size+recSize > maxBytes {
+				full = true
+				break
+			}
+			res.Records = append(res.Records, rec)
+			res.NextOffset = rec.Offset + 1
+			size += recSize
+		}
+		if full || size >= maxBytes || size >= req.MinBytes {
+			return res, nil
+		}
+
+		if err = clog.WaitFor(waitCtx, res.NextOffset); err != nil {
+			if ctx.Err() == nil && waitCtx.Err() != nil {
+				return res, nil
+			}
+			return nil, err
+		}
+	}
 }
 
 func (s *grpcServer) ProduceStream(
@@ -132,28 +512,72 @@ func (s *grpcServer) ProduceStream(
 		}
 	}
 }
+
+// number of records a filtered stream skips between two reports of its progress, it also reports it once it caught up
+// with the log
+const filterProgressInterval = 1000
+
 func (s *grpcServer) ConsumeStream(
 	req *api.ConsumeRequest,
 	stream api.Log_ConsumeStreamServer,
 ) error {
+	ctx := stream.Context()
+	clog, err := s.commitLog(ctx, req.Topic, req.Partition, consumeAction)
+	if err != nil {
+		return err
+	}
+
+	var f *filter.Filter
+	if req.Filter != "" {
+		if f, err = filter.Parse(req.Filter); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		req.Filter = ""
+	}
+
+	// a stream started from a timestamp or a symbolic position carries on from the offset it resolved to
+	offset, err := startOffset(clog, req)
+	if err != nil {
+		return err
+	}
+	req.Offset = offset
+	req.StartPosition = api.StartPosition_START_OFFSET
+	req.StartTimestamp = 0
 
+	var skipped uint64
 	for {
-		select {
-		case <-stream.Context().Done():
-			return nil
-		default:
-			res, err := s.Consume(stream.Context(), req)
-			switch err.(type) {
-			case nil:
-			case api.ErrOffsetOutOfRange:
-				continue
-			default:
-				return err
+		res, err := s.Consume(ctx, req)
+		switch err.(type) {
+		case nil:
+		case api.ErrOffsetOutOfRange:
+			if skipped > 0 {
+				if err = stream.Send(&api.ConsumeResponse{NextOffset: req.Offset}); err != nil {
+					return err
+				}
+				skipped = 0
 			}
-			if err = stream.Send(res); err != nil {
+			// sleep until the log reaches the offset instead of polling it
+			if err = clog.WaitFor(ctx, req.Offset); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
 				return err
 			}
-			req.Offset++
+			continue
+		default:
+			return err
+		}
+		req.Offset = res.NextOffset
+
+		if f != nil && !f.Match(res.Record) {
+			if skipped++; skipped < filterProgressInterval {
+				continue
+			}
+			res = &api.ConsumeResponse{NextOffset: req.Offset}
+		}
+		if err = stream.Send(res); err != nil {
+			return err
 		}
+		skipped = 0
 	}
 }
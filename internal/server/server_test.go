@@ -4,15 +4,22 @@ import (
 	"EchoLog/api/v1"
 	"EchoLog/internal/auth"
 	"EchoLog/internal/config"
+	"EchoLog/internal/group"
 	"EchoLog/internal/log"
+	"EchoLog/internal/topic"
 	"context"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 	"io/ioutil"
 	"net"
 	"os"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -29,6 +36,18 @@ func TestServer(t *testing.T) {
 		"produce/consume stream succeeds":                     testProduceConsumeStream,
 		"consume past log boundary fails":                     testConsumePastBoundary,
 		"unauthorized fails":                                  testUnauthorized,
+		"consume stream from a timestamp succeeds":            testConsumeStreamFromTimestamp,
+		"produce a batch succeeds":                            testProduceBatch,
+		"consume stream waits for new records":                testConsumeStreamWaits,
+		"produce compressed records succeeds":                 testProduceCompressed,
+		"idempotent producer retries are deduplicated":        testProduceIdempotent,
+		"produce at an expected offset succeeds or fails":     testProduceExpected,
+		"topics are created, listed and deleted":              testTopics,
+		"partitioned topics spread the records":               testPartitions,
+		"consumer groups share partitions and commit offsets": testGroups,
+		"fetch batches records and waits for them":            testFetch,
+		"consume from symbolic start positions":               testStartPositions,
+		"consume stream filters records":                      testConsumeStreamFiltered,
 	} {
 		t.Run(scenario, func(t *testing.T) {
 			rootClient,
@@ -95,14 +114,16 @@ func setupTest(t *testing.T, fn func(*Config)) (
 
 	dir, err := ioutil.TempDir("", "server-test")
 	require.NoError(t, err)
-	defer os.RemoveAll(dir)
 
-	clog, err := log.NewLog(dir, log.Config{})
+	topics, err := topic.NewManager(path.Join(dir, "topics"), log.Config{})
+	require.NoError(t, err)
+	groups, err := group.NewCoordinator(path.Join(dir, "groups"), topics, group.Config{})
 	require.NoError(t, err)
 
 	authorizer := auth.New(config.ACLModelFile, config.ACLPolicyFile)
 	cfg = &Config{
-		CommitLog:  clog,
+		Topics:     topics,
+		Groups:     groups,
 		Authorizer: authorizer,
 	}
 	if fn != nil {
@@ -121,6 +142,9 @@ func setupTest(t *testing.T, fn func(*Config)) (
 		rootConn.Close()
 		nobodyConn.Close()
 		l.Close()
+		groups.Close()
+		topics.Close()
+		os.RemoveAll(dir)
 	}
 }
 
@@ -129,6 +153,10 @@ func testProduceConsume(t *testing.T, client api.LogClient, _ api.LogClient, con
 
 	want := &api.LogRecord{
 		Value: []byte("hello world"),
+		Headers: []*api.Header{
+			{Key: "content-type", Value: []byte("text/plain")},
+			{Key: "trace-id", Value: []byte("4bf92f3577b34da6")},
+		},
 	}
 
 	produce, err := client.Produce(
@@ -145,6 +173,10 @@ func testProduceConsume(t *testing.T, client api.LogClient, _ api.LogClient, con
 	require.NoError(t, err)
 	require.Equal(t, want.Value, consume.Record.Value)
 	require.Equal(t, want.Offset, consume.Record.Offset)
+	require.Len(t, consume.Record.Headers, 2)
+	traceID, ok := consume.Record.Header("trace-id")
+	require.True(t, ok)
+	require.Equal(t, []byte("4bf92f3577b34da6"), traceID)
 }
 
 func testConsumePastBoundary(
@@ -223,10 +255,9 @@ func testProduceConsumeStream(
 		for i, record := range records {
 			res, err := stream.Recv()
 			require.NoError(t, err)
-			require.Equal(t, res.Record, &api.LogRecord{
-				Value:  record.Value,
-				Offset: uint64(i),
-			})
+			require.Equal(t, record.Value, res.Record.Value)
+			require.Equal(t, uint64(i), res.Record.Offset)
+			require.NotZero(t, res.Record.Timestamp)
 		}
 	}
 }
@@ -263,3 +294,643 @@ func testUnauthorized(
 		t.Fatalf("got code: %d, want: %d", gotCode, wantCode)
 	}
 }
+
+func testConsumeStreamFromTimestamp(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.LogRecord{
+				Value:     []byte(fmt.Sprintf("message %d", i)),
+				Timestamp: start.Add(time.Duration(i) * time.Minute).UnixNano(),
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{
+		StartTimestamp: start.Add(90 * time.Second).UnixNano(),
+	})
+	require.NoError(t, err)
+
+	for i := 2; i < 4; i++ {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, uint64(i), res.Record.Offset)
+		require.Equal(t, []byte(fmt.Sprintf("message %d", i)), res.Record.Value)
+	}
+}
+
+func testProduceBatch(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	_, err := client.Produce(ctx, &api.ProduceRequest{
+		Record: &api.LogRecord{Value: []byte("single")},
+	})
+	require.NoError(t, err)
+
+	records := []*api.LogRecord{
+		{Value: []byte("first")},
+		{Value: []byte("second")},
+		{Value: []byte("third")},
+	}
+	res, err := client.ProduceBatch(ctx, &api.ProduceBatchRequest{Records: records})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.FirstOffset)
+	require.Equal(t, uint64(3), res.LastOffset)
+
+	for i, record := range records {
+		consume, err := client.Consume(ctx, &api.ConsumeRequest{Offset: res.FirstOffset + uint64(i)})
+		require.NoError(t, err)
+		require.Equal(t, record.Value, consume.Record.Value)
+	}
+
+	_, err = client.ProduceBatch(ctx, &api.ProduceBatchRequest{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func testConsumeStreamWaits(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{Offset: 0})
+	require.NoError(t, err)
+
+	received := make(chan *api.ConsumeResponse)
+	go func() {
+		for {
+			res, err := stream.Recv()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- res
+		}
+	}()
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, err = client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.LogRecord{Value: []byte(fmt.Sprintf("late %d", i))},
+		})
+		require.NoError(t, err)
+
+		select {
+		case res := <-received:
+			require.Equal(t, uint64(i), res.Record.Offset)
+		case <-time.After(time.Second):
+			t.Fatal("record not streamed")
+		}
+	}
+}
+
+func testProduceCompressed(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	records := []*api.LogRecord{
+		{Value: []byte(`{"event":"created"}`)},
+		{Value: []byte(`{"event":"updated"}`)},
+	}
+	res, err := client.ProduceBatch(ctx, &api.ProduceBatchRequest{Records: records, Compression: api.Compression_GZIP})
+	require.NoError(t, err)
+	produce, err := client.Produce(ctx, &api.ProduceRequest{
+		Record:      &api.LogRecord{Value: []byte(`{"event":"deleted"}`)},
+		Compression: api.Compression_FLATE,
+	})
+	require.NoError(t, err)
+	require.Equal(t, res.LastOffset+1, produce.Offset)
+
+	records = append(records, &api.LogRecord{Value: []byte(`{"event":"deleted"}`)})
+	for i, record := range records {
+		consume, err := client.Consume(ctx, &api.ConsumeRequest{Offset: res.FirstOffset + uint64(i)})
+		require.NoError(t, err)
+		require.Equal(t, record.Value, consume.Record.Value)
+	}
+
+	_, err = client.Produce(ctx, &api.ProduceRequest{
+		Record:      &api.LogRecord{Value: []byte("unknown")},
+		Compression: api.Compression(42),
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func testProduceIdempotent(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	producer, err := client.RegisterProducer(ctx, &api.RegisterProducerRequest{})
+	require.NoError(t, err)
+	require.NotZero(t, producer.ProducerId)
+
+	produce := func(sequence uint64, value string) (*api.ProduceResponse, error) {
+		return client.Produce(ctx, &api.ProduceRequest{
+			Record:     &api.LogRecord{Value: []byte(value)},
+			ProducerId: producer.ProducerId,
+			Sequence:   sequence,
+		})
+	}
+
+	first, err := produce(1, "first")
+	require.NoError(t, err)
+	second, err := produce(2, "second")
+	require.NoError(t, err)
+
+	// the retry of a request which was already appended gets its offset back
+	retried, err := produce(1, "first")
+	require.NoError(t, err)
+	require.Equal(t, first.Offset, retried.Offset)
+
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: second.Offset + 1})
+	require.Error(t, err)
+
+	_, err = produce(0, "unnumbered")
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// round robin could send a retry to another partition than the request it retries
+	_, err = client.Produce(ctx, &api.ProduceRequest{
+		Record:      &api.LogRecord{Value: []byte("spread")},
+		ProducerId:  producer.ProducerId,
+		Sequence:    3,
+		Partitioner: api.Partitioner_ROUND_ROBIN,
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Produce(ctx, &api.ProduceRequest{
+		Record:     &api.LogRecord{Value: []byte("unregistered")},
+		ProducerId: producer.ProducerId + 1,
+		Sequence:   1,
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func testProduceExpected(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	res, err := client.ProduceBatch(ctx, &api.ProduceBatchRequest{
+		Records:        []*api.LogRecord{{Value: []byte("created")}, {Value: []byte("renamed")}},
+		ExpectedOffset: &api.ExpectedOffset{NextOffset: 0},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.LastOffset)
+
+	_, err = client.Produce(ctx, &api.ProduceRequest{
+		Record:         &api.LogRecord{Value: []byte("deleted")},
+		ExpectedOffset: &api.ExpectedOffset{NextOffset: 1},
+	})
+	st := status.Convert(err)
+	require.Equal(t, codes.FailedPrecondition, st.Code())
+	var nextOffset string
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			nextOffset = info.Metadata["next_offset"]
+		}
+	}
+	require.Equal(t, "2", nextOffset)
+
+	produce, err := client.Produce(ctx, &api.ProduceRequest{
+		Record:         &api.LogRecord{Value: []byte("deleted")},
+		ExpectedOffset: &api.ExpectedOffset{NextOffset: 2},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), produce.Offset)
+}
+
+func testTopics(
+	t *testing.T,
+	client api.LogClient,
+	nobodyClient api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	_, err := client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "orders"})
+	require.NoError(t, err)
+	_, err = client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "orders"})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "../orders"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = nobodyClient.CreateTopic(ctx, &api.CreateTopicRequest{Name: "payments"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	list, err := client.ListTopics(ctx, &api.ListTopicsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(list.Topics))
+	require.Equal(t, topic.DefaultTopic, list.Topics[0].Name)
+	require.Equal(t, "orders", list.Topics[1].Name)
+	require.Equal(t, uint32(1), list.Topics[1].Partitions)
+
+	// every topic numbers its records on its own
+	_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{Value: []byte("default")}})
+	require.NoError(t, err)
+	produce, err := client.Produce(ctx, &api.ProduceRequest{
+		Record: &api.LogRecord{Value: []byte("order")},
+		Topic:  "orders",
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), produce.Offset)
+	consume, err := client.Consume(ctx, &api.ConsumeRequest{Offset: 0, Topic: "orders"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("order"), consume.Record.Value)
+
+	_, err = client.DeleteTopic(ctx, &api.DeleteTopicRequest{Name: "orders"})
+	require.NoError(t, err)
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: 0, Topic: "orders"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.DeleteTopic(ctx, &api.DeleteTopicRequest{Name: topic.DefaultTopic})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func testPartitions(
+	t *testing.T,
+	client api.LogClient,
+	nobodyClient api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	_, err := client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "orders", Partitions: 2})
+	require.NoError(t, err)
+
+	// records sharing a key go to the same partition
+	first, err := client.Produce(ctx, &api.ProduceRequest{
+		Record: &api.LogRecord{Key: []byte("alice"), Value: []byte("created")},
+		Topic:  "orders",
+	})
+	require.NoError(t, err)
+	second, err := client.ProduceBatch(ctx, &api.ProduceBatchRequest{
+		Records:     []*api.LogRecord{{Key: []byte("alice"), Value: []byte("paid")}},
+		Topic:       "orders",
+		Partitioner: api.Partitioner_KEY_HASH,
+	})
+	require.NoError(t, err)
+	require.Equal(t, first.Partition, second.Partition)
+	require.Equal(t, uint64(1), second.FirstOffset)
+
+	consume, err := client.Consume(ctx, &api.ConsumeRequest{Offset: 1, Topic: "orders", Partition: first.Partition})
+	require.NoError(t, err)
+	require.Equal(t, []byte("paid"), consume.Record.Value)
+
+	// every partition numbers its records on its own
+	other := 1 - first.Partition
+	produce, err := client.Produce(ctx, &api.ProduceRequest{
+		Record:      &api.LogRecord{Value: []byte("created")},
+		Topic:       "orders",
+		Partitioner: api.Partitioner_EXPLICIT,
+		Partition:   other,
+	})
+	require.NoError(t, err)
+	require.Equal(t, other, produce.Partition)
+	require.Equal(t, uint64(0), produce.Offset)
+
+	_, err = client.Produce(ctx, &api.ProduceRequest{
+		Record:      &api.LogRecord{Value: []byte("created")},
+		Topic:       "orders",
+		Partitioner: api.Partitioner_EXPLICIT,
+		Partition:   2,
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Offset: 0, Topic: "orders", Partition: 2})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// the partition count can only increase
+	_, err = nobodyClient.AddPartitions(ctx, &api.AddPartitionsRequest{Name: "orders", Partitions: 3})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.AddPartitions(ctx, &api.AddPartitionsRequest{Name: "orders", Partitions: 1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.AddPartitions(ctx, &api.AddPartitionsRequest{Name: "orders", Partitions: 3})
+	require.NoError(t, err)
+
+	partitions := map[uint32]bool{}
+	for i := 0; i < 3; i++ {
+		produce, err = client.Produce(ctx, &api.ProduceRequest{
+			Record:      &api.LogRecord{Value: []byte("created")},
+			Topic:       "orders",
+			Partitioner: api.Partitioner_ROUND_ROBIN,
+		})
+		require.NoError(t, err)
+		partitions[produce.Partition] = true
+	}
+	require.Equal(t, 3, len(partitions))
+
+	list, err := client.ListTopics(ctx, &api.ListTopicsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "orders", list.Topics[1].Name)
+	require.Equal(t, uint32(3), list.Topics[1].Partitions)
+}
+
+func testGroups(
+	t *testing.T,
+	client api.LogClient,
+	nobodyClient api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	_, err := client.CreateTopic(ctx, &api.CreateTopicRequest{Name: "orders", Partitions: 2})
+	require.NoError(t, err)
+
+	_, err = nobodyClient.JoinGroup(ctx, &api.JoinGroupRequest{Group: "billing", Topic: "orders"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	first, err := client.JoinGroup(ctx, &api.JoinGroupRequest{Group: "billing", Topic: "orders"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{0, 1}, first.Partitions)
+	second, err := client.JoinGroup(ctx, &api.JoinGroupRequest{Group: "billing", Topic: "orders"})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1}, second.Partitions)
+
+	heartbeat, err := client.Heartbeat(ctx, &api.HeartbeatRequest{Group: "billing", MemberId: first.MemberId})
+	require.NoError(t, err)
+	require.Equal(t, second.Generation, heartbeat.Generation)
+	require.Equal(t, []uint32{0}, heartbeat.Partitions)
+
+	// commits of an earlier generation or of partitions of other members are rejected
+	_, err = client.CommitOffset(ctx, &api.CommitOffsetRequest{
+		Group: "billing", Topic: "orders", Partition: 0, Offset: 3,
+		MemberId: first.MemberId, Generation: first.Generation,
+	})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.CommitOffset(ctx, &api.CommitOffsetRequest{
+		Group: "billing", Topic: "orders", Partition: 1, Offset: 3,
+		MemberId: first.MemberId, Generation: heartbeat.Generation,
+	})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.CommitOffset(ctx, &api.CommitOffsetRequest{
+		Group: "billing", Topic: "orders", Partition: 0, Offset: 3,
+		MemberId: first.MemberId, Generation: heartbeat.Generation,
+	})
+	require.NoError(t, err)
+
+	fetch, err := client.FetchOffset(ctx, &api.FetchOffsetRequest{Group: "billing", Topic: "orders", Partition: 0})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), fetch.Offset)
+	_, err = client.FetchOffset(ctx, &api.FetchOffsetRequest{Group: "billing", Topic: "orders", Partition: 1})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// the partitions of a member leaving go to the others
+	_, err = client.LeaveGroup(ctx, &api.LeaveGroupRequest{Group: "billing", MemberId: first.MemberId})
+	require.NoError(t, err)
+	heartbeat, err = client.Heartbeat(ctx, &api.HeartbeatRequest{Group: "billing", MemberId: second.MemberId})
+	require.NoError(t, err)
+	require.Equal(t, []uint32{0, 1}, heartbeat.Partitions)
+	_, err = client.Heartbeat(ctx, &api.HeartbeatRequest{Group: "billing", MemberId: first.MemberId})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// without a member id the offsets are committed as is
+	_, err = client.CommitOffset(ctx, &api.CommitOffsetRequest{Group: "audit", Partition: 0, Offset: 7})
+	require.NoError(t, err)
+	fetch, err = client.FetchOffset(ctx, &api.FetchOffsetRequest{Group: "audit"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), fetch.Offset)
+}
+
+func testFetch(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	_, err := client.ProduceBatch(ctx, &api.ProduceBatchRequest{
+		Records: []*api.LogRecord{{Value: []byte("first")}, {Value: []byte("second")}, {Value: []byte("third")}},
+	})
+	require.NoError(t, err)
+
+	res, err := client.Fetch(ctx, &api.FetchRequest{Offset: 0})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(res.Records))
+	require.Equal(t, []byte("third"), res.Records[2].Value)
+	require.Equal(t, uint64(3), res.NextOffset)
+
+	// a full batch is returned without waiting for min bytes
+	start := time.Now()
+	res, err = client.Fetch(ctx, &api.FetchRequest{Offset: 1, MaxBytes: 1, MinBytes: 1024, MaxWaitMs: 5000})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res.Records))
+	require.Equal(t, uint64(2), res.NextOffset)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	res, err = client.Fetch(ctx, &api.FetchRequest{Offset: 3})
+	require.NoError(t, err)
+	require.Empty(t, res.Records)
+	require.Equal(t, uint64(3), res.NextOffset)
+
+	start = time.Now()
+	res, err = client.Fetch(ctx, &api.FetchRequest{Offset: 3, MinBytes: 1, MaxWaitMs: 50})
+	require.NoError(t, err)
+	require.Empty(t, res.Records)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(50*time.Millisecond))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = client.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{Value: []byte("fourth")}})
+	}()
+	res, err = client.Fetch(ctx, &api.FetchRequest{Offset: 3, MinBytes: 1, MaxWaitMs: 5000})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(res.Records))
+	require.Equal(t, []byte("fourth"), res.Records[0].Value)
+
+	// the records appended while the fetch waits are returned once each
+	go func() {
+		for _, value := range []string{"fifth", "sixth"} {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = client.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{Value: []byte(value)}})
+		}
+	}()
+	res, err = client.Fetch(ctx, &api.FetchRequest{Offset: 4, MinBytes: 30, MaxWaitMs: 5000})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(res.Records))
+	require.Equal(t, []byte("fifth"), res.Records[0].Value)
+	require.Equal(t, []byte("sixth"), res.Records[1].Value)
+	require.Equal(t, uint64(6), res.NextOffset)
+
+	// a fetch asking for more than a response holds gets what fits
+	value := []byte(strings.Repeat("x", 1<<20))
+	for i := 0; i < 5; i++ {
+		_, err = client.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{Value: value}})
+		require.NoError(t, err)
+	}
+	res, err = client.Fetch(ctx, &api.FetchRequest{Offset: 6, MaxBytes: 1 << 40})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(res.Records))
+	require.Equal(t, uint64(9), res.NextOffset)
+}
+
+func testStartPositions(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	offsets, err := client.GetOffsets(ctx, &api.GetOffsetsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), offsets.LowWatermark)
+	require.Equal(t, uint64(0), offsets.HighWatermark)
+
+	for i := 0; i < 5; i++ {
+		_, err := client.Produce(ctx, &api.ProduceRequest{
+			Record: &api.LogRecord{Value: []byte(fmt.Sprintf("message %d", i))},
+		})
+		require.NoError(t, err)
+	}
+
+	offsets, err = client.GetOffsets(ctx, &api.GetOffsetsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), offsets.LowWatermark)
+	require.Equal(t, uint64(5), offsets.HighWatermark)
+	_, err = client.GetOffsets(ctx, &api.GetOffsetsRequest{Partition: 1})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	for _, tc := range []struct {
+		req    *api.ConsumeRequest
+		offset uint64
+	}{
+		{&api.ConsumeRequest{Offset: 4, StartPosition: api.StartPosition_START_EARLIEST}, 0},
+		{&api.ConsumeRequest{StartPosition: api.StartPosition_START_LATEST_MINUS, LatestMinus: 2}, 3},
+		{&api.ConsumeRequest{StartPosition: api.StartPosition_START_LATEST_MINUS, LatestMinus: 10}, 0},
+		{&api.ConsumeRequest{StartPosition: api.StartPosition_START_TIMESTAMP}, 0},
+		{&api.ConsumeRequest{Offset: 1}, 1},
+	} {
+		res, err := client.Consume(ctx, tc.req)
+		require.NoError(t, err)
+		require.Equal(t, tc.offset, res.Record.Offset)
+	}
+
+	_, err = client.Consume(ctx, &api.ConsumeRequest{StartPosition: api.StartPosition_START_LATEST})
+	require.Error(t, err)
+	_, err = client.Consume(ctx, &api.ConsumeRequest{StartPosition: api.StartPosition(42)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// a stream from the latest position only sees the records appended afterwards, which may start after the
+	// first ones produced since the server resolves the position once the stream reaches it
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{StartPosition: api.StartPosition_START_LATEST})
+	require.NoError(t, err)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+				_, _ = client.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{Value: []byte("tail")}})
+			}
+		}
+	}()
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, res.Record.Offset, uint64(5))
+	require.Equal(t, []byte("tail"), res.Record.Value)
+}
+
+func testConsumeStreamFiltered(
+	t *testing.T,
+	client api.LogClient,
+	_ api.LogClient,
+	config *Config,
+) {
+	ctx := context.Background()
+
+	for _, rec := range []struct {
+		kind  string
+		value string
+	}{
+		{"order", `{"total": 5}`},
+		{"order", `{"total": 50}`},
+		{"refund", `{"total": 50}`},
+		{"refund", `{"total": 70}`},
+	} {
+		_, err := client.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{
+			Value:   []byte(rec.value),
+			Headers: []*api.Header{{Key: "type", Value: []byte(rec.kind)}},
+		}})
+		require.NoError(t, err)
+	}
+
+	stream, err := client.ConsumeStream(ctx, &api.ConsumeRequest{Filter: `headers.type == "order" && value.total > 10`})
+	require.NoError(t, err)
+
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Record.Offset)
+	require.Equal(t, uint64(2), res.NextOffset)
+
+	// the skipped records at the end of the log are reported as progress
+	res, err = stream.Recv()
+	require.NoError(t, err)
+	require.Nil(t, res.Record)
+	require.Equal(t, uint64(4), res.NextOffset)
+
+	for _, expr := range []string{`headers.type ==`, strings.Repeat("(", 1<<20)} {
+		stream, err = client.ConsumeStream(ctx, &api.ConsumeRequest{Filter: expr})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+
+	_, err = client.Consume(ctx, &api.ConsumeRequest{Filter: `headers.type == "order"`})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_TopicAuthorization(t *testing.T) {
+	policy, err := ioutil.TempFile("", "policy-*.csv")
+	require.NoError(t, err)
+	defer os.Remove(policy.Name())
+	_, err = policy.WriteString("p, root, *, produce\np, root, *, create\np, nobody, public-*, consume\n")
+	require.NoError(t, err)
+	require.NoError(t, policy.Close())
+
+	rootClient, nobodyClient, _, teardown := setupTest(t, func(cfg *Config) {
+		cfg.Authorizer = auth.New(config.ACLModelFile, policy.Name())
+	})
+	defer teardown()
+
+	ctx := context.Background()
+	for _, name := range []string{"public-news", "private-news"} {
+		_, err = rootClient.CreateTopic(ctx, &api.CreateTopicRequest{Name: name})
+		require.NoError(t, err)
+		_, err = rootClient.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{Value: []byte(name)}, Topic: name})
+		require.NoError(t, err)
+	}
+
+	consume, err := nobodyClient.Consume(ctx, &api.ConsumeRequest{Topic: "public-news"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("public-news"), consume.Record.Value)
+	_, err = nobodyClient.Consume(ctx, &api.ConsumeRequest{Topic: "private-news"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = nobodyClient.Produce(ctx, &api.ProduceRequest{Record: &api.LogRecord{Value: []byte("x")}, Topic: "public-news"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
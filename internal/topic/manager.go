@@ -0,0 +1,161 @@
+package topic
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/log"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// DefaultTopic is addressed by requests which don't name a topic, it always exists
+const DefaultTopic = "default"
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// CheckName returns an error for names which can't be used as topic names
+func CheckName(name string) error {
+	if !namePattern.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid topic name %q: letters, digits, '.', '_' and '-' only, up to 249 of them", name)
+	}
+	return nil
+}
+
+// Manager owns the topics, each of them stored in a subdirectory of the manager's directory
+type Manager struct {
+	mux    sync.RWMutex
+	dir    string
+	config log.Config
+	topics map[string]*Topic
+}
+
+// NewManager opens the topics found in dir and creates the default topic, with a single partition, if needed
+// every partition is opened with the same config
+func NewManager(dir string, config log.Config) (*Manager, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		dir:    dir,
+		config: config,
+		topics: make(map[string]*Topic),
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if !f.IsDir() || CheckName(f.Name()) != nil {
+			continue
+		}
+		if err = m.open(f.Name(), 1); err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+	}
+
+	if _, ok := m.topics[DefaultTopic]; !ok {
+		if err = m.open(DefaultTopic, 1); err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// open must be called with the lock held
+func (m *Manager) open(name string, partitions uint32) error {
+	t, err := openTopic(path.Join(m.dir, name), name, m.config, partitions)
+	if err != nil {
+		return err
+	}
+	m.topics[name] = t
+	return nil
+}
+
+// Create creates an empty topic, with a single partition when partitions is 0
+// fails with api.ErrTopicExists when there is already one with this name
+func (m *Manager) Create(name string, partitions uint32) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	if partitions == 0 {
+		partitions = 1
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.topics[name]; ok {
+		return api.ErrTopicExists{Topic: name}
+	}
+	return m.open(name, partitions)
+}
+
+// Delete removes the topic and its records, the default topic can't be deleted
+func (m *Manager) Delete(name string) error {
+	if name == DefaultTopic {
+		return fmt.Errorf("the default topic can't be deleted")
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	t, ok := m.topics[name]
+	if !ok {
+		return api.ErrTopicNotFound{Topic: name}
+	}
+	delete(m.topics, name)
+
+	return t.remove()
+}
+
+// Topic returns the topic, the default topic when name is empty
+func (m *Manager) Topic(name string) (*Topic, error) {
+	if name == "" {
+		name = DefaultTopic
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	t, ok := m.topics[name]
+	if !ok {
+		return nil, api.ErrTopicNotFound{Topic: name}
+	}
+	return t, nil
+}
+
+// List returns the names of the topics in alphabetical order
+func (m *Manager) List() []string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	names := make([]string, 0, len(m.topics))
+	for name := range m.topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes the logs of every topic
+func (m *Manager) Close() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for name, t := range m.topics {
+		if err := t.close(); err != nil {
+			return err
+		}
+		delete(m.topics, name)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package topic
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/log"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topic-manager")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m, err := NewManager(dir, log.Config{})
+	require.NoError(t, err)
+	require.Equal(t, []string{DefaultTopic}, m.List())
+
+	require.NoError(t, m.Create("orders", 0))
+	require.Equal(t, api.ErrTopicExists{Topic: "orders"}, m.Create("orders", 0))
+	require.Error(t, m.Create("..", 0))
+	require.Error(t, m.Create("orders/2021", 0))
+	require.NoError(t, m.Create("payments", 3))
+
+	orders, err := m.Topic("orders")
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), orders.Partitions())
+	ordersLog, err := orders.Partition(0)
+	require.NoError(t, err)
+	_, err = ordersLog.Append(&api.LogRecord{Value: []byte("order")})
+	require.NoError(t, err)
+
+	def, err := m.Topic("")
+	require.NoError(t, err)
+	defLog, err := def.Partition(0)
+	require.NoError(t, err)
+	_, high := defLog.Offsets()
+	require.Zero(t, high)
+
+	_, err = m.Topic("refunds")
+	require.Equal(t, api.ErrTopicNotFound{Topic: "refunds"}, err)
+
+	// the topics are found again when the manager is reopened
+	require.NoError(t, m.Close())
+	m, err = NewManager(dir, log.Config{})
+	require.NoError(t, err)
+	defer m.Close()
+	require.Equal(t, []string{DefaultTopic, "orders", "payments"}, m.List())
+
+	payments, err := m.Topic("payments")
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), payments.Partitions())
+
+	orders, err = m.Topic("orders")
+	require.NoError(t, err)
+	ordersLog, err = orders.Partition(0)
+	require.NoError(t, err)
+	rec, err := ordersLog.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("order"), rec.Value)
+
+	require.NoError(t, m.Delete("orders"))
+	require.Equal(t, []string{DefaultTopic, "payments"}, m.List())
+	_, err = os.Stat(path.Join(dir, "orders"))
+	require.True(t, os.IsNotExist(err))
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, m.Delete("orders"))
+
+	// requests which got the topic before it was deleted fail instead of using its closed logs
+	_, err = orders.Partition(0)
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, err)
+	_, _, err = orders.PartitionFor(&RoundRobinPartitioner{}, []*api.LogRecord{{Value: []byte("order")}})
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, err)
+	_, err = orders.RegisterProducer()
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, err)
+	require.Equal(t, api.ErrTopicNotFound{Topic: "orders"}, orders.AddPartitions(2))
+	_, err = ordersLog.Append(&api.LogRecord{Value: []byte("order")})
+	require.Equal(t, api.ErrLogClosed{Dir: path.Join(dir, "orders", "0")}, err)
+	require.Error(t, m.Delete(DefaultTopic))
+}
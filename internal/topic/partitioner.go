@@ -0,0 +1,81 @@
+package topic
+
+import (
+	"EchoLog/api/v1"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// Partitioner picks the partition of a topic the records appended together go to
+// a batch is appended to a single partition so it stays atomic
+type Partitioner interface {
+	Partition(recs []*api.LogRecord, partitions uint32) (uint32, error)
+}
+
+// KeyHashPartitioner keeps the records sharing a key in the same partition, as long as the partition count doesn't
+// change: adding partitions moves keys to other partitions for the records appended from then on
+// the keys of the records of a batch must all hash to the same partition
+type KeyHashPartitioner struct{}
+
+func (KeyHashPartitioner) Partition(recs []*api.LogRecord, partitions uint32) (uint32, error) {
+	partition := keyPartition(recs[0].Key, partitions)
+	for _, rec := range recs[1:] {
+		if keyPartition(rec.Key, partitions) != partition {
+			return 0, fmt.Errorf("the keys of the records of the batch belong to different partitions")
+		}
+	}
+	return partition, nil
+}
+
+func keyPartition(key []byte, partitions uint32) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write(key)
+	return h.Sum32() % partitions
+}
+
+// RoundRobinPartitioner spreads the records evenly across the partitions
+type RoundRobinPartitioner struct {
+	next uint32
+}
+
+func (p *RoundRobinPartitioner) Partition(_ []*api.LogRecord, partitions uint32) (uint32, error) {
+	return (atomic.AddUint32(&p.next, 1) - 1) % partitions, nil
+}
+
+// ExplicitPartitioner appends the records to the partition it holds
+type ExplicitPartitioner uint32
+
+func (p ExplicitPartitioner) Partition(_ []*api.LogRecord, partitions uint32) (uint32, error) {
+	if uint32(p) >= partitions {
+		return 0, fmt.Errorf("partition %d doesn't exist, the topic has %d", p, partitions)
+	}
+	return uint32(p), nil
+}
+
+// ProducerPartitioner appends every record of an idempotent producer to the same partition, chosen by its id, so a
+// retry reaches the partition which remembers its sequence as long as the partition count doesn't change
+type ProducerPartitioner uint64
+
+func (p ProducerPartitioner) Partition(_ []*api.LogRecord, partitions uint32) (uint32, error) {
+	return uint32(uint64(p) % uint64(partitions)), nil
+}
+
+// DefaultPartitioner hashes the keys of the records when they have one and hands the others to Unkeyed, the records
+// of a batch without a key follow the ones with a key
+type DefaultPartitioner struct {
+	Unkeyed Partitioner
+}
+
+func (p DefaultPartitioner) Partition(recs []*api.LogRecord, partitions uint32) (uint32, error) {
+	keyed := make([]*api.LogRecord, 0, len(recs))
+	for _, rec := range recs {
+		if len(rec.Key) > 0 {
+			keyed = append(keyed, rec)
+		}
+	}
+	if len(keyed) == 0 {
+		return p.Unkeyed.Partition(recs, partitions)
+	}
+	return KeyHashPartitioner{}.Partition(keyed, partitions)
+}
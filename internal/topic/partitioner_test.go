@@ -0,0 +1,73 @@
+package topic
+
+import (
+	"EchoLog/api/v1"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPartitioner(t *testing.T) {
+	keyed := func(keys ...string) []*api.LogRecord {
+		recs := make([]*api.LogRecord, 0, len(keys))
+		for _, key := range keys {
+			recs = append(recs, &api.LogRecord{Key: []byte(key), Value: []byte("value")})
+		}
+		return recs
+	}
+
+	for scenario, fn := range map[string]func(t *testing.T){
+		"key hash keeps a key in one partition": func(t *testing.T) {
+			first, err := KeyHashPartitioner{}.Partition(keyed("alice"), 8)
+			require.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				p, err := KeyHashPartitioner{}.Partition(keyed("alice", "alice"), 8)
+				require.NoError(t, err)
+				require.Equal(t, first, p)
+			}
+		},
+		"key hash rejects a batch spanning partitions": func(t *testing.T) {
+			keys := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+			_, err := KeyHashPartitioner{}.Partition(keyed(keys...), 8)
+			require.Error(t, err)
+		},
+		"round robin cycles through the partitions": func(t *testing.T) {
+			p := &RoundRobinPartitioner{}
+			for i := uint32(0); i < 6; i++ {
+				partition, err := p.Partition(nil, 3)
+				require.NoError(t, err)
+				require.Equal(t, i%3, partition)
+			}
+		},
+		"explicit checks the partition exists": func(t *testing.T) {
+			partition, err := ExplicitPartitioner(2).Partition(nil, 3)
+			require.NoError(t, err)
+			require.Equal(t, uint32(2), partition)
+			_, err = ExplicitPartitioner(3).Partition(nil, 3)
+			require.Error(t, err)
+		},
+		"default hashes keys and round robins the rest": func(t *testing.T) {
+			p := DefaultPartitioner{Unkeyed: &RoundRobinPartitioner{}}
+			want, err := KeyHashPartitioner{}.Partition(keyed("alice"), 4)
+			require.NoError(t, err)
+			recs := append(keyed("alice"), &api.LogRecord{Value: []byte("value")})
+			partition, err := p.Partition(recs, 4)
+			require.NoError(t, err)
+			require.Equal(t, want, partition)
+
+			for i := uint32(0); i < 4; i++ {
+				partition, err = p.Partition([]*api.LogRecord{{Value: []byte("value")}}, 4)
+				require.NoError(t, err)
+				require.Equal(t, i, partition)
+			}
+		},
+		"producer pins its records to a partition": func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				partition, err := ProducerPartitioner(7).Partition(nil, 4)
+				require.NoError(t, err)
+				require.Equal(t, uint32(3), partition)
+			}
+		},
+	} {
+		t.Run(scenario, fn)
+	}
+}
@@ -0,0 +1,263 @@
+package topic
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/log"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+)
+
+// Topic is split into partitions, each of them a log stored in a subdirectory of the topic named after its number
+// records are only ordered within a partition; the partition count can be increased, the records already appended
+// stay where they are
+type Topic struct {
+	mux        sync.RWMutex
+	name       string
+	dir        string
+	config     log.Config
+	partitions []*log.Log
+	roundRobin RoundRobinPartitioner
+	// set once the topic is closed or deleted, requests still holding the topic fail with api.ErrTopicNotFound
+	closed bool
+}
+
+// suffix of the directory the log of a topic stored before topics were partitioned is moved to, before it becomes
+// partition 0
+const adoptSuffix = ".adopt"
+
+// openTopic opens the partitions of the topic stored in dir, creating it with the given number of partitions when it
+// has none
+func openTopic(dir string, name string, config log.Config, partitions uint32) (*Topic, error) {
+	t := &Topic{
+		name:   name,
+		dir:    dir,
+		config: config,
+	}
+
+	if err := adoptLegacyLog(dir); err != nil {
+		return nil, fmt.Errorf("topic %s: %w", name, err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var existing uint32
+	for _, f := range files {
+		if n, err := strconv.ParseUint(f.Name(), 10, 32); err == nil && f.IsDir() && uint32(n) >= existing {
+			existing = uint32(n) + 1
+		}
+	}
+	if existing > partitions {
+		partitions = existing
+	}
+
+	if err = t.addPartitions(partitions); err != nil {
+		_ = t.close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// adoptLegacyLog turns the log of a topic created before topics were partitioned, whose segments are stored in the
+// directory of the topic itself, into partition 0 of the topic
+// the files are moved to a staging directory renamed to 0 once they are all there, a move interrupted by a crash is
+// resumed the next time the topic is opened
+func adoptLegacyLog(dir string) error {
+	files, err := log.Files(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	staging := path.Join(dir, "0"+adoptSuffix)
+	if _, err = os.Stat(staging); os.IsNotExist(err) {
+		if len(files) == 0 {
+			return nil
+		}
+	} else if err != nil {
+		return err
+	}
+
+	partition := path.Join(dir, "0")
+	if _, err = os.Stat(partition); err == nil {
+		return fmt.Errorf("%s holds the segments of an unpartitioned log as well as partition 0, move them out of "+
+			"the way", dir)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err = os.MkdirAll(staging, os.ModePerm); err != nil {
+		return err
+	}
+	for _, name := range files {
+		if err = os.Rename(path.Join(dir, name), path.Join(staging, name)); err != nil {
+			return err
+		}
+	}
+	return os.Rename(staging, partition)
+}
+
+func (t *Topic) Name() string {
+	return t.name
+}
+
+// Partitions returns the number of partitions of the topic
+func (t *Topic) Partitions() uint32 {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	return uint32(len(t.partitions))
+}
+
+// Partition returns the log of the partition, fails with api.ErrPartitionNotFound when the topic has no such partition
+func (t *Topic) Partition(partition uint32) (*log.Log, error) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	if err := t.checkOpen(); err != nil {
+		return nil, err
+	}
+	if partition >= uint32(len(t.partitions)) {
+		return nil, api.ErrPartitionNotFound{Topic: t.name, Partition: partition}
+	}
+	return t.partitions[partition], nil
+}
+
+// Partitioner returns the partitioner selected by a produce request, partition is only used by EXPLICIT
+// the records of an idempotent producer, whose id isn't 0, must go to the same partition when they are retried:
+// DEFAULT pins the records without a key to a partition chosen by the producer id and ROUND_ROBIN is refused
+func (t *Topic) Partitioner(partitioner api.Partitioner, partition uint32, producerID uint64) (Partitioner, error) {
+	switch partitioner {
+	case api.Partitioner_DEFAULT_PARTITIONER:
+		if producerID != 0 {
+			return DefaultPartitioner{Unkeyed: ProducerPartitioner(producerID)}, nil
+		}
+		return DefaultPartitioner{Unkeyed: &t.roundRobin}, nil
+	case api.Partitioner_KEY_HASH:
+		return KeyHashPartitioner{}, nil
+	case api.Partitioner_ROUND_ROBIN:
+		if producerID != 0 {
+			return nil, fmt.Errorf("idempotent producers can't use round robin, a retry could go to another partition")
+		}
+		return &t.roundRobin, nil
+	case api.Partitioner_EXPLICIT:
+		return ExplicitPartitioner(partition), nil
+	}
+	return nil, fmt.Errorf("unknown partitioner: %d", partitioner)
+}
+
+// PartitionFor picks the partition the records go to and returns it along with its log
+func (t *Topic) PartitionFor(partitioner Partitioner, recs []*api.LogRecord) (uint32, *log.Log, error) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	if err := t.checkOpen(); err != nil {
+		return 0, nil, err
+	}
+	partition, err := partitioner.Partition(recs, uint32(len(t.partitions)))
+	if err != nil {
+		return 0, nil, err
+	}
+	return partition, t.partitions[partition], nil
+}
+
+// AddPartitions increases the number of partitions of the topic to the given count
+func (t *Topic) AddPartitions(partitions uint32) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if err := t.checkOpen(); err != nil {
+		return err
+	}
+	if partitions < uint32(len(t.partitions)) {
+		return fmt.Errorf("topic %s has %d partitions, their number can't be decreased", t.name, len(t.partitions))
+	}
+	return t.addPartitions(partitions)
+}
+
+// addPartitions must be called with the lock held
+// the new partitions know the producers registered with the topic
+func (t *Topic) addPartitions(partitions uint32) error {
+	var producers []uint64
+	if len(t.partitions) > 0 {
+		producers = t.partitions[0].ProducerIDs()
+	}
+
+	for p := uint32(len(t.partitions)); p < partitions; p++ {
+		l, err := log.NewLog(path.Join(t.dir, strconv.FormatUint(uint64(p), 10)), t.config)
+		if err != nil {
+			return fmt.Errorf("topic %s, partition %d: %w", t.name, p, err)
+		}
+		t.partitions = append(t.partitions, l)
+
+		for _, id := range producers {
+			if err = l.AddProducer(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterProducer hands out a producer id valid on every partition of the topic
+// the first partition hands out the ids, the others are told about them
+func (t *Topic) RegisterProducer() (uint64, error) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	if err := t.checkOpen(); err != nil {
+		return 0, err
+	}
+	id, err := t.partitions[0].RegisterProducer()
+	if err != nil {
+		return 0, err
+	}
+	for _, l := range t.partitions[1:] {
+		if err = l.AddProducer(id); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// checkOpen must be called with the lock held
+func (t *Topic) checkOpen() error {
+	if t.closed {
+		return api.ErrTopicNotFound{Topic: t.name}
+	}
+	return nil
+}
+
+func (t *Topic) close() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.closed = true
+	for i, l := range t.partitions {
+		if err := l.Close(); err != nil {
+			t.partitions = t.partitions[i:]
+			return fmt.Errorf("topic %s: %w", t.name, err)
+		}
+	}
+	t.partitions = nil
+	return nil
+}
+
+func (t *Topic) remove() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.closed = true
+	for _, l := range t.partitions {
+		if err := l.Remove(); err != nil {
+			return fmt.Errorf("topic %s: %w", t.name, err)
+		}
+	}
+	t.partitions = nil
+	return os.RemoveAll(t.dir)
+}
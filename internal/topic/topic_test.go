@@ -0,0 +1,117 @@
+package topic
+
+import (
+	"EchoLog/api/v1"
+	"EchoLog/internal/log"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestTopic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topic")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	top, err := openTopic(dir, "orders", log.Config{}, 2)
+	require.NoError(t, err)
+	require.Equal(t, "orders", top.Name())
+	require.Equal(t, uint32(2), top.Partitions())
+
+	_, err = top.Partition(2)
+	require.Equal(t, api.ErrPartitionNotFound{Topic: "orders", Partition: 2}, err)
+
+	p, err := top.Partitioner(api.Partitioner_EXPLICIT, 1, 0)
+	require.NoError(t, err)
+	partition, l, err := top.PartitionFor(p, []*api.LogRecord{{Value: []byte("order")}})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), partition)
+	_, err = l.Append(&api.LogRecord{Value: []byte("order")})
+	require.NoError(t, err)
+
+	_, err = top.Partitioner(api.Partitioner(42), 0, 0)
+	require.Error(t, err)
+
+	// producer ids are valid on every partition, including the ones added later
+	id, err := top.RegisterProducer()
+	require.NoError(t, err)
+
+	require.Error(t, top.AddPartitions(1))
+	require.NoError(t, top.AddPartitions(3))
+	require.Equal(t, uint32(3), top.Partitions())
+
+	for i := uint32(0); i < top.Partitions(); i++ {
+		l, err := top.Partition(i)
+		require.NoError(t, err)
+		_, _, err = l.AppendIdempotent(id, 1, []*api.LogRecord{{Value: []byte("order")}}, api.Compression_NO_COMPRESSION)
+		require.NoError(t, err)
+	}
+
+	next, err := top.RegisterProducer()
+	require.NoError(t, err)
+	require.Greater(t, next, id)
+
+	// the retries of an idempotent producer reach the partition of the request they retry
+	_, err = top.Partitioner(api.Partitioner_ROUND_ROBIN, 0, id)
+	require.Error(t, err)
+	p, err = top.Partitioner(api.Partitioner_DEFAULT_PARTITIONER, 0, id)
+	require.NoError(t, err)
+	pinned, _, err := top.PartitionFor(p, []*api.LogRecord{{Value: []byte("order")}})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		partition, _, err = top.PartitionFor(p, []*api.LogRecord{{Value: []byte("order")}})
+		require.NoError(t, err)
+		require.Equal(t, pinned, partition)
+	}
+
+	// the partitions and their records are found again when the topic is reopened
+	require.NoError(t, top.close())
+	top, err = openTopic(dir, "orders", log.Config{}, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), top.Partitions())
+	l, err = top.Partition(1)
+	require.NoError(t, err)
+	rec, err := l.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("order"), rec.Value)
+
+	require.NoError(t, top.remove())
+	_, err = os.Stat(dir)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestTopic_LegacyLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "topic-legacy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// topics stored their segments in their own directory before they were partitioned
+	legacy, err := log.NewLog(dir, log.Config{})
+	require.NoError(t, err)
+	id, err := legacy.RegisterProducer()
+	require.NoError(t, err)
+	_, _, err = legacy.AppendIdempotent(id, 1, []*api.LogRecord{{Value: []byte("order")}}, api.Compression_NO_COMPRESSION)
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	top, err := openTopic(dir, "orders", log.Config{}, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), top.Partitions())
+	l, err := top.Partition(0)
+	require.NoError(t, err)
+	rec, err := l.Read(0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("order"), rec.Value)
+	require.Equal(t, []uint64{id}, l.ProducerIDs())
+	files, err := log.Files(dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+	require.NoError(t, top.close())
+
+	// segments found next to partition 0 aren't mixed with it
+	require.NoError(t, ioutil.WriteFile(path.Join(dir, "0.store"), nil, 0644))
+	_, err = openTopic(dir, "orders", log.Config{}, 2)
+	require.Error(t, err)
+}